@@ -5,6 +5,8 @@ import (
 
 	"github.com/gobuffalo/packr/v2"
 	"github.com/gobuffalo/suite/v3"
+	"github.com/gofrs/uuid"
+	"github.com/navionguy/cloudquotes/models"
 )
 
 type ActionSuite struct {
@@ -22,3 +24,24 @@ func Test_ActionSuite(t *testing.T) {
 	}
 	suite.Run(t, as)
 }
+
+// signIn makes a user with the passed site wide permissions and signs
+// them in for the rest of the test
+func (as *ActionSuite) signIn(perms ...string) *models.User {
+	u := &models.User{
+		Email:                uuid.Must(uuid.NewV4()).String() + "@example.com",
+		Password:             "password",
+		PasswordConfirmation: "password",
+	}
+	verrs, err := u.Create(as.DB)
+	as.NoError(err)
+	as.False(verrs.HasAny())
+
+	for _, perm := range perms {
+		as.NoError(as.DB.Create(&models.Permission{Name: perm, UserID: u.ID}))
+	}
+
+	as.Session.Set("current_user_id", u.ID)
+
+	return u
+}
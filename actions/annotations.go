@@ -0,0 +1,221 @@
+package actions
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/pop/v5"
+	"github.com/gobuffalo/validate/v3"
+	"github.com/gofrs/uuid"
+	"github.com/navionguy/cloudquotes/models"
+	"github.com/pkg/errors"
+)
+
+// AnnotationsResource is the resource for the Annotation model.
+// Annotations get created from the conversation form and are shared
+// between every quote with the same note, so editing one needs to
+// show who else is using it.
+type AnnotationsResource struct {
+	buffalo.Resource
+}
+
+// List all the annotations and how many quotes use each one.
+// This function is mapped to the path GET /annotations
+func (v AnnotationsResource) List(c buffalo.Context) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	// Paginate results. Params "page" and "per_page" control pagination.
+	// Default values are "page=1" and "per_page=20".
+	aq := tx.PaginateFromParams(c.Params()).RawQuery("SELECT annotations.id, annotations.note, COUNT(quotes.id) FROM annotations LEFT JOIN quotes ON quotes.annotation_id = annotations.id AND quotes.deleted_at IS NULL WHERE annotations.archive_id = ? GROUP BY annotations.id ORDER BY annotations.note", currentArchive(c).ID)
+
+	annotationCredits := &models.AnnotationCredits{}
+
+	if err := aq.All(annotationCredits); err != nil {
+		return errors.WithStack(err)
+	}
+
+	// Add the paginator to the context so it can be used in the template.
+	c.Set("pagination", aq.Paginator)
+
+	return c.Render(200, r.Auto(c, annotationCredits))
+}
+
+// Edit renders the edit form for an Annotation along with every quote
+// that uses it. This function is mapped to the path
+// GET /annotations/{annotation_id}/edit
+func (v AnnotationsResource) Edit(c buffalo.Context) error {
+	annotation, err := v.loadAnnotation(c)
+
+	if err != nil {
+		return c.Error(404, err)
+	}
+
+	if err = v.loadForm(annotation, c); err != nil {
+		return errors.WithStack(err)
+	}
+
+	return c.Render(200, r.Auto(c, annotation))
+}
+
+// Update changes an Annotation.  The "scope" param says who gets the
+// new note.  "all" rewrites it for every quote sharing the annotation,
+// a quote ID gives just that quote its own copy.  This function is
+// mapped to the path PUT /annotations/{annotation_id}
+func (v AnnotationsResource) Update(c buffalo.Context) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	annotation, err := v.loadAnnotation(c)
+
+	if err != nil {
+		return c.Error(404, err)
+	}
+
+	note := strings.TrimSpace(c.Param("note"))
+	scope := c.Param("scope")
+
+	if len(scope) == 0 || scope == "all" {
+		saved, verrs, err := annotation.Rewrite(tx, note, currentUserID(c))
+
+		if err != nil {
+			return errors.WithStack(err)
+		}
+
+		if verrs.HasAny() {
+			return v.renderErrors(annotation, verrs, c)
+		}
+
+		c.Flash().Add("success", "Annotation was updated everywhere it is used")
+
+		return c.Redirect(302, "/annotations/%s/edit", saved.ID)
+	}
+
+	quoteID, err := uuid.FromString(scope)
+
+	if err != nil {
+		return c.Error(400, err)
+	}
+
+	saved, verrs, err := annotation.Fork(tx, quoteID, note, currentUserID(c))
+
+	if errors.Cause(err) == sql.ErrNoRows {
+		// that quote isn't using this annotation
+		return c.Error(404, err)
+	}
+
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	if verrs.HasAny() {
+		annotation.Note = note
+		return v.renderErrors(annotation, verrs, c)
+	}
+
+	c.Flash().Add("success", "Annotation was changed for that quote only")
+
+	return c.Redirect(302, "/annotations/%s/edit", saved.ID)
+}
+
+// Destroy deletes an Annotation from the DB.  As long as any quote
+// outside the trash is still using it the delete is refused, unless the
+// "detach" param says to take it off those quotes first.  This function is mapped to the
+// path DELETE /annotations/{annotation_id}
+func (v AnnotationsResource) Destroy(c buffalo.Context) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	annotation, err := v.loadAnnotation(c)
+
+	if err != nil {
+		return c.Error(404, err)
+	}
+
+	n, err := tx.Where("annotation_id = ? AND deleted_at IS NULL", annotation.ID).Count(&models.Quote{})
+
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	if n > 0 && c.Param("detach") != "true" {
+		c.Flash().Add("danger", fmt.Sprintf("That annotation is still used by %d quotes.", n))
+
+		return c.Redirect(302, "/annotations/%s/edit", annotation.ID)
+	}
+
+	// quotes in the trash don't count as using it, but they still have
+	// to let go of it before it can be destroyed
+	if err = annotation.Detach(tx, currentUserID(c)); err != nil {
+		return errors.WithStack(err)
+	}
+
+	if err = annotation.Remove(tx, currentUserID(c)); err != nil {
+		return errors.WithStack(err)
+	}
+
+	c.Flash().Add("success", "Annotation was destroyed successfully")
+
+	return c.Redirect(302, "/annotations")
+}
+
+// loadAnnotation finds the annotation named by the annotation_id parameter
+func (v AnnotationsResource) loadAnnotation(c buffalo.Context) (*models.Annotation, error) {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return nil, errors.WithStack(errors.New("no transaction found"))
+	}
+
+	annotation := &models.Annotation{}
+
+	if err := tx.Where("archive_id = ?", currentArchive(c).ID).Find(annotation, c.Param("annotation_id")); err != nil {
+		return nil, err
+	}
+
+	return annotation, nil
+}
+
+// loadForm sets up everything the edit form needs, which is the
+// annotation and every quote that shares it.
+func (v AnnotationsResource) loadForm(annotation *models.Annotation, c buffalo.Context) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	quotes, err := annotation.Quotes(tx)
+
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	c.Set("annotation", annotation)
+	c.Set("quotes", quotes)
+
+	return nil
+}
+
+// renderErrors sends the edit form back with the validation errors
+func (v AnnotationsResource) renderErrors(annotation *models.Annotation, verrs *validate.Errors, c buffalo.Context) error {
+	if err := v.loadForm(annotation, c); err != nil {
+		return errors.WithStack(err)
+	}
+
+	// set the verification errors into the context and send back the annotation
+	c.Set("errors", verrs)
+
+	return c.Render(422, r.Auto(c, annotation))
+}
@@ -0,0 +1,41 @@
+package actions
+
+import (
+	"time"
+
+	"github.com/navionguy/cloudquotes/models"
+)
+
+func (as *ActionSuite) Test_Annotations_Edit_NotFound() {
+	res := as.HTML("/annotations/563cd207-ab16-4a46-b44e-7317b96c6ba9/edit").Get()
+	as.Equal(404, res.Code)
+}
+
+// quotes in the trash don't keep an annotation from being destroyed
+func (as *ActionSuite) Test_Annotations_Destroy_TrashedQuote() {
+	u := as.signIn()
+
+	archive, err := models.DefaultArchive(as.DB)
+	as.NoError(err)
+
+	verrs, err := archive.SetMember(as.DB, u.ID, models.RoleMember)
+	as.NoError(err)
+	as.False(verrs.HasAny())
+
+	annotation := &models.Annotation{Note: "Said in the trash", ArchiveID: archive.ID}
+	as.NoError(as.DB.Create(annotation))
+
+	now := time.Now()
+	cv := &models.Conversation{ArchiveID: archive.ID, OccurredOn: now, Status: models.StatusDraft, DeletedAt: &now}
+	as.NoError(as.DB.Create(cv))
+
+	quote := &models.Quote{ConversationID: cv.ID, SaidOn: now, Phrase: "Nobody will see this.", AnnotationID: &annotation.ID, DeletedAt: &now}
+	as.NoError(as.DB.Create(quote))
+
+	res := as.HTML("/annotations/%s", annotation.ID).Delete()
+	as.Equal(302, res.Code)
+	as.Equal("/annotations", res.Location())
+
+	as.NoError(as.DB.Find(quote, quote.ID))
+	as.Nil(quote.AnnotationID)
+}
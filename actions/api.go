@@ -0,0 +1,290 @@
+package actions
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/buffalo/render"
+	"github.com/gobuffalo/pop/v5"
+	"github.com/gobuffalo/validate/v3"
+	"github.com/gofrs/uuid"
+	"github.com/navionguy/cloudquotes/models"
+	"github.com/pkg/errors"
+)
+
+// apiPageSize is how many items a page of the API holds unless the
+// "limit" param asks for something else, apiMaxPageSize is as big as a
+// page gets
+const (
+	apiPageSize    = 20
+	apiMaxPageSize = 100
+)
+
+// apiProblem is an error from the API, laid out the way RFC 7807 says
+// so clients can handle every error the same way.  Errors holds the
+// validation errors, by field, when a request was turned down.
+type apiProblem struct {
+	Type   string              `json:"type"`
+	Title  string              `json:"title"`
+	Status int                 `json:"status"`
+	Detail string              `json:"detail,omitempty"`
+	Errors map[string][]string `json:"errors,omitempty"`
+}
+
+// apiPage is one page of a list from the API.  NextCursor is passed
+// back as the "cursor" param to get the page after it, it is left off
+// the last page.
+type apiPage struct {
+	Data       interface{} `json:"data"`
+	NextCursor string      `json:"next_cursor,omitempty"`
+}
+
+// renderProblem sends the problem back as application/problem+json
+func renderProblem(c buffalo.Context, p apiProblem) error {
+	if len(p.Type) == 0 {
+		p.Type = "about:blank"
+	}
+
+	if len(p.Title) == 0 {
+		p.Title = http.StatusText(p.Status)
+	}
+
+	return c.Render(p.Status, r.Func("application/problem+json", func(w io.Writer, d render.Data) error {
+		return json.NewEncoder(w).Encode(p)
+	}))
+}
+
+// apiInvalid turns the validation errors into a 422 problem
+func apiInvalid(c buffalo.Context, verrs *validate.Errors) error {
+	return renderProblem(c, apiProblem{Status: 422, Detail: "the request didn't pass validation", Errors: verrs.Errors})
+}
+
+// APIErrors sends back any error from an API handler as a problem,
+// instead of the HTML error pages the rest of the site gets.  What went
+// wrong inside a 500 is logged rather than handed out.
+func APIErrors(next buffalo.Handler) buffalo.Handler {
+	return func(c buffalo.Context) error {
+		err := next(c)
+
+		if err == nil {
+			return nil
+		}
+
+		p := apiProblem{Status: 500}
+
+		if herr, ok := errors.Cause(err).(buffalo.HTTPError); ok {
+			p.Status = herr.Status
+
+			if herr.Cause != nil {
+				p.Detail = herr.Cause.Error()
+			}
+		}
+
+		if p.Status >= 500 {
+			c.Logger().Error(err)
+			p.Detail = ""
+		}
+
+		return renderProblem(c, p)
+	}
+}
+
+// APIAuthorize signs the request in as the user the bearer token in its
+// Authorization header belongs to.  The API has no sessions, so every
+// request needs a token.
+func APIAuthorize(next buffalo.Handler) buffalo.Handler {
+	return func(c buffalo.Context) error {
+		tx, ok := c.Value("tx").(*pop.Connection)
+		if !ok {
+			return errors.WithStack(errors.New("no transaction found"))
+		}
+
+		unauthorized := func(detail string) error {
+			c.Response().Header().Set("WWW-Authenticate", `Bearer realm="cloudquotes"`)
+			return renderProblem(c, apiProblem{Status: 401, Detail: detail})
+		}
+
+		auth := c.Request().Header.Get("Authorization")
+
+		if !strings.HasPrefix(auth, "Bearer ") {
+			return unauthorized("a bearer token is needed")
+		}
+
+		token, err := models.FindAPIToken(tx, strings.TrimSpace(strings.TrimPrefix(auth, "Bearer ")))
+
+		if err == models.ErrBadAPIToken {
+			return unauthorized(err.Error())
+		}
+
+		if err != nil {
+			return errors.WithStack(err)
+		}
+
+		u := &models.User{}
+
+		if err = tx.Find(u, token.UserID); err != nil {
+			return errors.WithStack(err)
+		}
+
+		c.Set("current_user", u)
+		c.Set("api_token", token)
+
+		return next(c)
+	}
+}
+
+// APIScopes turns away requests the token wasn't given the scope for.
+// Reading needs the read scope, anything that makes a change needs the
+// write scope.
+func APIScopes(next buffalo.Handler) buffalo.Handler {
+	return func(c buffalo.Context) error {
+		token, ok := c.Value("api_token").(*models.APIToken)
+		if !ok {
+			return errors.WithStack(errors.New("no API token found"))
+		}
+
+		scope := models.ScopeWrite
+
+		switch c.Request().Method {
+		case "GET", "HEAD", "OPTIONS":
+			scope = models.ScopeRead
+		}
+
+		if !token.HasScope(scope) {
+			return renderProblem(c, apiProblem{Status: 403, Detail: "this token doesn't have the " + scope + " scope"})
+		}
+
+		return next(c)
+	}
+}
+
+// SetAPIArchive works out which archive an API request is looking at.
+// The "archive" param picks one of the user's archives by slug or ID,
+// otherwise it is the first one they belong to, or the default archive
+// if they don't belong to any.
+func SetAPIArchive(next buffalo.Handler) buffalo.Handler {
+	return func(c buffalo.Context) error {
+		tx, ok := c.Value("tx").(*pop.Connection)
+		if !ok {
+			return errors.WithStack(errors.New("no transaction found"))
+		}
+
+		archives, err := models.UserArchives(tx, currentUserID(c))
+
+		if err != nil {
+			return errors.WithStack(err)
+		}
+
+		var archive *models.Archive
+
+		if want := c.Param("archive"); len(want) > 0 {
+			for i := range archives {
+				if archives[i].Slug == want || archives[i].ID.String() == want {
+					archive = &archives[i]
+				}
+			}
+
+			if archive == nil {
+				return c.Error(404, errors.New("no archive "+want+" for this user"))
+			}
+		}
+
+		if archive == nil && len(archives) > 0 {
+			archive = &archives[0]
+		}
+
+		if archive == nil {
+			if archive, err = models.DefaultArchive(tx); err != nil {
+				return errors.WithStack(err)
+			}
+		}
+
+		c.Set("current_archive", archive)
+
+		return next(c)
+	}
+}
+
+// encodeCursor marks the place in a list just after the item created at
+// createdAt with the passed ID
+func encodeCursor(createdAt time.Time, id uuid.UUID) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(createdAt.UTC().Format(time.RFC3339Nano) + "|" + id.String()))
+}
+
+// decodeCursor reads back a cursor made by encodeCursor
+func decodeCursor(cursor string) (time.Time, uuid.UUID, error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+
+	if err != nil {
+		return time.Time{}, uuid.Nil, err
+	}
+
+	parts := strings.SplitN(string(b), "|", 2)
+
+	if len(parts) != 2 {
+		return time.Time{}, uuid.Nil, errors.New("malformed cursor")
+	}
+
+	createdAt, err := time.Parse(time.RFC3339Nano, parts[0])
+
+	if err != nil {
+		return time.Time{}, uuid.Nil, err
+	}
+
+	id, err := uuid.FromString(parts[1])
+
+	return createdAt, id, err
+}
+
+// apiPaginate narrows the query down to the page after the "cursor"
+// param.  Lists come back oldest first, by when they were created, so
+// a page doesn't shift when something new is added.  One more item than
+// the page holds is asked for, apiNextCursor uses it to tell if there
+// is another page.
+func apiPaginate(c buffalo.Context, q *pop.Query, table string) (*pop.Query, int, error) {
+	limit := apiPageSize
+
+	if l := c.Param("limit"); len(l) > 0 {
+		n, err := strconv.Atoi(l)
+
+		if err != nil || n < 1 {
+			return nil, 0, c.Error(400, errors.New("limit has to be a positive number"))
+		}
+
+		limit = n
+	}
+
+	if limit > apiMaxPageSize {
+		limit = apiMaxPageSize
+	}
+
+	if cursor := c.Param("cursor"); len(cursor) > 0 {
+		createdAt, id, err := decodeCursor(cursor)
+
+		if err != nil {
+			return nil, 0, c.Error(400, errors.New("cursor isn't one handed out by this API"))
+		}
+
+		q = q.Where("("+table+".created_at, "+table+".id) > (?, ?)", createdAt, id)
+	}
+
+	return q.Order(table + ".created_at ASC, " + table + ".id ASC").Limit(limit + 1), limit, nil
+}
+
+// apiNextCursor works out the cursor for the page after this one, or
+// an empty string if this is the last page.  found is how many items
+// the query came back with, last gives the created time and ID of the
+// last item that fits on the page.
+func apiNextCursor(found int, limit int, last func(i int) (time.Time, uuid.UUID)) string {
+	if found <= limit {
+		return ""
+	}
+
+	return encodeCursor(last(limit - 1))
+}
@@ -0,0 +1,192 @@
+package actions
+
+import (
+	"time"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/pop/v5"
+	"github.com/gofrs/uuid"
+	"github.com/navionguy/cloudquotes/models"
+	"github.com/pkg/errors"
+)
+
+// apiAuthor is an author as the API hands it out
+type apiAuthor struct {
+	ID         uuid.UUID `json:"id"`
+	Name       string    `json:"name"`
+	Team       string    `json:"team,omitempty"`
+	Bio        string    `json:"bio,omitempty"`
+	Visibility string    `json:"visibility"`
+	Aliases    []string  `json:"aliases"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// apiAnnotation is an annotation as the API hands it out
+type apiAnnotation struct {
+	ID        uuid.UUID `json:"id"`
+	Note      string    `json:"note"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// newAPIAuthor lays the author out for the API
+func newAPIAuthor(a models.Author) apiAuthor {
+	aa := apiAuthor{
+		ID:         a.ID,
+		Name:       a.Name,
+		Team:       a.Team,
+		Bio:        a.Bio,
+		Visibility: a.Visibility,
+		Aliases:    []string{},
+		CreatedAt:  a.CreatedAt,
+		UpdatedAt:  a.UpdatedAt,
+	}
+
+	for _, al := range a.Aliases {
+		aa.Aliases = append(aa.Aliases, al.Name)
+	}
+
+	return aa
+}
+
+// newAPIAnnotation lays the annotation out for the API
+func newAPIAnnotation(a models.Annotation) apiAnnotation {
+	return apiAnnotation{ID: a.ID, Note: a.Note, CreatedAt: a.CreatedAt, UpdatedAt: a.UpdatedAt}
+}
+
+// apiAuthorsQuery finds the authors in the archive the signed in user
+// may see.  Like the author pages, only authors shown in full are there
+// for anyone but the editors.
+func apiAuthorsQuery(c buffalo.Context) (*pop.Query, error) {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return nil, errors.WithStack(errors.New("no transaction found"))
+	}
+
+	archive := currentArchive(c).ID
+	q := tx.Eager("Aliases").Where("authors.archive_id = ?", archive)
+
+	editor, err := seesRealNames(c, archive)
+
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	if !editor {
+		q = q.Where("authors.visibility = ?", models.VisibilityFull)
+	}
+
+	return q, nil
+}
+
+// APIAuthorsList lists the authors in the archive, a page at a time.
+// This function is mapped to the path GET /api/v1/authors
+func APIAuthorsList(c buffalo.Context) error {
+	q, err := apiAuthorsQuery(c)
+
+	if err != nil {
+		return err
+	}
+
+	q, limit, err := apiPaginate(c, q, "authors")
+
+	if err != nil {
+		return err
+	}
+
+	authors := models.Authors{}
+
+	if err = q.All(&authors); err != nil {
+		return errors.WithStack(err)
+	}
+
+	next := apiNextCursor(len(authors), limit, func(i int) (time.Time, uuid.UUID) {
+		return authors[i].CreatedAt, authors[i].ID
+	})
+
+	if len(authors) > limit {
+		authors = authors[:limit]
+	}
+
+	data := []apiAuthor{}
+	for _, a := range authors {
+		data = append(data, newAPIAuthor(a))
+	}
+
+	return c.Render(200, r.JSON(apiPage{Data: data, NextCursor: next}))
+}
+
+// APIAuthorsShow gets one author.  This function is mapped to the path
+// GET /api/v1/authors/{author_id}
+func APIAuthorsShow(c buffalo.Context) error {
+	q, err := apiAuthorsQuery(c)
+
+	if err != nil {
+		return err
+	}
+
+	author := models.Author{}
+
+	if err = q.Find(&author, c.Param("author_id")); err != nil {
+		return c.Error(404, err)
+	}
+
+	return c.Render(200, r.JSON(newAPIAuthor(author)))
+}
+
+// APIAnnotationsList lists the annotations in the archive, a page at a
+// time.  This function is mapped to the path GET /api/v1/annotations
+func APIAnnotationsList(c buffalo.Context) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	q, limit, err := apiPaginate(c, tx.Where("annotations.archive_id = ?", currentArchive(c).ID), "annotations")
+
+	if err != nil {
+		return err
+	}
+
+	annotations := []models.Annotation{}
+
+	if err = q.All(&annotations); err != nil {
+		return errors.WithStack(err)
+	}
+
+	next := apiNextCursor(len(annotations), limit, func(i int) (time.Time, uuid.UUID) {
+		return annotations[i].CreatedAt, annotations[i].ID
+	})
+
+	if len(annotations) > limit {
+		annotations = annotations[:limit]
+	}
+
+	data := []apiAnnotation{}
+	for _, a := range annotations {
+		data = append(data, newAPIAnnotation(a))
+	}
+
+	return c.Render(200, r.JSON(apiPage{Data: data, NextCursor: next}))
+}
+
+// APIAnnotationsShow gets one annotation.  This function is mapped to
+// the path GET /api/v1/annotations/{annotation_id}
+func APIAnnotationsShow(c buffalo.Context) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	annotation := models.Annotation{}
+
+	if err := tx.Where("archive_id = ?", currentArchive(c).ID).Find(&annotation, c.Param("annotation_id")); err != nil {
+		return c.Error(404, err)
+	}
+
+	return c.Render(200, r.JSON(newAPIAnnotation(annotation)))
+}
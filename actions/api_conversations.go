@@ -0,0 +1,458 @@
+package actions
+
+import (
+	"strings"
+	"time"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/pop/v5"
+	"github.com/gobuffalo/validate/v3"
+	"github.com/gofrs/uuid"
+	"github.com/navionguy/cloudquotes/models"
+	"github.com/pkg/errors"
+)
+
+// apiDateLayout is how the API writes dates, partial dates come with a
+// precision saying how much of the date is real
+const apiDateLayout = "2006-01-02"
+
+// apiSpeaker is somebody who said a line.  ID is left off for authors
+// who asked not to be named.
+type apiSpeaker struct {
+	ID   *uuid.UUID `json:"id,omitempty"`
+	Name string     `json:"name"`
+}
+
+// apiQuote is one line of a conversation, as the API hands it out.
+// Speakers is empty for narrator lines.
+type apiQuote struct {
+	ID             uuid.UUID    `json:"id"`
+	ConversationID uuid.UUID    `json:"conversation_id"`
+	Sequence       int          `json:"sequence"`
+	Phrase         string       `json:"phrase"`
+	SaidOn         string       `json:"said_on"`
+	SaidPrecision  string       `json:"said_precision"`
+	Publish        bool         `json:"publish"`
+	Narration      bool         `json:"narration"`
+	Speakers       []apiSpeaker `json:"speakers"`
+	Annotation     string       `json:"annotation,omitempty"`
+	CreatedAt      time.Time    `json:"created_at"`
+	UpdatedAt      time.Time    `json:"updated_at"`
+}
+
+// apiConversation is a conversation as the API hands it out
+type apiConversation struct {
+	ID                uuid.UUID  `json:"id"`
+	OccurredOn        string     `json:"occurred_on"`
+	OccurredPrecision string     `json:"occurred_precision"`
+	Status            string     `json:"status"`
+	Meeting           string     `json:"meeting,omitempty"`
+	Location          string     `json:"location,omitempty"`
+	Channel           string     `json:"channel,omitempty"`
+	Setup             string     `json:"setup,omitempty"`
+	Source            string     `json:"source,omitempty"`
+	Tags              []string   `json:"tags"`
+	Quotes            []apiQuote `json:"quotes"`
+	CreatedAt         time.Time  `json:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at"`
+}
+
+// apiQuoteRequest is one line of a conversation being added through
+// the API.  SaidOn takes any date the seed loader understands, and
+// falls back to when the conversation happened.  Leaving off AuthorID
+// makes it a narrator line.
+type apiQuoteRequest struct {
+	Phrase      string      `json:"phrase"`
+	SaidOn      string      `json:"said_on"`
+	AuthorID    *uuid.UUID  `json:"author_id"`
+	CoAuthorIDs []uuid.UUID `json:"co_author_ids"`
+	Publish     bool        `json:"publish"`
+	Annotation  string      `json:"annotation"`
+}
+
+// apiConversationRequest is a conversation being added through the API
+type apiConversationRequest struct {
+	OccurredOn string            `json:"occurred_on"`
+	Meeting    string            `json:"meeting"`
+	Location   string            `json:"location"`
+	Channel    string            `json:"channel"`
+	Setup      string            `json:"setup"`
+	Source     string            `json:"source"`
+	Tags       []string          `json:"tags"`
+	Quotes     []apiQuoteRequest `json:"quotes"`
+}
+
+// newAPISpeaker leaves the ID off masked authors, it would lead back to
+// who they are
+func newAPISpeaker(a models.Author) apiSpeaker {
+	s := apiSpeaker{Name: a.Name}
+
+	if !a.Masked {
+		id := a.ID
+		s.ID = &id
+	}
+
+	return s
+}
+
+// newAPIQuote lays the quote out for the API
+func newAPIQuote(q models.Quote) apiQuote {
+	aq := apiQuote{
+		ID:             q.ID,
+		ConversationID: q.ConversationID,
+		Sequence:       q.Sequence,
+		Phrase:         q.Phrase,
+		SaidOn:         q.SaidOn.Format(apiDateLayout),
+		SaidPrecision:  q.SaidPrecision,
+		Publish:        q.Publish,
+		Narration:      q.Narration(),
+		Speakers:       []apiSpeaker{},
+		CreatedAt:      q.CreatedAt,
+		UpdatedAt:      q.UpdatedAt,
+	}
+
+	for _, a := range q.Speakers() {
+		aq.Speakers = append(aq.Speakers, newAPISpeaker(a))
+	}
+
+	if q.Annotation != nil {
+		aq.Annotation = q.Annotation.Note
+	}
+
+	return aq
+}
+
+// newAPIConversation lays the conversation out for the API
+func newAPIConversation(cv models.Conversation) apiConversation {
+	ac := apiConversation{
+		ID:                cv.ID,
+		OccurredOn:        cv.OccurredOn.Format(apiDateLayout),
+		OccurredPrecision: cv.OccurredPrecision,
+		Status:            cv.Status,
+		Meeting:           cv.Meeting,
+		Location:          cv.Location,
+		Channel:           cv.Channel,
+		Setup:             cv.Setup,
+		Source:            cv.Source,
+		Tags:              []string{},
+		Quotes:            []apiQuote{},
+		CreatedAt:         cv.CreatedAt,
+		UpdatedAt:         cv.UpdatedAt,
+	}
+
+	for _, t := range cv.Tags {
+		ac.Tags = append(ac.Tags, t.Name)
+	}
+
+	for _, q := range cv.Quotes {
+		ac.Quotes = append(ac.Quotes, newAPIQuote(q))
+	}
+
+	return ac
+}
+
+// conversation builds the conversation the request asks for in the
+// archive.  Dates that can't be read go into verrs.
+func (req apiConversationRequest) conversation(archive uuid.UUID, verrs *validate.Errors) *models.Conversation {
+	conv := &models.Conversation{
+		ArchiveID: archive,
+		Meeting:   req.Meeting,
+		Location:  req.Location,
+		Channel:   req.Channel,
+		Setup:     req.Setup,
+		Source:    req.Source,
+		Tags:      models.ParseTags(strings.Join(req.Tags, ",")),
+	}
+
+	var err error
+
+	if conv.OccurredOn, conv.OccurredPrecision, err = models.ParseDate(req.OccurredOn); err != nil {
+		verrs.Add("occurred_on", err.Error())
+	}
+
+	if len(req.Quotes) == 0 {
+		verrs.Add("quotes", "a conversation needs at least one quote")
+	}
+
+	for _, rq := range req.Quotes {
+		q := models.Quote{
+			Phrase:        rq.Phrase,
+			SaidOn:        conv.OccurredOn,
+			SaidPrecision: conv.OccurredPrecision,
+			Publish:       rq.Publish,
+			AuthorID:      rq.AuthorID,
+		}
+
+		if len(rq.SaidOn) > 0 {
+			if q.SaidOn, q.SaidPrecision, err = models.ParseDate(rq.SaidOn); err != nil {
+				verrs.Add("said_on", err.Error())
+			}
+		}
+
+		for _, id := range rq.CoAuthorIDs {
+			q.CoAuthors = append(q.CoAuthors, models.Author{ID: id})
+		}
+
+		if len(strings.TrimSpace(rq.Annotation)) > 0 {
+			q.Annotation = &models.Annotation{Note: rq.Annotation}
+		}
+
+		conv.Quotes = append(conv.Quotes, q)
+	}
+
+	return conv
+}
+
+// APIConversationsList lists the conversations in the archive, a page
+// at a time.  The "status", "tag" and "author" params narrow it down.
+// This function is mapped to the path GET /api/v1/conversations
+func APIConversationsList(c buffalo.Context) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	archive := currentArchive(c).ID
+
+	q := tx.Eager("Quotes").Eager("Quotes.Author").Eager("Quotes.CoAuthors").Eager("Quotes.Annotation").Eager("Tags").Where("conversations.deleted_at IS NULL AND conversations.archive_id = ?", archive)
+
+	editor, err := seesRealNames(c, archive)
+
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	// like the site, only the editors see what hasn't been published
+	if !editor {
+		q = q.Where("conversations.status = ?", models.StatusPublished)
+	}
+
+	if status := c.Param("status"); len(status) > 0 {
+		q = q.Where("conversations.status = ?", status)
+	}
+
+	if tag := c.Param("tag"); len(tag) > 0 {
+		q = models.FilterByTag(q, tag)
+	}
+
+	if param := c.Param("author"); len(param) > 0 {
+		author, err := uuid.FromString(param)
+
+		if err != nil {
+			return c.Error(400, errors.New("author has to be an author's ID"))
+		}
+
+		q = q.Where("conversations.id IN (SELECT conversation_id FROM quotes WHERE deleted_at IS NULL AND (author_id = ? OR id IN (SELECT quote_id FROM quote_authors WHERE author_id = ?)))", author, author)
+	}
+
+	q, limit, err := apiPaginate(c, q, "conversations")
+
+	if err != nil {
+		return err
+	}
+
+	conversations := models.Conversations{}
+
+	if err = q.All(&conversations); err != nil {
+		return errors.WithStack(err)
+	}
+
+	conversations.DropRemovedQuotes()
+
+	next := apiNextCursor(len(conversations), limit, func(i int) (time.Time, uuid.UUID) {
+		return conversations[i].CreatedAt, conversations[i].ID
+	})
+
+	if len(conversations) > limit {
+		conversations = conversations[:limit]
+	}
+
+	// masking can leave a page short, the cursor still picks up after it
+	conversations, err = maskAuthors(c, archive, conversations)
+
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	data := []apiConversation{}
+	for _, cv := range conversations {
+		data = append(data, newAPIConversation(cv))
+	}
+
+	return c.Render(200, r.JSON(apiPage{Data: data, NextCursor: next}))
+}
+
+// APIConversationsShow gets one conversation.  This function is mapped
+// to the path GET /api/v1/conversations/{conversation_id}
+func APIConversationsShow(c buffalo.Context) error {
+	archive := currentArchive(c).ID
+
+	conversation, err := ConversationsResource{}.findConversation(c, archive, c.Param("conversation_id"))
+
+	if err != nil {
+		return err
+	}
+
+	editor, err := seesRealNames(c, archive)
+
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	if !editor && conversation.Status != models.StatusPublished {
+		return c.Error(404, errors.New("conversation not found"))
+	}
+
+	shown, err := maskAuthors(c, archive, models.Conversations{*conversation})
+
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	// every line in it is unpublished, or by somebody who asked to be hidden
+	if len(shown) == 0 {
+		return c.Error(404, errors.New("conversation not found"))
+	}
+
+	return c.Render(200, r.JSON(newAPIConversation(shown[0])))
+}
+
+// APIConversationsCreate adds a conversation to the archive.  Like one
+// added through the site, it starts out as a draft waiting for review.
+// This function is mapped to the path POST /api/v1/conversations
+func APIConversationsCreate(c buffalo.Context) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	req := apiConversationRequest{}
+
+	if err := c.Bind(&req); err != nil {
+		return c.Error(400, errors.New("the body has to be a JSON conversation"))
+	}
+
+	archive := currentArchive(c).ID
+	verrs := validate.NewErrors()
+	conv := req.conversation(archive, verrs)
+
+	if verrs.HasAny() {
+		return apiInvalid(c, verrs)
+	}
+
+	verrs, err := conv.Create(tx, currentUserID(c))
+
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	if verrs.HasAny() {
+		return apiInvalid(c, verrs)
+	}
+
+	created, err := ConversationsResource{}.findConversation(c, archive, conv.ID.String())
+
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	c.Response().Header().Set("Location", "/api/v1/conversations/"+conv.ID.String())
+
+	return c.Render(201, r.JSON(newAPIConversation(*created)))
+}
+
+// APIQuotesList lists every line in the archive, a page at a time.
+// This function is mapped to the path GET /api/v1/quotes
+func APIQuotesList(c buffalo.Context) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	archive := currentArchive(c).ID
+
+	q := tx.Eager("Author", "CoAuthors", "Annotation").Where("quotes.deleted_at IS NULL AND quotes.conversation_id IN (SELECT id FROM conversations WHERE deleted_at IS NULL AND archive_id = ?)", archive)
+
+	editor, err := seesRealNames(c, archive)
+
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	if !editor {
+		q = q.Where("quotes.publish = ? AND quotes.conversation_id IN (SELECT id FROM conversations WHERE status = ?)", true, models.StatusPublished)
+	}
+
+	q, limit, err := apiPaginate(c, q, "quotes")
+
+	if err != nil {
+		return err
+	}
+
+	quotes := models.Quotes{}
+
+	if err = q.All(&quotes); err != nil {
+		return errors.WithStack(err)
+	}
+
+	next := apiNextCursor(len(quotes), limit, func(i int) (time.Time, uuid.UUID) {
+		return quotes[i].CreatedAt, quotes[i].ID
+	})
+
+	if len(quotes) > limit {
+		quotes = quotes[:limit]
+	}
+
+	data := []apiQuote{}
+	for _, qt := range quotes {
+		if !editor {
+			if qt, ok = qt.MaskAuthors(); !ok {
+				continue
+			}
+		}
+
+		data = append(data, newAPIQuote(qt))
+	}
+
+	return c.Render(200, r.JSON(apiPage{Data: data, NextCursor: next}))
+}
+
+// APIQuotesShow gets one line.  This function is mapped to the path
+// GET /api/v1/quotes/{quote_id}
+func APIQuotesShow(c buffalo.Context) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	archive := currentArchive(c).ID
+	quote := models.Quote{}
+
+	editor, err := seesRealNames(c, archive)
+
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	q := tx.Eager("Author", "CoAuthors", "Annotation").Where("deleted_at IS NULL AND conversation_id IN (SELECT id FROM conversations WHERE deleted_at IS NULL AND archive_id = ?)", archive)
+
+	if !editor {
+		q = q.Where("publish = ? AND conversation_id IN (SELECT id FROM conversations WHERE status = ?)", true, models.StatusPublished)
+	}
+
+	if err = q.Find(&quote, c.Param("quote_id")); err != nil {
+		return c.Error(404, err)
+	}
+
+	if !editor {
+		if quote, ok = quote.MaskAuthors(); !ok {
+			return c.Error(404, errors.New("quote not found"))
+		}
+	}
+
+	return c.Render(200, r.JSON(newAPIQuote(quote)))
+}
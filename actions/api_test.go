@@ -0,0 +1,109 @@
+package actions
+
+import (
+	"time"
+
+	"github.com/gobuffalo/httptest"
+	"github.com/gofrs/uuid"
+	"github.com/navionguy/cloudquotes/models"
+)
+
+func (as *ActionSuite) Test_API_NoToken() {
+	res := as.JSON("/api/v1/conversations").Get()
+	as.Equal(401, res.Code)
+	as.Contains(res.Header().Get("Content-Type"), "application/problem+json")
+	as.Contains(res.Header().Get("WWW-Authenticate"), "Bearer")
+}
+
+func (as *ActionSuite) Test_API_BadToken() {
+	req := as.JSON("/api/v1/authors")
+	req.Headers["Authorization"] = "Bearer cq_notarealtoken"
+	res := req.Get()
+	as.Equal(401, res.Code)
+}
+
+// apiToken issues a read token to a new user who isn't a member of any
+// archive
+func (as *ActionSuite) apiToken() string {
+	u := as.signIn()
+
+	token := &models.APIToken{UserID: u.ID, Name: "test", Scopes: models.ScopeRead}
+	secret, verrs, err := token.Issue(as.DB)
+	as.NoError(err)
+	as.False(verrs.HasAny())
+
+	return secret
+}
+
+// apiGet makes an API request with the token
+func (as *ActionSuite) apiGet(secret string, u string, args ...interface{}) *httptest.JSONResponse {
+	req := as.JSON(u, args...)
+	req.Headers["Authorization"] = "Bearer " + secret
+
+	return req.Get()
+}
+
+// an author that isn't an ID is the caller's mistake, not a server error
+func (as *ActionSuite) Test_API_BadAuthorFilter() {
+	res := as.apiGet(as.apiToken(), "/api/v1/conversations?author=bob")
+	as.Equal(400, res.Code)
+	as.Contains(res.Header().Get("Content-Type"), "application/problem+json")
+}
+
+func (as *ActionSuite) Test_API_Cursor() {
+	at := time.Date(2021, time.January, 20, 12, 0, 0, 12345, time.UTC)
+	id := uuid.Must(uuid.NewV4())
+
+	createdAt, got, err := decodeCursor(encodeCursor(at, id))
+	as.NoError(err)
+	as.True(at.Equal(createdAt))
+	as.Equal(id, got)
+
+	_, _, err = decodeCursor("not a cursor")
+	as.Error(err)
+}
+
+// only what has been published can be read through the API by anyone
+// but the editors
+func (as *ActionSuite) Test_API_Unpublished() {
+	archive, err := models.DefaultArchive(as.DB)
+	as.NoError(err)
+
+	author := &models.Author{Name: "Bob", ArchiveID: archive.ID, Visibility: models.VisibilityFull}
+	as.NoError(as.DB.Create(author))
+
+	draft := &models.Conversation{ArchiveID: archive.ID, OccurredOn: time.Now(), Status: models.StatusDraft}
+	as.NoError(as.DB.Create(draft))
+	as.NoError(as.DB.Create(&models.Quote{ConversationID: draft.ID, AuthorID: &author.ID, SaidOn: time.Now(), Phrase: "Not ready.", Publish: true}))
+
+	published := &models.Conversation{ArchiveID: archive.ID, OccurredOn: time.Now(), Status: models.StatusPublished, Publish: true}
+	as.NoError(as.DB.Create(published))
+	as.NoError(as.DB.Create(&models.Quote{ConversationID: published.ID, AuthorID: &author.ID, SaidOn: time.Now(), Sequence: 0, Phrase: "Ship it.", Publish: true}))
+
+	hidden := &models.Quote{ConversationID: published.ID, AuthorID: &author.ID, SaidOn: time.Now(), Sequence: 1, Phrase: "Off the record.", Publish: false}
+	as.NoError(as.DB.Create(hidden))
+
+	secret := as.apiToken()
+
+	res := as.apiGet(secret, "/api/v1/conversations")
+	as.Equal(200, res.Code)
+	as.Contains(res.Body.String(), "Ship it.")
+	as.NotContains(res.Body.String(), "Not ready.")
+	as.NotContains(res.Body.String(), "Off the record.")
+
+	res = as.apiGet(secret, "/api/v1/conversations/%s", draft.ID)
+	as.Equal(404, res.Code)
+
+	res = as.apiGet(secret, "/api/v1/conversations/%s", published.ID)
+	as.Equal(200, res.Code)
+	as.NotContains(res.Body.String(), "Off the record.")
+
+	res = as.apiGet(secret, "/api/v1/quotes")
+	as.Equal(200, res.Code)
+	as.Contains(res.Body.String(), "Ship it.")
+	as.NotContains(res.Body.String(), "Not ready.")
+	as.NotContains(res.Body.String(), "Off the record.")
+
+	res = as.apiGet(secret, "/api/v1/quotes/%s", hidden.ID)
+	as.Equal(404, res.Code)
+}
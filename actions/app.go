@@ -58,14 +58,124 @@ func App() *buffalo.App {
 		// Setup and use translations:
 		app.Use(translations())
 
+		// find out who is signed in, routes that need someone get wrapped in Authorize
+		app.Use(SetCurrentUser)
+
+		// everything is scoped to the archive the user is looking at
+		app.Use(SetCurrentArchive)
+
 		app.GET("/", HomeHandler)
-		//app.Use(SetCurrentUser)
-		//app.Use(Authorize)
+		app.GET("/qotd", QotdHandler)
+		app.GET("/signin", AuthNew)
+		app.POST("/signin", AuthCreate)
+		app.DELETE("/signout", AuthDestroy)
+		app.GET("/users/new", UsersNew)
+		app.POST("/users", UsersCreate)
+		app.GET("/archives", Authorize(ArchivesIndex))
+		app.POST("/archives", Authorize(ArchivesCreate))
+		app.GET("/archives/{archive_id}", Authorize(ArchivesShow))
+		app.POST("/archives/{archive_id}/switch", Authorize(ArchivesSwitch))
+		app.POST("/archives/{archive_id}/members", Authorize(ArchivesAddMember))
+		app.DELETE("/archives/{archive_id}/members/{user_id}", Authorize(ArchivesRemoveMember))
+		app.GET("/archives/{archive_id}/webhooks", Authorize(WebhooksIndex))
+		app.POST("/archives/{archive_id}/webhooks", Authorize(WebhooksCreate))
+		app.GET("/archives/{archive_id}/webhooks/{webhook_id}", Authorize(WebhooksShow))
+		app.DELETE("/archives/{archive_id}/webhooks/{webhook_id}", Authorize(WebhooksDestroy))
+		app.POST("/archives/{archive_id}/webhooks/{webhook_id}/toggle", Authorize(WebhooksToggle))
+		app.POST("/archives/{archive_id}/webhooks/{webhook_id}/deliveries/{delivery_id}/redeliver", Authorize(WebhooksRedeliver))
+		app.GET("/settings/tokens", Authorize(TokensIndex)).Name("tokens")
+		app.POST("/settings/tokens", Authorize(TokensCreate))
+		app.DELETE("/settings/tokens/{token_id}", Authorize(TokensRevoke)).Name("token")
+		app.GET("/collections", Authorize(CollectionsIndex))
+		app.POST("/collections", Authorize(CollectionsCreate))
+		app.GET("/collections/{collection_id}", Authorize(CollectionsShow))
+		app.PUT("/collections/{collection_id}", Authorize(CollectionsUpdate))
+		app.DELETE("/collections/{collection_id}", Authorize(CollectionsDestroy))
+		app.DELETE("/collections/{collection_id}/entries/{conversation_id}", Authorize(CollectionsRemoveEntry))
+		app.POST("/collections/{collection_id}/entries/{conversation_id}/move", Authorize(CollectionsMoveEntry))
+		app.GET("/shared/{token}", SharedCollection)
+		app.GET("/shared/{token}/feed", SharedCollectionFeed)
+		app.GET("/feeds/conversations.atom", FeedsConversations(feedAtom)).Name("conversationsAtom")
+		app.GET("/feeds/conversations.rss", FeedsConversations(feedRSS)).Name("conversationsRSS")
+		app.GET("/feeds/authors/{author_id}.atom", FeedsAuthor(feedAtom)).Name("authorAtom")
+		app.GET("/feeds/authors/{author_id}.rss", FeedsAuthor(feedRSS)).Name("authorRSS")
+		app.GET("/feeds/tags/{tag}.atom", FeedsTag(feedAtom)).Name("tagAtom")
+		app.GET("/feeds/tags/{tag}.rss", FeedsTag(feedRSS)).Name("tagRSS")
+		app.GET("/review", Authorize(ReviewIndex))
+		app.GET("/leaderboard", LeaderboardHandler)
 		cv := &ConversationsResource{}
 		app.GET("/conversations/export/", cv.Export) // this is becoming useless and should probably go away
-		app.Resource("/conversations", cv)
-		app.Resource("/authors", &AuthorsResource{})
+		app.POST("/conversations/{conversation_id}/status", Authorize(ArchiveMember(cv.Transition)))
+		app.POST("/conversations/{conversation_id}/vote", Authorize(ArchiveMember(cv.Vote)))
+		app.DELETE("/conversations/{conversation_id}/vote", Authorize(ArchiveMember(cv.Unvote)))
+		app.POST("/conversations/{conversation_id}/star", Authorize(ArchiveMember(CollectionsStar)))
+		app.GET("/conversations/{conversation_id}/history", Authorize(cv.History))
+		app.POST("/conversations/{conversation_id}/history/{revision_id}/revert", Authorize(cv.Revert))
+		cm := CommentsResource{}
+		cmr := app.Resource("/conversations/{conversation_id}/comments", cm)
+		cmr.Use(Authorize, ArchiveMember)
+		cmr.Middleware.Skip(Authorize, cm.List, cm.Show)
+		cmr.Middleware.Skip(ArchiveMember, cm.List, cm.Show)
+
+		// anyone can read the archive they're looking at, only its members
+		// can change it
+		cvr := app.Resource("/conversations", cv)
+		cvr.Use(Authorize, ArchiveMember)
+		cvr.Middleware.Skip(Authorize, cv.List, cv.Show)
+		cvr.Middleware.Skip(ArchiveMember, cv.List, cv.Show)
+		app.GET("/attachments/{attachment_id}", Authorize(AttachmentShow))
+		app.DELETE("/attachments/{attachment_id}", Authorize(ArchiveMember(AttachmentDestroy)))
+		au := &AuthorsResource{}
+		app.POST("/authors/{author_id}/merge", Authorize(au.Merge))
+		app.POST("/authors/{author_id}/optout", Authorize(au.OptOut))
+		app.DELETE("/authors/{author_id}/optout", Authorize(au.OptIn))
+		aur := app.Resource("/authors", au)
+		aur.Use(Authorize, ArchiveMember)
+		aur.Middleware.Skip(Authorize, au.List, au.Show)
+		aur.Middleware.Skip(ArchiveMember, au.List, au.Show)
+		tg := &TagsResource{}
+		tgr := app.Resource("/tags", tg)
+		tgr.Use(Authorize, ArchiveMember)
+		tgr.Middleware.Skip(Authorize, tg.List)
+		tgr.Middleware.Skip(ArchiveMember, tg.List)
+		an := &AnnotationsResource{}
+		anr := app.Resource("/annotations", an)
+		anr.Use(Authorize, ArchiveMember)
+		anr.Middleware.Skip(Authorize, an.List)
+		anr.Middleware.Skip(ArchiveMember, an.List)
+		app.GET("/trash", Authorize(TrashIndex))
+		app.POST("/trash/{conversation_id}/restore", Authorize(TrashRestore))
+		app.DELETE("/trash/{conversation_id}", Authorize(TrashPurge)).Name("trashPurge")
+
+		// the JSON API is for scripts and bots.  They sign in with a bearer
+		// token on every request instead of a session, so there's no CSRF
+		// to guard against, and errors come back as problem JSON.
+		api := app.Group("/api/v1")
+		api.Middleware.Remove(csrf.New, SetCurrentUser, SetCurrentArchive)
+		api.Use(APIErrors, APIAuthorize, APIScopes, SetAPIArchive)
+		api.GET("/conversations", APIConversationsList)
+		api.POST("/conversations", ArchiveMember(APIConversationsCreate))
+		api.GET("/conversations/{conversation_id}", APIConversationsShow)
+		api.GET("/quotes", APIQuotesList)
+		api.GET("/quotes/{quote_id}", APIQuotesShow)
+		api.GET("/authors", APIAuthorsList)
+		api.GET("/authors/{author_id}", APIAuthorsShow)
+		api.GET("/annotations", APIAnnotationsList)
+		api.GET("/annotations/{annotation_id}", APIAnnotationsShow)
+
 		app.ServeFiles("/", assetsBox) // serve files from the public directory
+
+		// webhook deliveries go out and deleted files get removed in the
+		// background, tests do both by hand
+		if ENV != "test" {
+			if err := startWebhookDelivery(app.Worker); err != nil {
+				app.Stop(err)
+			}
+
+			if err := startFileRemoval(app.Worker); err != nil {
+				app.Stop(err)
+			}
+		}
 	}
 
 	return app
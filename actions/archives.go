@@ -0,0 +1,325 @@
+package actions
+
+import (
+	"strings"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/pop/v5"
+	"github.com/gofrs/uuid"
+	"github.com/navionguy/cloudquotes/models"
+	"github.com/pkg/errors"
+)
+
+// SetCurrentArchive works out which archive the request is looking at.
+// Signed in users get the one they last switched to, or the first one
+// they belong to.  Everybody else, including users who don't belong to
+// any archive yet, gets the default archive.  The archives the user can
+// switch between go into the context for the layout.
+func SetCurrentArchive(next buffalo.Handler) buffalo.Handler {
+	return func(c buffalo.Context) error {
+		tx, ok := c.Value("tx").(*pop.Connection)
+		if !ok {
+			return errors.WithStack(errors.New("no transaction found"))
+		}
+
+		archives, err := models.UserArchives(tx, currentUserID(c))
+
+		if err != nil {
+			return errors.WithStack(err)
+		}
+
+		var archive *models.Archive
+
+		if id, ok := c.Session().Get("current_archive_id").(uuid.UUID); ok {
+			for i := range archives {
+				if archives[i].ID == id {
+					archive = &archives[i]
+				}
+			}
+		}
+
+		if archive == nil && len(archives) > 0 {
+			archive = &archives[0]
+		}
+
+		if archive == nil {
+			if archive, err = models.DefaultArchive(tx); err != nil {
+				return errors.WithStack(err)
+			}
+		}
+
+		c.Set("current_archive", archive)
+		c.Set("archives", archives)
+
+		return next(c)
+	}
+}
+
+// currentArchive returns the archive the request is scoped to
+func currentArchive(c buffalo.Context) *models.Archive {
+	if archive, ok := c.Value("current_archive").(*models.Archive); ok {
+		return archive
+	}
+
+	return &models.Archive{}
+}
+
+// requirePermission turns away anyone who doesn't have the named
+// permission in the archive the request is scoped to
+func requirePermission(c buffalo.Context, perm string) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	allowed, err := currentArchive(c).Allows(tx, currentUserID(c), perm)
+
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	if !allowed {
+		return c.Error(403, errors.New("you need to be "+perm+" in this archive to do that"))
+	}
+
+	return nil
+}
+
+// ArchiveMember turns away anyone who doesn't belong to the archive the
+// request is scoped to.  Everybody can read the default archive, only
+// its members get to add to it or change it.
+func ArchiveMember(next buffalo.Handler) buffalo.Handler {
+	return func(c buffalo.Context) error {
+		// Get the DB connection from the context
+		tx, ok := c.Value("tx").(*pop.Connection)
+		if !ok {
+			return errors.WithStack(errors.New("no transaction found"))
+		}
+
+		role, err := currentArchive(c).Role(tx, currentUserID(c))
+
+		if err != nil {
+			return errors.WithStack(err)
+		}
+
+		if role == "" {
+			return c.Error(403, errors.New("you need to be a member of this archive to do that"))
+		}
+
+		return next(c)
+	}
+}
+
+// ArchivesIndex lists the archives the signed in user belongs to, along
+// with the form for starting a new one.  This function is mapped to the
+// path GET /archives
+func ArchivesIndex(c buffalo.Context) error {
+	c.Set("archive", &models.Archive{})
+
+	return c.Render(200, r.HTML("archives/index.html"))
+}
+
+// ArchivesCreate starts a new archive with the signed in user as its
+// admin, and switches over to it.  This function is mapped to the path
+// POST /archives
+func ArchivesCreate(c buffalo.Context) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	archive := &models.Archive{}
+
+	if err := c.Bind(archive); err != nil {
+		return errors.WithStack(err)
+	}
+
+	archive.ID = uuid.Nil
+	archive.Name = strings.TrimSpace(archive.Name)
+	archive.Slug = strings.ToLower(strings.TrimSpace(archive.Slug))
+
+	verrs, err := archive.Create(tx, currentUserID(c))
+
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	if verrs.HasAny() {
+		// set the verification errors into the context and send back the archive
+		c.Set("archive", archive)
+		c.Set("errors", verrs)
+
+		return c.Render(422, r.HTML("archives/index.html"))
+	}
+
+	c.Session().Set("current_archive_id", archive.ID)
+	c.Flash().Add("success", "Archive was created successfully")
+
+	return c.Redirect(302, "/archives/%s", archive.ID)
+}
+
+// ArchivesShow lists everyone in an archive.  Only members get to see
+// who else is in it.  This function is mapped to the path
+// GET /archives/{archive_id}
+func ArchivesShow(c buffalo.Context) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	archive, role, err := loadMemberArchive(c)
+
+	if err != nil {
+		return c.Error(404, err)
+	}
+
+	members, err := archive.Members(tx)
+
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	c.Set("archive", archive)
+	c.Set("members", members)
+	c.Set("admin", role == models.RoleAdmin)
+	c.Set("roles", models.Roles)
+
+	return c.Render(200, r.HTML("archives/show.html"))
+}
+
+// ArchivesSwitch makes another of the user's archives the current one.
+// This function is mapped to the path POST /archives/{archive_id}/switch
+func ArchivesSwitch(c buffalo.Context) error {
+	archive, _, err := loadMemberArchive(c)
+
+	if err != nil {
+		return c.Error(404, err)
+	}
+
+	c.Session().Set("current_archive_id", archive.ID)
+
+	return c.Redirect(302, "/conversations")
+}
+
+// ArchivesAddMember adds a user to the archive by email, or changes the
+// role of someone already in it.  Only the archive's admins can.  This
+// function is mapped to the path POST /archives/{archive_id}/members
+func ArchivesAddMember(c buffalo.Context) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	archive, role, err := loadMemberArchive(c)
+
+	if err != nil {
+		return c.Error(404, err)
+	}
+
+	if role != models.RoleAdmin {
+		return c.Error(403, errors.New("only an archive's admins can change who belongs to it"))
+	}
+
+	u := &models.User{}
+
+	if err := tx.Where("email = ?", strings.ToLower(strings.TrimSpace(c.Param("email")))).First(u); err != nil {
+		c.Flash().Add("danger", "There is no account for that email.")
+		return c.Redirect(302, "/archives/%s", archive.ID)
+	}
+
+	if u.ID == currentUserID(c) {
+		c.Flash().Add("danger", "You can't change your own role.")
+		return c.Redirect(302, "/archives/%s", archive.ID)
+	}
+
+	verrs, err := archive.SetMember(tx, u.ID, c.Param("role"))
+
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	if verrs.HasAny() {
+		c.Flash().Add("danger", verrs.Error())
+	} else {
+		c.Flash().Add("success", u.Email+" is now "+c.Param("role"))
+	}
+
+	return c.Redirect(302, "/archives/%s", archive.ID)
+}
+
+// ArchivesRemoveMember takes a user out of the archive.  Admins can
+// remove anybody but themselves, and anybody can leave.  This function
+// is mapped to the path DELETE /archives/{archive_id}/members/{user_id}
+func ArchivesRemoveMember(c buffalo.Context) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	archive, role, err := loadMemberArchive(c)
+
+	if err != nil {
+		return c.Error(404, err)
+	}
+
+	user, err := uuid.FromString(c.Param("user_id"))
+
+	if err != nil {
+		return c.Error(400, err)
+	}
+
+	self := user == currentUserID(c)
+
+	// an admin leaving could leave the archive with nobody to run it
+	if (self && role == models.RoleAdmin) || (!self && role != models.RoleAdmin) {
+		return c.Error(403, errors.New("admins can't leave their own archive, and only admins can remove someone else"))
+	}
+
+	if err = archive.RemoveMember(tx, user); err != nil {
+		return errors.WithStack(err)
+	}
+
+	if self {
+		c.Session().Delete("current_archive_id")
+		c.Flash().Add("success", "You have left "+archive.Name)
+
+		return c.Redirect(302, "/archives")
+	}
+
+	c.Flash().Add("success", "Member was removed successfully")
+
+	return c.Redirect(302, "/archives/%s", archive.ID)
+}
+
+// loadMemberArchive finds the archive named by the archive_id parameter,
+// as long as the signed in user belongs to it, along with their role.
+func loadMemberArchive(c buffalo.Context) (*models.Archive, string, error) {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return nil, "", errors.WithStack(errors.New("no transaction found"))
+	}
+
+	archive := &models.Archive{}
+
+	if err := tx.Find(archive, c.Param("archive_id")); err != nil {
+		return nil, "", err
+	}
+
+	role, err := archive.Role(tx, currentUserID(c))
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	if role == "" {
+		return nil, "", errors.New("not a member of that archive")
+	}
+
+	return archive, role, nil
+}
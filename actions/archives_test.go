@@ -0,0 +1,35 @@
+package actions
+
+import "github.com/navionguy/cloudquotes/models"
+
+func (as *ActionSuite) Test_ArchivesIndex_SignedOut() {
+	res := as.HTML("/archives").Get()
+	as.Equal(302, res.Code)
+}
+
+func (as *ActionSuite) Test_ArchivesSwitch_SignedOut() {
+	res := as.HTML("/archives/563cd207-ab16-4a46-b44e-7317b96c6ba9/switch").Post(nil)
+	as.Equal(302, res.Code)
+}
+
+func (as *ActionSuite) Test_ArchiveMember() {
+	u := as.signIn()
+
+	// a user who belongs to no archive can read the default one
+	res := as.HTML("/conversations").Get()
+	as.Equal(200, res.Code)
+
+	// but can't change it
+	res = as.HTML("/conversations/563cd207-ab16-4a46-b44e-7317b96c6ba9/vote").Post(nil)
+	as.Equal(403, res.Code)
+
+	archive, err := models.DefaultArchive(as.DB)
+	as.NoError(err)
+
+	verrs, err := archive.SetMember(as.DB, u.ID, models.RoleMember)
+	as.NoError(err)
+	as.False(verrs.HasAny())
+
+	res = as.HTML("/conversations/563cd207-ab16-4a46-b44e-7317b96c6ba9/vote").Post(nil)
+	as.Equal(404, res.Code)
+}
@@ -0,0 +1,149 @@
+package actions
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/buffalo/worker"
+	"github.com/gobuffalo/pop/v5"
+	"github.com/navionguy/cloudquotes/models"
+	"github.com/navionguy/cloudquotes/storage"
+	"github.com/pkg/errors"
+)
+
+// AttachmentShow sends back the file attached to a conversation.  It
+// takes being signed in, the photos and clips are for the team, not
+// whoever happens across the wall.  Files on a conversation that hasn't
+// been published are only for the editors, the same as the conversation
+// itself.  This function is mapped to the path
+// GET /attachments/{attachment_id}
+func AttachmentShow(c buffalo.Context) error {
+	attachment, err := loadAttachment(c)
+
+	if err != nil {
+		return c.Error(404, err)
+	}
+
+	file, err := storage.Default().Get(attachment.ID.String())
+
+	if err == storage.ErrNotFound {
+		return c.Error(404, err)
+	}
+
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer file.Close()
+
+	res := c.Response()
+	res.Header().Set("Content-Type", attachment.ContentType)
+	res.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=%q", attachment.Filename))
+	res.Header().Set("X-Content-Type-Options", "nosniff")
+	res.WriteHeader(200)
+
+	_, err = io.Copy(res, file)
+
+	return err
+}
+
+// AttachmentDestroy takes a file off a conversation.  Whoever attached it
+// can remove it, as can a moderator.  This function is mapped to the
+// path DELETE /attachments/{attachment_id}
+func AttachmentDestroy(c buffalo.Context) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	attachment, err := loadAttachment(c)
+
+	if err != nil {
+		return c.Error(404, err)
+	}
+
+	user := currentUserID(c)
+
+	if attachment.UserID == nil || *attachment.UserID != user {
+		moderator, err := currentArchive(c).Allows(tx, user, models.PermModerator)
+
+		if err != nil {
+			return errors.WithStack(err)
+		}
+
+		if !moderator {
+			return c.Error(403, errors.New("only the person who attached a file or a moderator can remove it"))
+		}
+	}
+
+	if err = attachment.Remove(tx); err != nil {
+		return errors.WithStack(err)
+	}
+
+	c.Flash().Add("success", "Attachment was removed successfully")
+
+	return c.Redirect(302, "/conversations/%s", attachment.ConversationID)
+}
+
+// loadAttachment finds the attachment named by the attachment_id
+// parameter, as long as its conversation isn't in the trash and, unless
+// the signed in user is an editor, has been published.
+func loadAttachment(c buffalo.Context) (*models.Attachment, error) {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return nil, errors.WithStack(errors.New("no transaction found"))
+	}
+
+	editor, err := seesRealNames(c, currentArchive(c).ID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	attachment := &models.Attachment{}
+
+	q := tx.Where("conversation_id IN (SELECT id FROM conversations WHERE deleted_at IS NULL AND archive_id = ?)", currentArchive(c).ID)
+
+	// only editors get to see what is attached to a conversation that
+	// hasn't been published
+	if !editor {
+		q = q.Where("conversation_id IN (SELECT id FROM conversations WHERE status = ?)", models.StatusPublished)
+	}
+
+	if err = q.Find(attachment, c.Param("attachment_id")); err != nil {
+		return nil, err
+	}
+
+	return attachment, nil
+}
+
+// fileRemovalJob is the name the job removing deleted attachments' files
+// is registered under
+const fileRemovalJob = "remove_files"
+
+// fileRemovalInterval is how often the job looks for files to remove
+const fileRemovalInterval = 5 * time.Minute
+
+// startFileRemoval registers the job that takes the files of deleted
+// attachments out of the store and gets it going.  The job lines itself
+// back up every time it runs.
+func startFileRemoval(w worker.Worker) error {
+	job := worker.Job{Handler: fileRemovalJob}
+
+	err := w.Register(fileRemovalJob, func(worker.Args) error {
+		defer w.PerformIn(job, fileRemovalInterval)
+
+		_, err := models.RemoveFiles(models.DB, storage.Default())
+
+		return err
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return w.PerformIn(job, fileRemovalInterval)
+}
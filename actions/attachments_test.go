@@ -0,0 +1,47 @@
+package actions
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/navionguy/cloudquotes/models"
+	"github.com/navionguy/cloudquotes/storage"
+)
+
+func (as *ActionSuite) Test_AttachmentShow_SignedOut() {
+	res := as.HTML("/attachments/563cd207-ab16-4a46-b44e-7317b96c6ba9").Get()
+	as.Equal(302, res.Code)
+}
+
+func (as *ActionSuite) Test_AttachmentDestroy_SignedOut() {
+	res := as.HTML("/attachments/563cd207-ab16-4a46-b44e-7317b96c6ba9").Delete()
+	as.Equal(302, res.Code)
+}
+
+// what is attached to a draft stays with the editors
+func (as *ActionSuite) Test_AttachmentShow_Draft() {
+	archive, err := models.DefaultArchive(as.DB)
+	as.NoError(err)
+
+	cv := &models.Conversation{ArchiveID: archive.ID, OccurredOn: time.Now(), Status: models.StatusDraft}
+	as.NoError(as.DB.Create(cv))
+
+	data := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+	a := models.NewAttachment("whiteboard.png", data)
+	verrs, err := a.Save(as.DB, storage.Default(), cv.ID, data, uuid.Nil)
+	as.NoError(err)
+	as.False(verrs.HasAny())
+	defer storage.Default().Delete(a.ID.String())
+
+	as.signIn()
+
+	res := as.HTML("/attachments/%s", a.ID).Get()
+	as.Equal(404, res.Code)
+
+	as.signIn(models.PermEditor)
+
+	res = as.HTML("/attachments/%s", a.ID).Get()
+	as.Equal(200, res.Code)
+	as.True(bytes.Equal(data, res.Body.Bytes()))
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/gobuffalo/buffalo"
 	"github.com/gobuffalo/pop/v5"
+	"github.com/gobuffalo/validate/v3"
 
 	"github.com/navionguy/cloudquotes/models"
 	"github.com/pkg/errors"
@@ -26,8 +27,8 @@ func (v AuthorsResource) List(c buffalo.Context) error {
 	// Paginate results. Params "page" and "per_page" control pagination.
 	// Default values are "page=1" and "per_page=20".
 
-	// Get all the authors names and their quote count
-	cq := tx.PaginateFromParams(c.Params()).RawQuery("SELECT authors.id, authors.name, COUNT(DISTINCT quotes.id) FROM authors LEFT JOIN quotes ON quotes.author_id = authors.id GROUP BY authors.id ORDER BY authors.name")
+	// Get all the authors names and their quote count, lines said along with others included
+	cq := tx.PaginateFromParams(c.Params()).RawQuery("SELECT authors.id, authors.name, authors.visibility, COUNT(DISTINCT quotes.id) FROM authors LEFT JOIN quotes ON (quotes.author_id = authors.id OR quotes.id IN (SELECT quote_id FROM quote_authors WHERE quote_authors.author_id = authors.id)) AND quotes.deleted_at IS NULL WHERE authors.archive_id = ? GROUP BY authors.id ORDER BY authors.name", currentArchive(c).ID)
 
 	authorCredits := &models.AuthorCredits{}
 
@@ -35,12 +36,68 @@ func (v AuthorsResource) List(c buffalo.Context) error {
 		return errors.WithStack(err)
 	}
 
+	editor, err := seesRealNames(c, currentArchive(c).ID)
+
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	// only authors shown in full get listed where anybody can see
+	if !editor {
+		*authorCredits = authorCredits.Public()
+	}
+
 	// Add the paginator to the context so it can be used in the template.
 	c.Set("pagination", cq.Paginator)
 
 	return c.Render(200, r.Auto(c, authorCredits))
 }
 
+// Show gets the profile page for one Author.  This function is mapped to
+// the path GET /authors/{author_id}
+func (v AuthorsResource) Show(c buffalo.Context) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	spkr := &models.Author{}
+
+	if err := tx.Eager("Aliases").Where("archive_id = ?", currentArchive(c).ID).Find(spkr, c.Param("author_id")); err != nil {
+		return c.Error(404, err)
+	}
+
+	editor, err := seesRealNames(c, currentArchive(c).ID)
+
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	linked := spkr.LinkedTo(currentUserID(c))
+
+	// the page is all about who they are, so only authors shown in full
+	// get one, except for editors and the author themselves
+	if !editor && !linked && spkr.Visibility != models.VisibilityFull {
+		return c.Error(404, errors.New("author not found"))
+	}
+
+	profile, err := spkr.Profile(tx, editor)
+
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	if !editor {
+		profile.CoSpeakers = profile.CoSpeakers.Public()
+	}
+
+	c.Set("linked", linked)
+	c.Set("editor", editor)
+
+	return c.Render(200, r.Auto(c, profile))
+}
+
 // New author about to be entered
 func (v AuthorsResource) New(c buffalo.Context) error {
 	spkr := &models.Author{}
@@ -69,6 +126,8 @@ func (v AuthorsResource) Create(c buffalo.Context) error {
 		return errors.WithStack(errors.New("no transaction found"))
 	}
 
+	speaker.ArchiveID = currentArchive(c).ID
+
 	if nil != speaker.FindByName() {
 		verrs, err := tx.ValidateAndCreate(speaker)
 
@@ -107,7 +166,7 @@ func (v AuthorsResource) Create(c buffalo.Context) error {
 	authors := []models.Author{}
 
 	// Retrieve all Authors from the DB
-	if err := tx.Order("name").All(&authors); err != nil {
+	if err := tx.Where("archive_id = ?", currentArchive(c).ID).Order("name").All(&authors); err != nil {
 		return errors.WithStack(err)
 	}
 
@@ -129,16 +188,185 @@ func (v AuthorsResource) Edit(c buffalo.Context) error {
 
 	spkr := models.Author{}
 
-	if err := tx.Find(&spkr, c.Param("author_id")); err != nil {
+	if err := tx.Eager("Aliases").Where("archive_id = ?", currentArchive(c).ID).Find(&spkr, c.Param("author_id")); err != nil {
 		return c.Error(404, err)
 	}
 
+	if err := v.loadMergeList(&spkr, c); err != nil {
+		return errors.WithStack(err)
+	}
+
+	if err := v.setConsent(&spkr, c); err != nil {
+		return errors.WithStack(err)
+	}
+
 	c.Set("author", spkr)
 	c.Set("cvj", "")
 
 	return c.Render(200, r.Auto(c, spkr))
 }
 
+// Merge folds duplicate authors into this one.  The form posts the IDs
+// of the duplicates in "duplicates".  A merge can't be undone, so only
+// editors get to do it.  This function is mapped to the path
+// POST /authors/{author_id}/merge
+func (v AuthorsResource) Merge(c buffalo.Context) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	if err := requirePermission(c, models.PermEditor); err != nil {
+		return err
+	}
+
+	keep := &models.Author{}
+
+	if err := tx.Where("archive_id = ?", currentArchive(c).ID).Find(keep, c.Param("author_id")); err != nil {
+		return c.Error(404, err)
+	}
+
+	if err := c.Request().ParseForm(); err != nil {
+		return errors.WithStack(err)
+	}
+
+	ids := []interface{}{}
+	for _, id := range c.Request().Form["duplicates"] {
+		ids = append(ids, id)
+	}
+
+	if len(ids) == 0 {
+		c.Flash().Add("danger", "Pick the duplicate speakers to merge.")
+		return c.Redirect(302, fmt.Sprintf("/authors/%s/edit", keep.ID.String()))
+	}
+
+	dups := models.Authors{}
+
+	if err := tx.Where("archive_id = ?", currentArchive(c).ID).Where("id in (?)", ids...).All(&dups); err != nil {
+		return errors.WithStack(err)
+	}
+
+	err := keep.Merge(tx, dups, currentUserID(c))
+
+	if err == models.ErrLinkedElsewhere {
+		c.Flash().Add("danger", "Those speakers are linked to different accounts, they can't be the same person.")
+		return c.Redirect(302, fmt.Sprintf("/authors/%s/edit", keep.ID.String()))
+	}
+
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	c.Flash().Add("success", fmt.Sprintf("Merged %d speakers into %s", len(dups), keep.Name))
+
+	return c.Redirect(302, "/authors")
+}
+
+// loadMergeList puts every other author into the context so the edit
+// page can offer them up as duplicates to merge.
+func (v AuthorsResource) loadMergeList(spkr *models.Author, c buffalo.Context) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	others := models.Authors{}
+
+	if err := tx.Where("id != ? AND archive_id = ?", spkr.ID, spkr.ArchiveID).Order("name").All(&others); err != nil {
+		return err
+	}
+
+	c.Set("authors", others)
+
+	return nil
+}
+
+// OptOut stops anything more of the author's being published.  Only
+// the user linked to the author can ask for it.  This function is
+// mapped to the path POST /authors/{author_id}/optout
+func (v AuthorsResource) OptOut(c buffalo.Context) error {
+	return v.setOptOut(c, true)
+}
+
+// OptIn lets the author's lines be published again.  This function is
+// mapped to the path DELETE /authors/{author_id}/optout
+func (v AuthorsResource) OptIn(c buffalo.Context) error {
+	return v.setOptOut(c, false)
+}
+
+// setOptOut opts the author named by the author_id param out of, or
+// back in to, publishing, as long as the signed in user is them.
+func (v AuthorsResource) setOptOut(c buffalo.Context, out bool) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	spkr := &models.Author{}
+
+	if err := tx.Where("archive_id = ?", currentArchive(c).ID).Find(spkr, c.Param("author_id")); err != nil {
+		return c.Error(404, err)
+	}
+
+	if !spkr.LinkedTo(currentUserID(c)) {
+		return c.Error(403, errors.New("only the person an author is can opt them out"))
+	}
+
+	var err error
+
+	if out {
+		err = spkr.OptOut(tx)
+		c.Flash().Add("success", "Nothing more of yours will be published.")
+	} else {
+		err = spkr.OptIn(tx)
+		c.Flash().Add("success", "Your quotes can be published again.")
+	}
+
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	return c.Redirect(302, "/authors/%s", spkr.ID)
+}
+
+// setConsent puts what the edit page needs for the author's visibility
+// into the context.  Only editors get to change who the author is
+// linked to.
+func (v AuthorsResource) setConsent(spkr *models.Author, c buffalo.Context) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	editor, err := seesRealNames(c, spkr.ArchiveID)
+
+	if err != nil {
+		return err
+	}
+
+	email := ""
+
+	if spkr.UserID != nil {
+		u := &models.User{}
+
+		if err = tx.Find(u, *spkr.UserID); err != nil {
+			return err
+		}
+
+		email = u.Email
+	}
+
+	c.Set("editor", editor)
+	c.Set("linked_email", email)
+	c.Set("visibilities", models.Visibilities)
+
+	return nil
+}
+
 func (v AuthorsResource) unMarshalConversation(c buffalo.Context) (*models.Conversation, bool) {
 	cvv := c.Request().Form.Get("cvjson")
 	conv := &models.Conversation{}
@@ -163,19 +391,63 @@ func (v AuthorsResource) Update(c buffalo.Context) error {
 
 	speaker := &models.Author{}
 
+	if err := tx.Where("archive_id = ?", currentArchive(c).ID).Find(speaker, c.Param("author_id")); err != nil {
+		return c.Error(404, err)
+	}
+
+	visibility := speaker.Visibility
+
 	// Bind quote to the html form elements
 	if err := c.Bind(speaker); err != nil {
 		return errors.WithStack(err)
 	}
 
-	fmt.Printf("modified speaker %s, %s\n", speaker.Name, c.Param("author_id"))
-	verrs, err := tx.ValidateAndUpdate(speaker)
+	editor, err := seesRealNames(c, speaker.ArchiveID)
 
 	if err != nil {
-		return err
+		return errors.WithStack(err)
+	}
+
+	// how an author is shown is up to the editors, and the author
+	if !editor && !speaker.LinkedTo(currentUserID(c)) {
+		speaker.Visibility = visibility
+	}
+
+	verrs := validate.NewErrors()
+
+	if _, ok := c.Request().Form["user_email"]; ok && editor {
+		if verrs, err = speaker.LinkUser(tx, c.Param("user_email")); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
+	fmt.Printf("modified speaker %s, %s\n", speaker.Name, c.Param("author_id"))
+
+	if !verrs.HasAny() {
+		verrs, err = tx.ValidateAndUpdate(speaker)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if !verrs.HasAny() {
+		verrs, err = speaker.SetAliases(tx, c.Param("alias_list"))
+
+		if err != nil {
+			return err
+		}
 	}
 
 	if verrs.HasAny() {
+		if err = v.loadMergeList(speaker, c); err != nil {
+			return errors.WithStack(err)
+		}
+
+		if err = v.setConsent(speaker, c); err != nil {
+			return errors.WithStack(err)
+		}
+
 		c.Set("author", speaker)
 		c.Set("gotoPage", "edit")
 
@@ -188,7 +460,7 @@ func (v AuthorsResource) Update(c buffalo.Context) error {
 
 	fmt.Println("Moving back to author list.")
 
-	return c.Redirect(302, fmt.Sprintf("/author//%%7B%s%%7D/", speaker.ID.String()))
+	return c.Redirect(302, fmt.Sprintf("/authors/%s", speaker.ID.String()))
 
 	//return c.Render(201, r.Auto(c, speaker))
 
@@ -1,5 +1,7 @@
 package actions
 
+import "github.com/navionguy/cloudquotes/models"
+
 /*
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/require"
@@ -9,3 +11,74 @@ func (as *ActionSuite) Test_Authors_Put() {
 	as.LoadFixture("test authors")
 	//as.Fail("Not Implemented!")
 }
+
+func (as *ActionSuite) Test_Authors_Show_NotFound() {
+	res := as.HTML("/authors/563cd207-ab16-4a46-b44e-7317b96c6ba9").Get()
+	as.Equal(404, res.Code)
+}
+
+// the author in the path is the one that gets changed, whatever ID the
+// form says
+func (as *ActionSuite) Test_Authors_Update_ForeignID() {
+	u := as.signIn()
+
+	archive, err := models.DefaultArchive(as.DB)
+	as.NoError(err)
+
+	verrs, err := archive.SetMember(as.DB, u.ID, models.RoleMember)
+	as.NoError(err)
+	as.False(verrs.HasAny())
+
+	other := &models.Archive{Name: "Elsewhere", Slug: "elsewhere"}
+	as.NoError(as.DB.Create(other))
+
+	ours := &models.Author{Name: "Ours", ArchiveID: archive.ID}
+	as.NoError(as.DB.Create(ours))
+
+	theirs := &models.Author{Name: "Theirs", ArchiveID: other.ID}
+	as.NoError(as.DB.Create(theirs))
+
+	as.HTML("/authors/%s", ours.ID).Put(map[string]string{"ID": theirs.ID.String(), "name": "Renamed"})
+
+	as.NoError(as.DB.Reload(theirs))
+	as.Equal("Theirs", theirs.Name)
+
+	as.NoError(as.DB.Reload(ours))
+	as.Equal("Renamed", ours.Name)
+}
+
+func (as *ActionSuite) Test_AuthorsOptOut_SignedOut() {
+	res := as.HTML("/authors/563cd207-ab16-4a46-b44e-7317b96c6ba9/optout").Post(nil)
+	as.Equal(302, res.Code)
+}
+
+func (as *ActionSuite) Test_AuthorsMerge_SignedOut() {
+	res := as.HTML("/authors/563cd207-ab16-4a46-b44e-7317b96c6ba9/merge").Post(nil)
+	as.Equal(302, res.Code)
+}
+
+func (as *ActionSuite) Test_AuthorsMerge_NotEditor() {
+	as.signIn()
+
+	res := as.HTML("/authors/563cd207-ab16-4a46-b44e-7317b96c6ba9/merge").Post(nil)
+	as.Equal(403, res.Code)
+}
+
+func (as *ActionSuite) Test_Conversations_AuthorFilter_Masked() {
+	archive, err := models.DefaultArchive(as.DB)
+	as.NoError(err)
+
+	author := &models.Author{Name: "Ziggy Stardust", ArchiveID: archive.ID, Visibility: models.VisibilityInitials}
+	as.NoError(as.DB.Create(author))
+
+	// the public can't find a masked author by their real name
+	res := as.HTML("/conversations?author=%s", "Ziggy+Stardust").Get()
+	as.Equal(200, res.Code)
+	as.Contains(res.Body.String(), "No Quotes found for Author.")
+
+	as.signIn(models.PermEditor)
+
+	res = as.HTML("/conversations?author=%s", "Ziggy+Stardust").Get()
+	as.Equal(200, res.Code)
+	as.NotContains(res.Body.String(), "No Quotes found for Author.")
+}
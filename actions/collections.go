@@ -0,0 +1,371 @@
+package actions
+
+import (
+	"strings"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/pop/v5"
+	"github.com/gofrs/uuid"
+	"github.com/navionguy/cloudquotes/models"
+	"github.com/pkg/errors"
+)
+
+// CollectionsIndex lists the signed in user's collections in the
+// current archive, along with the form for starting a new one.  This
+// function is mapped to the path GET /collections
+func CollectionsIndex(c buffalo.Context) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	collections, err := models.UserCollections(tx, currentArchive(c).ID, currentUserID(c))
+
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	c.Set("collections", collections)
+	c.Set("collection", &models.Collection{})
+
+	return c.Render(200, r.HTML("collections/index.html"))
+}
+
+// CollectionsCreate starts a new, empty, collection.  This function is
+// mapped to the path POST /collections
+func CollectionsCreate(c buffalo.Context) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	collection := &models.Collection{}
+
+	if err := c.Bind(collection); err != nil {
+		return errors.WithStack(err)
+	}
+
+	collection.ID = uuid.Nil
+	collection.Name = strings.TrimSpace(collection.Name)
+	collection.ArchiveID = currentArchive(c).ID
+	collection.UserID = currentUserID(c)
+
+	verrs, err := collection.Create(tx)
+
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	if verrs.HasAny() {
+		collections, err := models.UserCollections(tx, currentArchive(c).ID, currentUserID(c))
+
+		if err != nil {
+			return errors.WithStack(err)
+		}
+
+		// set the verification errors into the context and send back the collection
+		c.Set("collections", collections)
+		c.Set("collection", collection)
+		c.Set("errors", verrs)
+
+		return c.Render(422, r.HTML("collections/index.html"))
+	}
+
+	c.Flash().Add("success", "Collection was created successfully")
+
+	return c.Redirect(302, "/collections/%s", collection.ID)
+}
+
+// CollectionsShow lists what is in one of the user's collections, in
+// order, with everything needed to rearrange it.  This function is
+// mapped to the path GET /collections/{collection_id}
+func CollectionsShow(c buffalo.Context) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	collection, err := loadCollection(c, c.Param("collection_id"))
+
+	if err != nil {
+		return c.Error(404, err)
+	}
+
+	conversations, err := collection.Conversations(tx, false)
+
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	editor, err := seesRealNames(c, collection.ArchiveID)
+
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	// masked, but kept in the list so they can still be moved or taken out
+	if !editor {
+		for i := range conversations {
+			conversations[i] = conversations[i].MaskAuthors()
+		}
+	}
+
+	c.Set("collection", collection)
+	c.Set("conversations", conversations)
+
+	return c.Render(200, r.HTML("collections/show.html"))
+}
+
+// CollectionsUpdate renames a collection, and flips it between public
+// and private.  This function is mapped to the path
+// PUT /collections/{collection_id}
+func CollectionsUpdate(c buffalo.Context) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	collection, err := loadCollection(c, c.Param("collection_id"))
+
+	if err != nil {
+		return c.Error(404, err)
+	}
+
+	collection.Name = strings.TrimSpace(c.Param("name"))
+	collection.Public = c.Param("public") == "true"
+
+	verrs, err := tx.ValidateAndUpdate(collection)
+
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	if verrs.HasAny() {
+		c.Flash().Add("danger", verrs.Error())
+	} else {
+		c.Flash().Add("success", "Collection was updated successfully")
+	}
+
+	return c.Redirect(302, "/collections/%s", collection.ID)
+}
+
+// CollectionsDestroy throws a collection away.  The conversations in it
+// are left alone.  This function is mapped to the path
+// DELETE /collections/{collection_id}
+func CollectionsDestroy(c buffalo.Context) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	collection, err := loadCollection(c, c.Param("collection_id"))
+
+	if err != nil {
+		return c.Error(404, err)
+	}
+
+	if err = tx.Destroy(collection); err != nil {
+		return errors.WithStack(err)
+	}
+
+	c.Flash().Add("success", "Collection was deleted successfully")
+
+	return c.Redirect(302, "/collections")
+}
+
+// CollectionsStar adds a conversation to one of the user's collections.
+// The "collection_id" param picks which, leaving it off stars it into
+// their favorites.  This function is mapped to the path
+// POST /conversations/{conversation_id}/star
+func CollectionsStar(c buffalo.Context) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	conversation := &models.Conversation{}
+
+	if err := tx.Where("deleted_at IS NULL AND archive_id = ?", currentArchive(c).ID).Find(conversation, c.Param("conversation_id")); err != nil {
+		return c.Error(404, err)
+	}
+
+	var collection *models.Collection
+	var err error
+
+	if id := c.Param("collection_id"); len(id) > 0 {
+		collection, err = loadCollection(c, id)
+
+		if err != nil {
+			return c.Error(404, err)
+		}
+	} else {
+		collection, err = models.Favorites(tx, currentArchive(c).ID, currentUserID(c))
+
+		if err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
+	if err = collection.Add(tx, conversation.ID); err != nil {
+		return errors.WithStack(err)
+	}
+
+	c.Flash().Add("success", "Conversation was added to "+collection.Name)
+
+	return c.Redirect(302, "/conversations/%s", conversation.ID)
+}
+
+// CollectionsRemoveEntry takes a conversation out of a collection.
+// This function is mapped to the path
+// DELETE /collections/{collection_id}/entries/{conversation_id}
+func CollectionsRemoveEntry(c buffalo.Context) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	collection, err := loadCollection(c, c.Param("collection_id"))
+
+	if err != nil {
+		return c.Error(404, err)
+	}
+
+	id, err := uuid.FromString(c.Param("conversation_id"))
+
+	if err != nil {
+		return c.Error(400, err)
+	}
+
+	if err = collection.Remove(tx, id); err != nil {
+		return errors.WithStack(err)
+	}
+
+	c.Flash().Add("success", "Conversation was removed from "+collection.Name)
+
+	return c.Redirect(302, "/collections/%s", collection.ID)
+}
+
+// CollectionsMoveEntry moves a conversation one place "up" or "down"
+// the collection, as given by the "direction" param.  This function is
+// mapped to the path
+// POST /collections/{collection_id}/entries/{conversation_id}/move
+func CollectionsMoveEntry(c buffalo.Context) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	collection, err := loadCollection(c, c.Param("collection_id"))
+
+	if err != nil {
+		return c.Error(404, err)
+	}
+
+	id, err := uuid.FromString(c.Param("conversation_id"))
+
+	if err != nil {
+		return c.Error(400, err)
+	}
+
+	by := 1
+	if c.Param("direction") == "up" {
+		by = -1
+	}
+
+	if err = collection.Move(tx, id, by); err != nil {
+		return errors.WithStack(err)
+	}
+
+	return c.Redirect(302, "/collections/%s", collection.ID)
+}
+
+// SharedCollection is where a collection's share URL goes.  Public
+// collections can be seen by anyone who has the URL, whatever archive
+// they are looking at, but only what has been published is shown.
+// This function is mapped to the path GET /shared/{token}
+func SharedCollection(c buffalo.Context) error {
+	collection, conversations, err := loadShared(c)
+
+	if err != nil {
+		return c.Error(404, err)
+	}
+
+	c.Set("collection", collection)
+	c.Set("conversations", conversations)
+
+	return c.Render(200, r.HTML("collections/shared.html"))
+}
+
+// SharedCollectionFeed is the JSON feed for a collection, in the same
+// shape as the export so the quote wall can play it.  This function is
+// mapped to the path GET /shared/{token}/feed
+func SharedCollectionFeed(c buffalo.Context) error {
+	_, conversations, err := loadShared(c)
+
+	if err != nil {
+		return c.Error(404, err)
+	}
+
+	return c.Render(200, r.JSON(conversations))
+}
+
+// loadCollection finds one of the signed in user's collections in the
+// current archive
+func loadCollection(c buffalo.Context, id string) (*models.Collection, error) {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return nil, errors.WithStack(errors.New("no transaction found"))
+	}
+
+	collection := &models.Collection{}
+
+	if err := tx.Where("archive_id = ? AND user_id = ?", currentArchive(c).ID, currentUserID(c)).Find(collection, id); err != nil {
+		return nil, err
+	}
+
+	return collection, nil
+}
+
+// loadShared finds the collection named by the token param, as long as
+// the signed in user, if any, is allowed to see it, along with its
+// published conversations, authors named the way they agreed to be.
+func loadShared(c buffalo.Context) (*models.Collection, models.Conversations, error) {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return nil, nil, errors.WithStack(errors.New("no transaction found"))
+	}
+
+	collection, err := models.FindSharedCollection(tx, c.Param("token"))
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !collection.VisibleTo(currentUserID(c)) {
+		return nil, nil, errors.New("collection is private")
+	}
+
+	conversations, err := collection.Conversations(tx, true)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	conversations, err = maskAuthors(c, collection.ArchiveID, conversations)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return collection, conversations, nil
+}
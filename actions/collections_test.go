@@ -0,0 +1,16 @@
+package actions
+
+func (as *ActionSuite) Test_CollectionsIndex_SignedOut() {
+	res := as.HTML("/collections").Get()
+	as.Equal(302, res.Code)
+}
+
+func (as *ActionSuite) Test_CollectionsStar_SignedOut() {
+	res := as.HTML("/conversations/563cd207-ab16-4a46-b44e-7317b96c6ba9/star").Post(nil)
+	as.Equal(302, res.Code)
+}
+
+func (as *ActionSuite) Test_SharedCollection_NotFound() {
+	res := as.HTML("/shared/nosuchtoken").Get()
+	as.Equal(404, res.Code)
+}
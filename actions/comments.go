@@ -0,0 +1,267 @@
+package actions
+
+import (
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/pop/v5"
+	"github.com/gofrs/uuid"
+	"github.com/navionguy/cloudquotes/models"
+	"github.com/pkg/errors"
+)
+
+// CommentsResource is the resource for the discussion thread under a
+// conversation.  Everyone can read the thread, but it takes being
+// signed in to post, and comments can only be changed by whoever wrote
+// them.  Moderators can remove anybody's.
+type CommentsResource struct {
+	buffalo.Resource
+}
+
+// List gets the whole thread for a Conversation. This function is
+// mapped to the path GET /conversations/{conversation_id}/comments
+func (v CommentsResource) List(c buffalo.Context) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	conversation, err := v.loadConversation(c)
+
+	if err != nil {
+		return c.Error(404, err)
+	}
+
+	comments, err := models.ConversationComments(tx, conversation.ID)
+
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	c.Set("conversation", conversation)
+
+	return c.Render(200, r.Auto(c, comments))
+}
+
+// Show gets the data for one Comment. This function is mapped to
+// the path GET /conversations/{conversation_id}/comments/{comment_id}
+func (v CommentsResource) Show(c buffalo.Context) error {
+	conversation, comment, err := v.loadComment(c)
+
+	if err != nil {
+		return c.Error(404, err)
+	}
+
+	c.Set("conversation", conversation)
+
+	return c.Render(200, r.Auto(c, comment))
+}
+
+// Create adds a Comment to the thread. This function is mapped to the
+// path POST /conversations/{conversation_id}/comments
+func (v CommentsResource) Create(c buffalo.Context) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	conversation, err := v.loadConversation(c)
+
+	if err != nil {
+		return c.Error(404, err)
+	}
+
+	comment := &models.Comment{}
+
+	if err := c.Bind(comment); err != nil {
+		return errors.WithStack(err)
+	}
+
+	user := currentUserID(c)
+	comment.ID = uuid.Nil
+	comment.ConversationID = conversation.ID
+	comment.UserID = &user
+	comment.RemovedAt = nil
+
+	verrs, err := tx.ValidateAndCreate(comment)
+
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	if verrs.HasAny() {
+		c.Flash().Add("danger", verrs.Error())
+	} else {
+		c.Flash().Add("success", "Comment was posted successfully")
+	}
+
+	return c.Redirect(302, "/conversations/%s", conversation.ID)
+}
+
+// Edit renders a edit form for a Comment. This function is mapped to
+// the path GET /conversations/{conversation_id}/comments/{comment_id}/edit
+func (v CommentsResource) Edit(c buffalo.Context) error {
+	conversation, comment, err := v.loadComment(c)
+
+	if err != nil {
+		return c.Error(404, err)
+	}
+
+	if !v.canEdit(c, comment) {
+		return c.Error(403, errors.New("only the person who wrote a comment can change it"))
+	}
+
+	c.Set("conversation", conversation)
+
+	return c.Render(200, r.Auto(c, comment))
+}
+
+// Update changes a Comment in the DB. This function is mapped to
+// the path PUT /conversations/{conversation_id}/comments/{comment_id}
+func (v CommentsResource) Update(c buffalo.Context) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	conversation, comment, err := v.loadComment(c)
+
+	if err != nil {
+		return c.Error(404, err)
+	}
+
+	if !v.canEdit(c, comment) {
+		return c.Error(403, errors.New("only the person who wrote a comment can change it"))
+	}
+
+	comment.Body = c.Param("body")
+
+	verrs, err := tx.ValidateAndUpdate(comment)
+
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	if verrs.HasAny() {
+		// set the verification errors into the context and send back the comment
+		c.Set("conversation", conversation)
+		c.Set("comment", comment)
+		c.Set("errors", verrs)
+
+		return c.Render(422, r.HTML("comments/edit.html"))
+	}
+
+	c.Flash().Add("success", "Comment was updated successfully")
+
+	return c.Redirect(302, "/conversations/%s", conversation.ID)
+}
+
+// Destroy takes a Comment out of the thread. Whoever wrote it can
+// delete it outright, a moderator removing someone else's leaves a
+// marker behind.  This function is mapped to the path
+// DELETE /conversations/{conversation_id}/comments/{comment_id}
+func (v CommentsResource) Destroy(c buffalo.Context) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	conversation, comment, err := v.loadComment(c)
+
+	if err != nil {
+		return c.Error(404, err)
+	}
+
+	if comment.OwnedBy(currentUserID(c)) {
+		if err = tx.Destroy(comment); err != nil {
+			return errors.WithStack(err)
+		}
+
+		c.Flash().Add("success", "Comment was deleted successfully")
+
+		return c.Redirect(302, "/conversations/%s", conversation.ID)
+	}
+
+	moderator, err := currentArchive(c).Allows(tx, currentUserID(c), models.PermModerator)
+
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	if !moderator {
+		return c.Error(403, errors.New("only the person who wrote a comment or a moderator can remove it"))
+	}
+
+	if err = comment.Remove(tx); err != nil {
+		return errors.WithStack(err)
+	}
+
+	c.Flash().Add("success", "Comment was removed by a moderator")
+
+	return c.Redirect(302, "/conversations/%s", conversation.ID)
+}
+
+// canEdit reports if the signed in user may change the comment.  Once a
+// moderator has removed it, nobody can.
+func (v CommentsResource) canEdit(c buffalo.Context, comment *models.Comment) bool {
+	return comment.RemovedAt == nil && comment.OwnedBy(currentUserID(c))
+}
+
+// loadConversation finds the conversation the thread hangs off, as long
+// as it isn't in the trash.  Only the editors get at the threads of
+// conversations that haven't been published.
+func (v CommentsResource) loadConversation(c buffalo.Context) (*models.Conversation, error) {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return nil, errors.WithStack(errors.New("no transaction found"))
+	}
+
+	archive := currentArchive(c).ID
+
+	editor, err := seesRealNames(c, archive)
+
+	if err != nil {
+		return nil, err
+	}
+
+	q := tx.Where("deleted_at IS NULL AND archive_id = ?", archive)
+
+	if !editor {
+		q = q.Where("status = ?", models.StatusPublished)
+	}
+
+	conversation := &models.Conversation{}
+
+	if err = q.Find(conversation, c.Param("conversation_id")); err != nil {
+		return nil, err
+	}
+
+	return conversation, nil
+}
+
+// loadComment finds the comment named by the comment_id parameter in
+// the conversation's thread
+func (v CommentsResource) loadComment(c buffalo.Context) (*models.Conversation, *models.Comment, error) {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return nil, nil, errors.WithStack(errors.New("no transaction found"))
+	}
+
+	conversation, err := v.loadConversation(c)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	comment, err := models.FindComment(tx, conversation.ID, c.Param("comment_id"))
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return conversation, comment, nil
+}
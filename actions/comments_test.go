@@ -0,0 +1,44 @@
+package actions
+
+import (
+	"time"
+
+	"github.com/navionguy/cloudquotes/models"
+)
+
+func (as *ActionSuite) Test_CommentsResource_List_NotFound() {
+	res := as.JSON("/conversations/563cd207-ab16-4a46-b44e-7317b96c6ba9/comments").Get()
+	as.Equal(404, res.Code)
+}
+
+func (as *ActionSuite) Test_CommentsResource_Create_SignedOut() {
+	res := as.HTML("/conversations/563cd207-ab16-4a46-b44e-7317b96c6ba9/comments").Post(map[string]string{"body": "It was a Tuesday."})
+	as.Equal(302, res.Code)
+}
+
+// threads on conversations that haven't been published stay hidden, and
+// nobody gets to read commenters' email addresses
+func (as *ActionSuite) Test_CommentsResource_List_Public() {
+	u := as.signIn()
+	as.Session.Clear()
+
+	archive, err := models.DefaultArchive(as.DB)
+	as.NoError(err)
+
+	draft := &models.Conversation{ArchiveID: archive.ID, OccurredOn: time.Now(), Status: models.StatusDraft}
+	as.NoError(as.DB.Create(draft))
+
+	published := &models.Conversation{ArchiveID: archive.ID, OccurredOn: time.Now(), Status: models.StatusPublished, Publish: true}
+	as.NoError(as.DB.Create(published))
+
+	as.NoError(as.DB.Create(&models.Comment{ConversationID: published.ID, UserID: &u.ID, Body: "It was a Tuesday."}))
+
+	res := as.JSON("/conversations/%s/comments", draft.ID).Get()
+	as.Equal(404, res.Code)
+
+	res = as.JSON("/conversations/%s/comments", published.ID).Get()
+	as.Equal(200, res.Code)
+	as.Contains(res.Body.String(), "It was a Tuesday.")
+	as.Contains(res.Body.String(), u.DisplayName())
+	as.NotContains(res.Body.String(), u.Email)
+}
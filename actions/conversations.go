@@ -1,14 +1,22 @@
 package actions
 
 import (
+	"database/sql"
 	"fmt"
+	"html/template"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/gobuffalo/buffalo"
 	"github.com/gobuffalo/pop/v5"
 	"github.com/gobuffalo/validate/v3"
 	"github.com/gofrs/uuid"
 	"github.com/navionguy/cloudquotes/models"
+	"github.com/navionguy/cloudquotes/storage"
 	"github.com/pkg/errors"
 )
 
@@ -24,14 +32,28 @@ import (
 // Path: Plural (/conversations)
 // View Template Folder: Plural (/templates/conversations/)
 
-var fontScale = map[int]string{
-	1: "48px",
-	2: "40px",
-	3: "30px",
-	4: "20px",
-	5: "10px",
+// fontScale sizes the show page by how much text it has to fit.  The
+// first size whose limit the conversation fits under wins, anything
+// longer than the last limit gets the smallest size.
+var fontScale = []struct {
+	chars int
+	size  string
+}{
+	{80, "48px"},
+	{200, "40px"},
+	{400, "30px"},
+	{800, "24px"},
+	{1600, "18px"},
+	{3200, "14px"},
 }
 
+// fontSmallest is for the rants that don't fit anywhere on the scale
+const fontSmallest = "12px"
+
+// fontLineCost is how many characters each line's speaker and date are
+// worth when working out how much room a conversation needs
+const fontLineCost = 40
+
 // ConversationsResource is the resource for the Conversation model
 type ConversationsResource struct {
 	buffalo.Resource
@@ -46,7 +68,15 @@ func (v ConversationsResource) List(c buffalo.Context) error {
 		return errors.WithStack(errors.New("no transaction found"))
 	}
 
-	auth := checkAuthorFilter(c)
+	// only the editors get to find conversations by a masked author's
+	// real name, or see the ones that haven't been published
+	editor, err := seesRealNames(c, currentArchive(c).ID)
+
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	auth := checkAuthorFilter(c, editor)
 	conversations := &models.Conversations{}
 
 	// Paginate results. Params "page" and "per_page" control pagination.
@@ -54,12 +84,30 @@ func (v ConversationsResource) List(c buffalo.Context) error {
 	// I only eager load the Quotes because I don't touch data from the
 	// other objects in the index page
 
-	q := tx.Eager("Quotes").Eager("Quotes.Author").PaginateFromParams(c.Params())
+	q := tx.Eager("Quotes").Eager("Quotes.Author").Eager("Quotes.CoAuthors").Eager("Tags").PaginateFromParams(c.Params()).Where("conversations.deleted_at IS NULL AND conversations.archive_id = ?", currentArchive(c).ID)
+
+	if !editor {
+		q = q.Where("conversations.status = ?", models.StatusPublished)
+	}
 
 	if len(auth.Name) > 0 {
-		q = q.InnerJoin("quotes", "conversations.id = quotes.conversation_id").Where("quotes.author_id = ?", auth.ID.String())
+		q = q.InnerJoin("quotes", "conversations.id = quotes.conversation_id").Where("(quotes.author_id = ? OR quotes.id IN (SELECT quote_id FROM quote_authors WHERE author_id = ?))", auth.ID.String(), auth.ID.String())
+	}
+
+	if tag := c.Param("tag"); len(tag) > 0 {
+		q = models.FilterByTag(q, tag)
 	}
 
+	// a search puts the best matches on top, ties fall back to newest first
+	terms := strings.TrimSpace(c.Param("q"))
+
+	if len(terms) > 0 {
+		q = models.SearchConversations(q, terms, editor)
+	}
+
+	// partial dates are kept as the start of what they cover, so a
+	// conversation only known as "1998" comes after everything known to
+	// have happened in 1998, and before 1997
 	q = q.Order("occurredon DESC")
 
 	// Retrieve all Conversations from the DB
@@ -67,18 +115,69 @@ func (v ConversationsResource) List(c buffalo.Context) error {
 		return errors.WithStack(err)
 	}
 
+	conversations.DropRemovedQuotes()
+
+	// a hidden author's line, or an unpublished one, could be the one a
+	// search matched, so conversations that lost lines to masking go
+	// without highlights
+	lines := map[uuid.UUID]int{}
+	for _, cv := range *conversations {
+		lines[cv.ID] = len(cv.Quotes)
+	}
+
+	shown, err := maskAuthors(c, currentArchive(c).ID, *conversations)
+
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	*conversations = shown
+
+	// the index page marks up where the search terms were found
+	highlights := map[uuid.UUID]template.HTML{}
+
+	if len(terms) > 0 {
+		highlights, err = models.SearchHighlights(tx, terms, *conversations)
+
+		if err != nil {
+			return errors.WithStack(err)
+		}
+
+		for _, cv := range *conversations {
+			if len(cv.Quotes) != lines[cv.ID] {
+				delete(highlights, cv.ID)
+			}
+		}
+
+		if len(*conversations) == 0 {
+			c.Flash().Add("success", "No Quotes found matching search.")
+		}
+	}
+
+	if err := v.setVotes(c, *conversations); err != nil {
+		return errors.WithStack(err)
+	}
+
+	c.Set("q", terms)
+	c.Set("tag", c.Param("tag"))
+	c.Set("highlights", highlights)
+	c.Set("editor", editor)
+
 	// Add the paginator to the context so it can be used in the template.
 	c.Set("pagination", q.Paginator)
 
 	return c.Render(200, r.Auto(c, conversations))
 }
 
-// checkAuthorFilter looks
-func checkAuthorFilter(c buffalo.Context) *models.Author {
+// checkAuthorFilter looks for the author named in the author param.
+// Unless realNames is set, authors that aren't shown in full can't be
+// found, otherwise a filter would give away who they are.
+func checkAuthorFilter(c buffalo.Context, realNames bool) *models.Author {
 	ta := c.Param("author")
 
 	auth := &models.Author{}
 	auth.Name = ta
+	auth.ArchiveID = currentArchive(c).ID
 
 	if len(auth.Name) == 0 {
 		// there is no author filter
@@ -87,6 +186,10 @@ func checkAuthorFilter(c buffalo.Context) *models.Author {
 
 	err := auth.FindByName()
 
+	if err == nil && !realNames && auth.Visibility != models.VisibilityFull {
+		err = errors.New("author is not shown by name")
+	}
+
 	if err != nil {
 		// name passed in is not a known author
 		auth.Name = ""
@@ -107,17 +210,172 @@ func (v ConversationsResource) Show(c buffalo.Context) error {
 		return c.Error(404, err)
 	}
 
-	var fontSize string
-	if len(conversation.Quotes) > 1 {
-		fontSize = fmt.Sprintf("%s", fontScale[len(conversation.Quotes)])
-	} else if len(conversation.Quotes[0].Phrase) > 100 {
-		fontSize = fmt.Sprintf("%s", fontScale[2])
+	editor, err := seesRealNames(c, currentArchive(c).ID)
+
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	// only the editors see what hasn't made it through review
+	if !editor && conversation.Status != models.StatusPublished {
+		return c.Error(404, errors.New("conversation not found"))
+	}
+
+	shown, err := maskAuthors(c, currentArchive(c).ID, models.Conversations{*conversation})
+
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	// every line in it is unpublished, or by somebody who asked to be hidden
+	if len(shown) == 0 {
+		return c.Error(404, errors.New("conversation not found"))
+	}
+
+	*conversation = shown[0]
+	setNotes(c, conversation)
+
+	if err = v.setVotes(c, models.Conversations{*conversation}); err != nil {
+		return errors.WithStack(err)
 	}
 
-	c.Set("fontsize", fontSize)
+	if err = v.setComments(c, conversation); err != nil {
+		return errors.WithStack(err)
+	}
+
+	if err = v.setAttachments(c, conversation); err != nil {
+		return errors.WithStack(err)
+	}
+
+	if err = v.setCollections(c); err != nil {
+		return errors.WithStack(err)
+	}
+
+	c.Set("fontsize", fontSizeFor(conversation))
 	return c.Render(200, r.Auto(c, conversation))
 }
 
+// setAttachments puts the files attached to the conversation into the
+// context.
+func (v ConversationsResource) setAttachments(c buffalo.Context, conversation *models.Conversation) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	attachments, err := models.ConversationAttachments(tx, conversation.ID)
+
+	if err != nil {
+		return err
+	}
+
+	c.Set("attachments", attachments)
+
+	return nil
+}
+
+// setCollections puts the signed in user's collections into the context
+// so the conversation can be starred into one.  Favorites are what a
+// star goes into by default, so they aren't listed again.
+func (v ConversationsResource) setCollections(c buffalo.Context) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	collections, err := models.UserCollections(tx, currentArchive(c).ID, currentUserID(c))
+
+	if err != nil {
+		return err
+	}
+
+	others := models.Collections{}
+	for _, col := range collections {
+		if col.Name != models.FavoritesName {
+			others = append(others, col)
+		}
+	}
+
+	c.Set("collections", others)
+
+	return nil
+}
+
+// setComments puts the discussion thread for the conversation into the
+// context, along with whether the signed in user can moderate it.
+func (v ConversationsResource) setComments(c buffalo.Context, conversation *models.Conversation) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	comments, err := models.ConversationComments(tx, conversation.ID)
+
+	if err != nil {
+		return err
+	}
+
+	moderator, err := currentArchive(c).Allows(tx, currentUserID(c), models.PermModerator)
+
+	if err != nil {
+		return err
+	}
+
+	c.Set("comments", comments)
+	c.Set("moderator", moderator)
+	c.Set("user_id", currentUserID(c))
+
+	return nil
+}
+
+// setVotes puts how many votes each conversation has, and which ones
+// the signed in user voted for, into the context.
+func (v ConversationsResource) setVotes(c buffalo.Context, conversations models.Conversations) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	votes, err := models.VoteCounts(tx, conversations)
+
+	if err != nil {
+		return err
+	}
+
+	voted, err := models.VotedFor(tx, conversations, currentUserID(c))
+
+	if err != nil {
+		return err
+	}
+
+	c.Set("votes", votes)
+	c.Set("voted", voted)
+
+	return nil
+}
+
+// fontSizeFor picks how big to draw the quotes on the show page.
+// The more there is to say, the smaller it gets.
+func fontSizeFor(conversation *models.Conversation) string {
+	chars := 0
+
+	for _, q := range conversation.Quotes {
+		chars += utf8.RuneCountInString(q.Phrase) + fontLineCost
+	}
+
+	for _, fs := range fontScale {
+		if chars <= fs.chars {
+			return fs.size
+		}
+	}
+
+	return fontSmallest
+}
+
 // New renders the form for creating a new Conversation.
 // This function is mapped to the path GET /conversations/new
 func (v ConversationsResource) New(c buffalo.Context) error {
@@ -153,9 +411,14 @@ func (v ConversationsResource) New(c buffalo.Context) error {
 // "prevQuote" - Move to the previous quote in the conversation
 //
 func (v ConversationsResource) Create(c buffalo.Context) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
 
 	req := c.Request()
-	if err := req.ParseForm(); err != nil {
+	if err := req.ParseMultipartForm(models.MaxAttachmentSize); err != nil && err != http.ErrNotMultipart {
 		return errors.WithStack(err)
 	}
 
@@ -166,17 +429,44 @@ func (v ConversationsResource) Create(c buffalo.Context) error {
 		return errors.WithStack(err)
 	}
 
+	conv.ArchiveID = currentArchive(c).ID
+
 	switch *option {
 	case "addAuthor":
 		return v.addAuthor(conv, c)
 
 	case "save":
-		verrs, err := conv.Create()
+		uploads, verrs, err := v.readAttachments(c)
 
 		if err != nil {
 			return errors.WithStack(err)
 		}
 
+		// the same classic quotes keep getting entered twice, so check
+		// first unless the user has already said to save anyway
+		if !verrs.HasAny() && c.Request().Form.Get("force") != "true" {
+			dups, err := v.findDuplicates(c, conv)
+
+			if err != nil {
+				return errors.WithStack(err)
+			}
+
+			if len(dups) > 0 {
+				c.Set("duplicates", dups)
+				c.Set("cvj", c.Request().Form.Get("cvjson"))
+
+				return c.Render(200, r.HTML("conversations/duplicates.html"))
+			}
+		}
+
+		if !verrs.HasAny() {
+			verrs, err = conv.Create(tx, currentUserID(c))
+
+			if err != nil {
+				return errors.WithStack(err)
+			}
+		}
+
 		if verrs.HasAny() {
 			err = v.loadForm(conv, c)
 
@@ -188,6 +478,11 @@ func (v ConversationsResource) Create(c buffalo.Context) error {
 
 			return c.Render(422, r.HTML("conversations/new.html"))
 		}
+
+		if err = v.saveAttachments(c, conv, uploads); err != nil {
+			return errors.WithStack(err)
+		}
+
 		c.Flash().Add("success", "Conversation was created successfully")
 
 		//return c.Redirect(302, fmt.Sprintf("/conversations//%%7B%s%%7D/", conversation.ID.String()))
@@ -200,6 +495,11 @@ func (v ConversationsResource) Create(c buffalo.Context) error {
 // Edit renders a edit form for a Conversation. This function is
 // mapped to the path GET /conversations/{conversation_id}/edit
 func (v ConversationsResource) Edit(c buffalo.Context) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
 
 	cv, err := v.loadConversation(c)
 
@@ -213,11 +513,71 @@ func (v ConversationsResource) Edit(c buffalo.Context) error {
 		return c.Error(404, err)
 	}
 
+	// a rejected conversation shows the reviewer's comment so it can be fixed
+	if cv.Status == models.StatusRejected {
+		rejection, err := cv.LatestReview(tx, models.StatusRejected)
+
+		if err != nil {
+			return errors.WithStack(err)
+		}
+
+		if rejection != nil {
+			c.Set("rejection", rejection)
+		}
+	}
+
 	return c.Render(200, r.Auto(c, cv))
 }
 
+// Transition moves a Conversation through the review workflow.  The
+// "status" param is where it should go next, and "comment" is what the
+// reviewer had to say, which a rejection has to have.  This function is
+// mapped to the path POST /conversations/{conversation_id}/status
+func (v ConversationsResource) Transition(c buffalo.Context) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	conversation := &models.Conversation{}
+
+	if err := tx.Where("deleted_at IS NULL AND archive_id = ?", currentArchive(c).ID).Find(conversation, c.Param("conversation_id")); err != nil {
+		return c.Error(404, err)
+	}
+
+	editor, err := currentArchive(c).Allows(tx, currentUserID(c), models.PermEditor)
+
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	verrs, err := conversation.Transition(tx, c.Param("status"), currentUserID(c), editor, c.Param("comment"))
+
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	if verrs.HasAny() {
+		c.Flash().Add("danger", verrs.Error())
+	} else {
+		c.Flash().Add("success", "Conversation is now "+conversation.Status)
+	}
+
+	// editors work from the review queue, everyone else from the list
+	if editor {
+		return c.Redirect(302, "/review")
+	}
+
+	return c.Redirect(302, "/conversations")
+}
+
 // Update changes a Conversation in the DB. This function is mapped to
 // the path PUT /conversations/{conversation_id}
+//
+// The edit form posts the same "cvjson" and "option" fields as the
+// create form, with the conversation holding every quote as it should
+// look after the edit.
 func (v ConversationsResource) Update(c buffalo.Context) error {
 	// Get the DB connection from the context
 	tx, ok := c.Value("tx").(*pop.Connection)
@@ -226,33 +586,48 @@ func (v ConversationsResource) Update(c buffalo.Context) error {
 	}
 
 	req := c.Request()
-	if err := req.ParseForm(); err != nil {
+	if err := req.ParseMultipartForm(models.MaxAttachmentSize); err != nil && err != http.ErrNotMultipart {
 		return errors.WithStack(err)
 	}
 
-	conv, option, err := v.bindToForm(c)
+	current := &models.Conversation{}
 
-	if err != nil {
-		return err
+	if err := tx.Where("deleted_at IS NULL AND archive_id = ?", currentArchive(c).ID).Find(current, c.Param("conversation_id")); err != nil {
+		return c.Error(404, err)
 	}
 
-	// Allocate an empty quote
-	quote := &models.Quote{}
+	conv, option, err := v.bindToForm(c)
 
-	if err := tx.Find(conv, c.Param("conversation_id")); err != nil {
-		return c.Error(404, err)
+	if err != nil {
+		return errors.WithStack(err)
 	}
-	var verrs *validate.Errors
+
+	// the URL says which conversation is being edited, not the form, and
+	// only the review workflow gets to change where it is in review
+	conv.ID = current.ID
+	conv.CreatedAt = current.CreatedAt
+	conv.Status = current.Status
+	conv.ArchiveID = current.ArchiveID
+	conv.Publish = current.Publish
+	conv.DeletedAt = current.DeletedAt
 
 	switch *option {
 	case "addAuthor":
 		return v.addAuthor(conv, c)
 
 	case "save":
-		conv, quote, verrs, err = v.saveConversation(quote)
+		uploads, verrs, err := v.readAttachments(c)
 
 		if err != nil {
-			return err
+			return errors.WithStack(err)
+		}
+
+		if !verrs.HasAny() {
+			verrs, err = conv.Update(tx, currentUserID(c))
+
+			if err != nil {
+				return errors.WithStack(err)
+			}
 		}
 
 		if verrs.HasAny() {
@@ -261,21 +636,47 @@ func (v ConversationsResource) Update(c buffalo.Context) error {
 			if err != nil {
 				return errors.WithStack(err)
 			}
-			// set the verification errors into the context and send back the quote
+			// set the verification errors into the context and send back the conversation
 			c.Set("errors", verrs)
 
-			return c.Render(422, r.HTML("conversations/new.html"))
+			return c.Render(422, r.HTML("conversations/edit.html"))
 		}
-		c.Flash().Add("success", "Conversation was created successfully")
 
-		//return c.Redirect(302, fmt.Sprintf("/conversations//%%7B%s%%7D/", conversation.ID.String()))
-		return c.Render(201, r.Auto(c, conv))
+		if err = v.saveAttachments(c, conv, uploads); err != nil {
+			return errors.WithStack(err)
+		}
+
+		// a change the editors haven't seen goes back to them
+		editor, err := currentArchive(c).Allows(tx, currentUserID(c), models.PermEditor)
+
+		if err != nil {
+			return errors.WithStack(err)
+		}
+
+		if editor {
+			c.Flash().Add("success", "Conversation was updated successfully")
+
+			return c.Redirect(302, "/conversations/%s", conv.ID)
+		}
+
+		if err = conv.Reopen(tx, currentUserID(c)); err != nil {
+			return errors.WithStack(err)
+		}
+
+		if conv.Status != current.Status {
+			c.Flash().Add("success", "Conversation was updated and sent back for review")
+		} else {
+			c.Flash().Add("success", "Conversation was updated successfully")
+		}
+
+		// only the editors can see it until it is published again
+		return c.Redirect(302, "/conversations")
 	}
 
 	return err
 }
 
-// Destroy deletes a Conversation from the DB. This function is mapped
+// Destroy moves a Conversation into the trash. This function is mapped
 // to the path DELETE /conversations/{conversation_id}
 func (v ConversationsResource) Destroy(c buffalo.Context) error {
 	// Get the DB connection from the context
@@ -288,69 +689,230 @@ func (v ConversationsResource) Destroy(c buffalo.Context) error {
 	conversation := &models.Conversation{}
 
 	// To find the Conversation the parameter conversation_id is used.
-	if err := tx.Eager("Quotes").Find(conversation, c.Param("conversation_id")); err != nil {
+	if err := tx.Eager("Quotes", "Tags").Where("deleted_at IS NULL AND archive_id = ?", currentArchive(c).ID).Find(conversation, c.Param("conversation_id")); err != nil {
 		return c.Error(404, err)
 	}
 
-	// loop through all the quotes and delete them
-	for i := range conversation.Quotes {
-		q := &models.Quote{}
-		q.ID = conversation.Quotes[i].ID
-		if err := tx.Destroy(q); err != nil {
-			return errors.WithStack(err)
-		}
-	}
+	conversation.Quotes = conversation.LiveQuotes()
 
-	if err := tx.Destroy(conversation); err != nil {
+	// it goes in the trash, where it can be restored or purged for good
+	if err := conversation.Trash(tx, currentUserID(c)); err != nil {
 		return errors.WithStack(err)
 	}
 
 	// If there are no errors set a flash message
-	c.Flash().Add("success", "Conversation was destroyed successfully")
+	c.Flash().Add("success", "Conversation was moved to the trash")
 
 	// Redirect to the conversations index page
 	return c.Render(302, r.Auto(c, conversation))
 
 }
 
-// Export dumps the database in JSON.  Maps to the
-// path GET /conversations/export
-func (v ConversationsResource) Export(c buffalo.Context) error {
+// Vote gives the signed in user's upvote to a Conversation.  This
+// function is mapped to the path POST /conversations/{conversation_id}/vote
+func (v ConversationsResource) Vote(c buffalo.Context) error {
 	// Get the DB connection from the context
 	tx, ok := c.Value("tx").(*pop.Connection)
 	if !ok {
 		return errors.WithStack(errors.New("no transaction found"))
 	}
 
-	conversations := &models.Conversations{}
+	conversation := &models.Conversation{}
 
-	if err := tx.Eager("Quotes.Conversation").Eager("Quotes").Eager("Quotes.Author").Eager("Quotes.Annotation").All(conversations); err != nil {
+	if err := tx.Where("deleted_at IS NULL AND archive_id = ?", currentArchive(c).ID).Find(conversation, c.Param("conversation_id")); err != nil {
 		return c.Error(404, err)
 	}
 
-	// Redirect to the conversations index page
+	if err := models.Upvote(tx, conversation.ID, currentUserID(c)); err != nil {
+		return errors.WithStack(err)
+	}
 
-	//return c.Redirect(301, "/conversations")
+	return v.backFromVote(c, conversation)
+}
+
+// Unvote takes back the signed in user's upvote.  This function is
+// mapped to the path DELETE /conversations/{conversation_id}/vote
+func (v ConversationsResource) Unvote(c buffalo.Context) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	conversation := &models.Conversation{}
+
+	if err := tx.Where("deleted_at IS NULL AND archive_id = ?", currentArchive(c).ID).Find(conversation, c.Param("conversation_id")); err != nil {
+		return c.Error(404, err)
+	}
+
+	if err := models.Unvote(tx, conversation.ID, currentUserID(c)); err != nil {
+		return errors.WithStack(err)
+	}
+
+	return v.backFromVote(c, conversation)
+}
+
+// backFromVote sends the user back to the index if that's where they
+// voted from, otherwise to the conversation.
+func (v ConversationsResource) backFromVote(c buffalo.Context, conversation *models.Conversation) error {
+	if c.Param("from") == "index" {
+		return c.Redirect(302, "/conversations")
+	}
+
+	return c.Redirect(302, "/conversations/%s", conversation.ID)
+}
+
+// History shows every revision of the conversation, its quotes, and
+// their annotations, newest first.  The revisions keep the speakers'
+// real names, so only the archive's editors get to see them.  This
+// function is mapped to the path GET /conversations/{conversation_id}/history
+func (v ConversationsResource) History(c buffalo.Context) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	if err := requirePermission(c, models.PermEditor); err != nil {
+		return err
+	}
+
+	conversation, err := v.loadConversation(c)
+
+	if err != nil {
+		return c.Error(404, err)
+	}
+
+	if err = setHistoryPage(c, tx, conversation); err != nil {
+		return err
+	}
+
+	return c.Render(200, r.HTML("conversations/history.html"))
+}
+
+// setHistoryPage puts what the history page needs into the context
+func setHistoryPage(c buffalo.Context, tx *pop.Connection, conversation *models.Conversation) error {
+	history, err := models.ConversationHistory(tx, conversation.ID)
+
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	c.Set("conversation", conversation)
+	c.Set("history", history)
 
-	return c.Render(200, r.JSON(conversations))
+	return nil
 }
 
-// saveConversation - the user has finished adding quotes and is ready to save the conversation
-func (v ConversationsResource) saveConversation(quote *models.Quote) (*models.Conversation, *models.Quote, *validate.Errors, error) {
+// Revert puts one item in the conversation's history back the way it
+// was in the chosen revision.  Only the archive's editors can revert.
+// This function is mapped to the path
+// POST /conversations/{conversation_id}/history/{revision_id}/revert
+func (v ConversationsResource) Revert(c buffalo.Context) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	if err := requirePermission(c, models.PermEditor); err != nil {
+		return err
+	}
 
 	conversation := &models.Conversation{}
 
-	verrs, err := conversation.Create()
+	if err := tx.Where("deleted_at IS NULL AND archive_id = ?", currentArchive(c).ID).Find(conversation, c.Param("conversation_id")); err != nil {
+		return c.Error(404, err)
+	}
+
+	rev := &models.Revision{}
+
+	if err := tx.Find(rev, c.Param("revision_id")); err != nil {
+		return c.Error(404, err)
+	}
+
+	// annotations are shared, so they aren't tied to the conversation
+	if rev.ItemType != models.RevisionAnnotation && (rev.ConversationID == nil || *rev.ConversationID != conversation.ID) {
+		return c.Error(404, errors.New("revision is not part of this conversation"))
+	}
+
+	// but they are tied to the archive
+	if rev.ItemType == models.RevisionAnnotation {
+		found, err := tx.Where("id = ? AND archive_id = ?", rev.ItemID, conversation.ArchiveID).Exists(&models.Annotation{})
+
+		if err != nil {
+			return errors.WithStack(err)
+		}
+
+		if !found {
+			return c.Error(404, errors.New("revision is not part of this archive"))
+		}
+	}
+
+	verrs, err := rev.Revert(tx, currentUserID(c))
+
+	if errors.Cause(err) == sql.ErrNoRows {
+		c.Flash().Add("danger", "That item no longer exists, so it can't be reverted.")
+		return c.Redirect(302, "/conversations/%s/history", conversation.ID)
+	}
 
 	if err != nil {
-		return nil, nil, nil, errors.WithStack(err)
+		return errors.WithStack(err)
 	}
 
 	if verrs.HasAny() {
-		return nil, quote, verrs, nil
+		c.Set("errors", verrs)
+
+		if err = setHistoryPage(c, tx, conversation); err != nil {
+			return err
+		}
+
+		return c.Render(422, r.HTML("conversations/history.html"))
+	}
+
+	c.Flash().Add("success", "Revision was restored successfully")
+
+	return c.Redirect(302, "/conversations/%s/history", conversation.ID)
+}
+
+// Export dumps the database in JSON.  Authors are named the way they
+// agreed to be, unless an editor is asking.  Maps to the
+// path GET /conversations/export
+func (v ConversationsResource) Export(c buffalo.Context) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	conversations := &models.Conversations{}
+
+	// only what has made it through review ever leaves the building
+	q := tx.Eager("Quotes.Conversation").Eager("Quotes").Eager("Quotes.Author").Eager("Quotes.CoAuthors").Eager("Quotes.Annotation").Eager("Tags").Where("conversations.status = ? AND conversations.deleted_at IS NULL AND conversations.archive_id = ?", models.StatusPublished, currentArchive(c).ID)
+
+	if tag := c.Param("tag"); len(tag) > 0 {
+		q = models.FilterByTag(q, tag)
+	}
+
+	if err := q.All(conversations); err != nil {
+		return c.Error(404, err)
+	}
+
+	for i := range *conversations {
+		(*conversations)[i].Quotes = (*conversations)[i].PublishedQuotes()
+	}
+
+	shown, err := maskAuthors(c, currentArchive(c).ID, *conversations)
+
+	if err != nil {
+		return errors.WithStack(err)
 	}
 
-	return conversation, nil, nil, nil
+	// Redirect to the conversations index page
+
+	//return c.Redirect(301, "/conversations")
+
+	return c.Render(200, r.JSON(shown))
 }
 
 func (v ConversationsResource) nextQuote(c buffalo.Context) (*models.Conversation, error) {
@@ -360,6 +922,12 @@ func (v ConversationsResource) nextQuote(c buffalo.Context) (*models.Conversatio
 // loadConversation handles loading a quote for the Show() function.
 // I may push this back into the function unless I figure out a better way to print.
 func (v ConversationsResource) loadConversation(c buffalo.Context) (*models.Conversation, error) {
+	return v.findConversation(c, currentArchive(c).ID, c.Param("conversation_id"))
+}
+
+// findConversation loads the conversation with the passed id out of the
+// archive, along with everything the show page needs to draw it.
+func (v ConversationsResource) findConversation(c buffalo.Context, archive uuid.UUID, id string) (*models.Conversation, error) {
 	// Get the DB connection from the context
 	tx, ok := c.Value("tx").(*pop.Connection)
 	if !ok {
@@ -369,12 +937,38 @@ func (v ConversationsResource) loadConversation(c buffalo.Context) (*models.Conv
 
 	// Need to tell buffalo to "Eager" load all the objects contained
 	// in the conversation object.
-	// To find the Conversation the parameter conversation_id is used.
 
-	if err := tx.Eager("Quotes.Conversation").Eager("Quotes").Eager("Quotes.Author").Eager("Quotes.Annotation").Find(&conversation, c.Param("conversation_id")); err != nil {
+	// conversations in the trash can't be seen until they are restored
+	if err := tx.Eager("Quotes.Conversation").Eager("Quotes").Eager("Quotes.Author").Eager("Quotes.CoAuthors").Eager("Quotes.Annotation").Eager("Tags").Where("deleted_at IS NULL AND archive_id = ?", archive).Find(&conversation, id); err != nil {
 		return nil, c.Error(404, err)
 	}
 
+	conversation.Quotes = conversation.LiveQuotes()
+	setNotes(c, &conversation)
+
+	return &conversation, nil
+}
+
+// findDuplicates looks for conversations already in the archive with
+// lines that look a lot like the ones in conv
+func (v ConversationsResource) findDuplicates(c buffalo.Context, conv *models.Conversation) (models.Duplicates, error) {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return nil, errors.WithStack(errors.New("no transaction found"))
+	}
+
+	phrases := []string{}
+	for _, q := range conv.Quotes {
+		phrases = append(phrases, q.Phrase)
+	}
+
+	return models.FindDuplicates(tx, currentArchive(c).ID, phrases)
+}
+
+// setNotes puts the annotations for the conversation's quotes into the
+// context for the show page.
+func setNotes(c buffalo.Context, conversation *models.Conversation) {
 	// I have not yet figured out how to detect a null pointer in
 	// my plush code embedded in the HTML.  Until I do, I build
 	// a list of strings that are either empty, or contain any
@@ -390,8 +984,39 @@ func (v ConversationsResource) loadConversation(c buffalo.Context) (*models.Conv
 	}
 
 	c.Set("notes", notes)
+}
 
-	return &conversation, nil
+// seesRealNames reports if the signed in user is an editor of the
+// archive.  Editors see who really said what, whatever the authors
+// asked for the public to see.
+func seesRealNames(c buffalo.Context, archive uuid.UUID) (bool, error) {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return false, errors.WithStack(errors.New("no transaction found"))
+	}
+
+	return models.Archive{ID: archive}.Allows(tx, currentUserID(c), models.PermEditor)
+}
+
+// maskAuthors names the authors of the conversations' lines the way
+// they agreed to be named, and leaves out lines by hidden authors and
+// lines that aren't published, unless the signed in user is one of the
+// archive's editors.  Conversations left with no lines are dropped.
+func maskAuthors(c buffalo.Context, archive uuid.UUID, conversations models.Conversations) (models.Conversations, error) {
+	editor, err := seesRealNames(c, archive)
+
+	if err != nil || editor {
+		return conversations, err
+	}
+
+	published := models.Conversations{}
+	for _, cv := range conversations {
+		cv.Quotes = cv.PublishedQuotes()
+		published = append(published, cv)
+	}
+
+	return published.MaskAuthors(), nil
 }
 
 func (v ConversationsResource) loadForm(conversation *models.Conversation, c buffalo.Context) error {
@@ -408,7 +1033,7 @@ func (v ConversationsResource) loadForm(conversation *models.Conversation, c buf
 	annotation.Note = ""
 
 	// Retrieve all Authors from the DB
-	if err := tx.Order("name").All(&authors); err != nil {
+	if err := tx.Where("archive_id = ?", currentArchive(c).ID).Order("name").All(&authors); err != nil {
 		return errors.WithStack(err)
 	}
 
@@ -441,6 +1066,79 @@ func (v ConversationsResource) bindToForm(c buffalo.Context) (*models.Conversati
 	return conv, &option, nil
 }
 
+// upload is a file that came in with the conversation form, waiting for
+// the conversation to be saved before it can be attached
+type upload struct {
+	attachment *models.Attachment
+	data       []byte
+}
+
+// readAttachments pulls in the files posted in the form's "attachments"
+// field and checks each one is something that can be attached, so the
+// conversation doesn't get saved along with half its files.
+func (v ConversationsResource) readAttachments(c buffalo.Context) ([]upload, *validate.Errors, error) {
+	verrs := validate.NewErrors()
+	uploads := []upload{}
+
+	form := c.Request().MultipartForm
+
+	if form == nil {
+		return uploads, verrs, nil
+	}
+
+	for _, fh := range form.File["attachments"] {
+		f, err := fh.Open()
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		// read one byte past the limit so an oversized file fails validation
+		data, err := ioutil.ReadAll(io.LimitReader(f, models.MaxAttachmentSize+1))
+		f.Close()
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		attachment := models.NewAttachment(fh.Filename, data)
+
+		aerrs, err := attachment.Validate(nil)
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		verrs.Append(aerrs)
+		uploads = append(uploads, upload{attachment: attachment, data: data})
+	}
+
+	return uploads, verrs, nil
+}
+
+// saveAttachments attaches the uploaded files to the saved conversation
+func (v ConversationsResource) saveAttachments(c buffalo.Context, conv *models.Conversation, uploads []upload) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	for _, u := range uploads {
+		verrs, err := u.attachment.Save(tx, storage.Default(), conv.ID, u.data, currentUserID(c))
+
+		if err != nil {
+			return err
+		}
+
+		if verrs.HasAny() {
+			return errors.New(verrs.Error())
+		}
+	}
+
+	return nil
+}
+
 // if there is an annotation, add it to the quote
 func attachAnnotation(quote *models.Quote, annotation *models.Annotation) error {
 
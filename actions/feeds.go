@@ -0,0 +1,443 @@
+package actions
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/buffalo/render"
+	"github.com/gobuffalo/pop/v5"
+	"github.com/navionguy/cloudquotes/models"
+	"github.com/pkg/errors"
+)
+
+// feedSize is how many conversations a feed holds
+const feedSize = 50
+
+// feedTitleLength is how much of the first line makes it into an
+// entry's title
+const feedTitleLength = 80
+
+// the two kinds of feed on offer
+const (
+	feedAtom = "atom"
+	feedRSS  = "rss"
+)
+
+// feed is what goes into either kind of feed.  Path is where the feed
+// itself lives, Page the HTML page it follows.
+type feed struct {
+	Title         string
+	Path          string
+	Page          string
+	Conversations models.Conversations
+}
+
+// atomFeed is laid out the way RFC 4287 says
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type atomEntry struct {
+	Title      string         `xml:"title"`
+	ID         string         `xml:"id"`
+	Updated    string         `xml:"updated"`
+	Link       atomLink       `xml:"link"`
+	Authors    []atomPerson   `xml:"author"`
+	Categories []atomCategory `xml:"category"`
+	Content    atomContent    `xml:"content"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomContent struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+// rssFeed is RSS 2.0
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	GUID        rssGUID  `xml:"guid"`
+	PubDate     string   `xml:"pubDate"`
+	Categories  []string `xml:"category"`
+	Description string   `xml:"description"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	ID          string `xml:",chardata"`
+}
+
+// FeedsConversations is the feed of everything newly published in the
+// archive.  This function is mapped to the paths
+// GET /feeds/conversations.atom and GET /feeds/conversations.rss
+func FeedsConversations(format string) buffalo.Handler {
+	return func(c buffalo.Context) error {
+		q, err := feedQuery(c)
+
+		if err != nil {
+			return err
+		}
+
+		return renderFeed(c, format, q, feed{
+			Title: currentArchive(c).Name,
+			Path:  "/feeds/conversations." + format,
+			Page:  "/conversations",
+		})
+	}
+}
+
+// FeedsAuthor is the feed of what one author has said.  Like the author
+// page, only authors shown in full get one.  This function is mapped to
+// the paths GET /feeds/authors/{author_id}.atom and .rss
+func FeedsAuthor(format string) buffalo.Handler {
+	return func(c buffalo.Context) error {
+		// Get the DB connection from the context
+		tx, ok := c.Value("tx").(*pop.Connection)
+		if !ok {
+			return errors.WithStack(errors.New("no transaction found"))
+		}
+
+		spkr := &models.Author{}
+
+		if err := tx.Where("archive_id = ? AND visibility = ?", currentArchive(c).ID, models.VisibilityFull).Find(spkr, c.Param("author_id")); err != nil {
+			return c.Error(404, err)
+		}
+
+		q, err := feedQuery(c)
+
+		if err != nil {
+			return err
+		}
+
+		q = q.Where("conversations.id IN (SELECT conversation_id FROM quotes WHERE deleted_at IS NULL AND (author_id = ? OR id IN (SELECT quote_id FROM quote_authors WHERE author_id = ?)))", spkr.ID, spkr.ID)
+
+		return renderFeed(c, format, q, feed{
+			Title: currentArchive(c).Name + " - " + spkr.Name,
+			Path:  fmt.Sprintf("/feeds/authors/%s.%s", spkr.ID, format),
+			Page:  fmt.Sprintf("/authors/%s", spkr.ID),
+		})
+	}
+}
+
+// FeedsTag is the feed of the conversations with one tag.  This
+// function is mapped to the paths GET /feeds/tags/{tag}.atom and .rss
+func FeedsTag(format string) buffalo.Handler {
+	return func(c buffalo.Context) error {
+		q, err := feedQuery(c)
+
+		if err != nil {
+			return err
+		}
+
+		tag := models.NormalizeTagName(c.Param("tag"))
+
+		return renderFeed(c, format, models.FilterByTag(q, tag), feed{
+			Title: currentArchive(c).Name + " - " + tag,
+			Path:  "/feeds/tags/" + url.PathEscape(tag) + "." + format,
+			Page:  "/conversations?tag=" + url.QueryEscape(tag),
+		})
+	}
+}
+
+// feedQuery finds the published conversations in the archive, the most
+// recently changed first
+func feedQuery(c buffalo.Context) (*pop.Query, error) {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return nil, errors.WithStack(errors.New("no transaction found"))
+	}
+
+	// only what has made it through review ever leaves the building
+	q := tx.Eager("Quotes").Eager("Quotes.Author").Eager("Quotes.CoAuthors").Eager("Tags").Where("conversations.status = ? AND conversations.deleted_at IS NULL AND conversations.archive_id = ?", models.StatusPublished, currentArchive(c).ID)
+
+	return q.Order("conversations.updated_at DESC").Limit(feedSize), nil
+}
+
+// renderFeed loads the conversations and sends them back as the format
+// asked for.  A feed reader that already has the latest copy gets a 304
+// instead.
+func renderFeed(c buffalo.Context, format string, q *pop.Query, f feed) error {
+	conversations := models.Conversations{}
+
+	if err := q.All(&conversations); err != nil {
+		return errors.WithStack(err)
+	}
+
+	for i := range conversations {
+		conversations[i].Quotes = conversations[i].PublishedQuotes()
+	}
+
+	// feeds get passed around and cached, so authors are always named
+	// the way they agreed to be, even when an editor is reading
+	f.Conversations = conversations.MaskAuthors()
+
+	updated := feedUpdated(f.Conversations)
+	tag := feedETag(format, f.Conversations)
+
+	h := c.Response().Header()
+	h.Set("ETag", tag)
+	h.Set("Last-Modified", updated.UTC().Format(http.TimeFormat))
+
+	if notModified(c.Request(), tag, updated) {
+		c.Response().WriteHeader(http.StatusNotModified)
+		return nil
+	}
+
+	base := feedBaseURL(c.Request())
+
+	if format == feedRSS {
+		return c.Render(200, r.Func("application/rss+xml", func(w io.Writer, d render.Data) error {
+			return writeXML(w, f.rss(base, updated))
+		}))
+	}
+
+	return c.Render(200, r.Func("application/atom+xml", func(w io.Writer, d render.Data) error {
+		return writeXML(w, f.atom(base, updated))
+	}))
+}
+
+// writeXML sends the feed out with the XML declaration in front
+func writeXML(w io.Writer, v interface{}) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	return xml.NewEncoder(w).Encode(v)
+}
+
+// feedUpdated is when the newest change to anything in the feed was
+// made.  An empty feed is as old as can be.
+func feedUpdated(conversations models.Conversations) time.Time {
+	updated := time.Unix(0, 0)
+
+	for _, cv := range conversations {
+		if cv.UpdatedAt.After(updated) {
+			updated = cv.UpdatedAt
+		}
+	}
+
+	return updated
+}
+
+// feedETag changes whenever a conversation joins or leaves the feed, or
+// one in it is changed
+func feedETag(format string, conversations models.Conversations) string {
+	h := sha256.New()
+	io.WriteString(h, format)
+
+	for _, cv := range conversations {
+		fmt.Fprintf(h, "|%s@%d", cv.ID, cv.UpdatedAt.UnixNano())
+	}
+
+	return `W/"` + hex.EncodeToString(h.Sum(nil))[:32] + `"`
+}
+
+// notModified reports if the conditional GET headers say the client has
+// this version of the feed already.  If-None-Match wins when both are
+// sent.
+func notModified(req *http.Request, tag string, updated time.Time) bool {
+	if match := req.Header.Get("If-None-Match"); len(match) > 0 {
+		for _, m := range strings.Split(match, ",") {
+			m = strings.TrimSpace(m)
+
+			if m == "*" || strings.TrimPrefix(m, "W/") == strings.TrimPrefix(tag, "W/") {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	since, err := http.ParseTime(req.Header.Get("If-Modified-Since"))
+
+	if err != nil {
+		return false
+	}
+
+	// the header only goes down to the second
+	return !updated.Truncate(time.Second).After(since)
+}
+
+// feedBaseURL works out the scheme and host links in the feed start
+// with, going by what the proxy in front of us says when there is one
+func feedBaseURL(req *http.Request) string {
+	scheme := "http"
+
+	if req.TLS != nil {
+		scheme = "https"
+	}
+
+	if proto := req.Header.Get("X-Forwarded-Proto"); len(proto) > 0 {
+		scheme = proto
+	}
+
+	return scheme + "://" + req.Host
+}
+
+// feedEntryTitle is the start of the conversation's first line, with
+// whoever said it
+func feedEntryTitle(cv models.Conversation) string {
+	if len(cv.Quotes) == 0 {
+		return cv.Context()
+	}
+
+	q := cv.Quotes[0]
+	text := strings.Join(strings.Fields(q.Phrase), " ")
+
+	if utf8.RuneCountInString(text) > feedTitleLength {
+		text = string([]rune(text)[:feedTitleLength]) + "…"
+	}
+
+	if q.Narration() {
+		return text
+	}
+
+	return q.Credit() + ": " + text
+}
+
+// feedEntryContent lays the conversation's lines out as HTML
+func feedEntryContent(cv models.Conversation) string {
+	var b strings.Builder
+
+	if ctx := cv.Context(); len(ctx) > 0 {
+		fmt.Fprintf(&b, "<p><small>%s</small></p>", html.EscapeString(ctx))
+	}
+
+	for _, q := range cv.Quotes {
+		if q.Narration() {
+			fmt.Fprintf(&b, "<p><em>%s</em></p>", html.EscapeString(q.Phrase))
+			continue
+		}
+
+		fmt.Fprintf(&b, "<p><strong>%s:</strong> %s</p>", html.EscapeString(q.Credit()), html.EscapeString(q.Phrase))
+	}
+
+	return b.String()
+}
+
+// atom lays the feed out as Atom
+func (f feed) atom(base string, updated time.Time) atomFeed {
+	af := atomFeed{
+		Title:   f.Title,
+		ID:      base + f.Path,
+		Updated: updated.UTC().Format(time.RFC3339),
+		Links: []atomLink{
+			{Rel: "self", Type: "application/atom+xml", Href: base + f.Path},
+			{Rel: "alternate", Type: "text/html", Href: base + f.Page},
+		},
+		Entries: []atomEntry{},
+	}
+
+	for _, cv := range f.Conversations {
+		e := atomEntry{
+			Title:   feedEntryTitle(cv),
+			ID:      "urn:uuid:" + cv.ID.String(),
+			Updated: cv.UpdatedAt.UTC().Format(time.RFC3339),
+			Link:    atomLink{Rel: "alternate", Type: "text/html", Href: fmt.Sprintf("%s/conversations/%s", base, cv.ID)},
+			Content: atomContent{Type: "html", Body: feedEntryContent(cv)},
+		}
+
+		seen := map[string]bool{}
+
+		for _, q := range cv.Quotes {
+			for _, a := range q.Speakers() {
+				if !seen[a.Name] {
+					seen[a.Name] = true
+					e.Authors = append(e.Authors, atomPerson{Name: a.Name})
+				}
+			}
+		}
+
+		// an entry has to have an author, when nobody is named it's the archive's
+		if len(e.Authors) == 0 {
+			e.Authors = append(e.Authors, atomPerson{Name: f.Title})
+		}
+
+		for _, t := range cv.Tags {
+			e.Categories = append(e.Categories, atomCategory{Term: t.Name})
+		}
+
+		af.Entries = append(af.Entries, e)
+	}
+
+	return af
+}
+
+// rss lays the feed out as RSS
+func (f feed) rss(base string, updated time.Time) rssFeed {
+	rf := rssFeed{
+		Version: "2.0",
+		Channel: rssChannel{
+			Title:       f.Title,
+			Link:        base + f.Page,
+			Description: f.Title,
+			Items:       []rssItem{},
+		},
+	}
+
+	if len(f.Conversations) > 0 {
+		rf.Channel.LastBuildDate = updated.UTC().Format(time.RFC1123Z)
+	}
+
+	for _, cv := range f.Conversations {
+		item := rssItem{
+			Title:       feedEntryTitle(cv),
+			Link:        fmt.Sprintf("%s/conversations/%s", base, cv.ID),
+			GUID:        rssGUID{ID: "urn:uuid:" + cv.ID.String()},
+			PubDate:     cv.UpdatedAt.UTC().Format(time.RFC1123Z),
+			Description: feedEntryContent(cv),
+		}
+
+		for _, t := range cv.Tags {
+			item.Categories = append(item.Categories, t.Name)
+		}
+
+		rf.Channel.Items = append(rf.Channel.Items, item)
+	}
+
+	return rf
+}
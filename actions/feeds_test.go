@@ -0,0 +1,71 @@
+package actions
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/navionguy/cloudquotes/models"
+)
+
+func (as *ActionSuite) Test_Feeds_Conversations() {
+	res := as.HTML("/feeds/conversations.atom").Get()
+	as.Equal(200, res.Code)
+	as.Contains(res.Header().Get("Content-Type"), "application/atom+xml")
+	as.Contains(res.Body.String(), "<feed xmlns=\"http://www.w3.org/2005/Atom\">")
+
+	// a reader with the latest copy gets nothing new
+	req := as.HTML("/feeds/conversations.atom")
+	req.Headers["If-None-Match"] = res.Header().Get("ETag")
+	as.Equal(304, req.Get().Code)
+
+	res = as.HTML("/feeds/conversations.rss").Get()
+	as.Equal(200, res.Code)
+	as.Contains(res.Header().Get("Content-Type"), "application/rss+xml")
+}
+
+func (as *ActionSuite) Test_Feeds_Author_NotFound() {
+	res := as.HTML("/feeds/authors/563cd207-ab16-4a46-b44e-7317b96c6ba9.atom").Get()
+	as.Equal(404, res.Code)
+}
+
+func (as *ActionSuite) Test_Feeds_NotModified() {
+	updated := time.Date(2021, time.January, 26, 12, 0, 0, 500, time.UTC)
+	req, _ := http.NewRequest("GET", "/feeds/conversations.atom", nil)
+
+	as.False(notModified(req, `W/"abc"`, updated))
+
+	req.Header.Set("If-Modified-Since", updated.Format(http.TimeFormat))
+	as.True(notModified(req, `W/"abc"`, updated))
+
+	req.Header.Set("If-Modified-Since", updated.Add(-time.Hour).Format(http.TimeFormat))
+	as.False(notModified(req, `W/"abc"`, updated))
+
+	// the ETag wins over the date
+	req.Header.Set("If-None-Match", `"xyz", W/"abc"`)
+	as.True(notModified(req, `W/"abc"`, updated))
+
+	req.Header.Set("If-None-Match", `W/"xyz"`)
+	req.Header.Set("If-Modified-Since", updated.Format(http.TimeFormat))
+	as.False(notModified(req, `W/"abc"`, updated))
+}
+
+func (as *ActionSuite) Test_Feeds_EntryContent() {
+	author := models.Author{ID: uuid.Must(uuid.NewV4()), Name: "Bob <b>"}
+	cv := models.Conversation{
+		ID: uuid.Must(uuid.NewV4()),
+		Quotes: models.Quotes{
+			{Phrase: "Is this thing on?", AuthorID: &author.ID, Author: author},
+			{Phrase: "feedback squeals"},
+		},
+	}
+
+	content := feedEntryContent(cv)
+	as.Contains(content, "<strong>Bob &lt;b&gt;:</strong> Is this thing on?")
+	as.Contains(content, "<em>feedback squeals</em>")
+	as.Equal("Bob <b>: Is this thing on?", feedEntryTitle(cv))
+
+	cv.Quotes[0].Phrase = strings.Repeat("a", feedTitleLength+10)
+	as.Equal("Bob <b>: "+strings.Repeat("a", feedTitleLength)+"…", feedEntryTitle(cv))
+}
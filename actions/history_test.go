@@ -0,0 +1,25 @@
+package actions
+
+func (as *ActionSuite) Test_History_SignedOut() {
+	res := as.HTML("/conversations/563cd207-ab16-4a46-b44e-7317b96c6ba9/history").Get()
+	as.Equal(302, res.Code)
+}
+
+func (as *ActionSuite) Test_History_NotEditor() {
+	as.signIn()
+
+	res := as.HTML("/conversations/563cd207-ab16-4a46-b44e-7317b96c6ba9/history").Get()
+	as.Equal(403, res.Code)
+}
+
+func (as *ActionSuite) Test_Revert_SignedOut() {
+	res := as.HTML("/conversations/563cd207-ab16-4a46-b44e-7317b96c6ba9/history/563cd207-ab16-4a46-b44e-7317b96c6ba9/revert").Post(nil)
+	as.Equal(302, res.Code)
+}
+
+func (as *ActionSuite) Test_Revert_NotEditor() {
+	as.signIn()
+
+	res := as.HTML("/conversations/563cd207-ab16-4a46-b44e-7317b96c6ba9/history/563cd207-ab16-4a46-b44e-7317b96c6ba9/revert").Post(nil)
+	as.Equal(403, res.Code)
+}
@@ -0,0 +1,76 @@
+package actions
+
+import (
+	"strconv"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/pop/v5"
+	"github.com/navionguy/cloudquotes/models"
+	"github.com/pkg/errors"
+)
+
+// leaderboardSize is how many conversations make the leaderboard unless
+// the "limit" param asks for more or less.
+const leaderboardSize = 25
+
+// LeaderboardHandler ranks the most loved conversations by their
+// upvotes.  The "year" param narrows it down to the conversations from
+// that year, which is how the quote of the year gets picked, otherwise
+// it's over all time.  Ask for JSON to get the standings without the
+// page.  Mapped to GET /leaderboard
+func LeaderboardHandler(c buffalo.Context) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	year := 0
+
+	if y := c.Param("year"); len(y) > 0 {
+		var err error
+		if year, err = strconv.Atoi(y); err != nil {
+			return c.Error(400, err)
+		}
+	}
+
+	limit := leaderboardSize
+
+	if l := c.Param("limit"); len(l) > 0 {
+		var err error
+		if limit, err = strconv.Atoi(l); err != nil || limit < 1 {
+			return c.Error(400, errors.New("limit must be a positive number"))
+		}
+	}
+
+	standings, err := models.Leaderboard(tx, currentArchive(c).ID, year, limit)
+
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	editor, err := seesRealNames(c, currentArchive(c).ID)
+
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	// the votes still count for lines by hidden authors, they just
+	// aren't shown
+	if !editor {
+		for i := range standings {
+			standings[i].Conversation = standings[i].Conversation.MaskAuthors()
+		}
+	}
+
+	years, err := models.LeaderboardYears(tx, currentArchive(c).ID)
+
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	c.Set("year", year)
+	c.Set("years", years)
+
+	return c.Render(200, r.Auto(c, standings))
+}
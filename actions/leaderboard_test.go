@@ -0,0 +1,16 @@
+package actions
+
+func (as *ActionSuite) Test_LeaderboardHandler() {
+	res := as.HTML("/leaderboard").Get()
+	as.Equal(200, res.Code)
+}
+
+func (as *ActionSuite) Test_LeaderboardHandler_JSON() {
+	res := as.JSON("/leaderboard?year=2020").Get()
+	as.Equal(200, res.Code)
+}
+
+func (as *ActionSuite) Test_Vote_SignedOut() {
+	res := as.HTML("/conversations/563cd207-ab16-4a46-b44e-7317b96c6ba9/vote").Post(nil)
+	as.Equal(302, res.Code)
+}
@@ -0,0 +1,90 @@
+package actions
+
+import (
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/pop/v5"
+	"github.com/gofrs/uuid"
+	"github.com/navionguy/cloudquotes/models"
+	"github.com/pkg/errors"
+)
+
+// QotdHandler shows the quote of the day.  Everybody who asks on the
+// same day gets the same conversation, so the office screens and the
+// chat bot stay in step.  The "collection" param, a collection's share
+// token, plays that collection instead of the whole archive.
+// Mapped to GET /qotd
+func QotdHandler(c buffalo.Context) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	archive := currentArchive(c).ID
+
+	var id uuid.UUID
+	var err error
+
+	if token := c.Param("collection"); len(token) > 0 {
+		var collection *models.Collection
+		collection, err = models.FindSharedCollection(tx, token)
+
+		if err != nil || !collection.VisibleTo(currentUserID(c)) {
+			return c.Error(404, errors.New("no such collection"))
+		}
+
+		archive = collection.ArchiveID
+		id, err = collection.QuoteOfTheDay(tx)
+	} else {
+		id, err = models.QuoteOfTheDay(tx, archive)
+	}
+
+	if err != nil {
+		if errors.Cause(err) == models.ErrEmptyDeck {
+			return c.Error(404, err)
+		}
+		return errors.WithStack(err)
+	}
+
+	v := ConversationsResource{}
+	conversation, err := v.findConversation(c, archive, id.String())
+
+	if err != nil {
+		return c.Error(404, err)
+	}
+
+	// lines that weren't cleared for publishing stay off the wall
+	conversation.Quotes = conversation.PublishedQuotes()
+
+	shown, err := maskAuthors(c, archive, models.Conversations{*conversation})
+
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	if len(shown) == 0 {
+		return c.Error(404, errors.New("nothing to show today"))
+	}
+
+	*conversation = shown[0]
+	setNotes(c, conversation)
+
+	if err = v.setVotes(c, models.Conversations{*conversation}); err != nil {
+		return errors.WithStack(err)
+	}
+
+	if err = v.setComments(c, conversation); err != nil {
+		return errors.WithStack(err)
+	}
+
+	if err = v.setAttachments(c, conversation); err != nil {
+		return errors.WithStack(err)
+	}
+
+	if err = v.setCollections(c); err != nil {
+		return errors.WithStack(err)
+	}
+
+	c.Set("fontsize", fontSizeFor(conversation))
+	return c.Render(200, r.Auto(c, conversation))
+}
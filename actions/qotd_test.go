@@ -0,0 +1,9 @@
+package actions
+
+func (as *ActionSuite) Test_QotdHandler_EmptyArchive() {
+	res := as.HTML("/qotd").Get()
+	as.Equal(404, res.Code)
+
+	jres := as.JSON("/qotd").Get()
+	as.Equal(404, jres.Code)
+}
@@ -0,0 +1,59 @@
+package actions
+
+import (
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/pop/v5"
+	"github.com/navionguy/cloudquotes/models"
+	"github.com/pkg/errors"
+)
+
+// ReviewIndex is the editors' review queue.  It lists the conversations
+// waiting on a decision, oldest first, so nothing sits forgotten at the
+// bottom.  The "status" param switches to listing everything in that
+// status instead, which is how editors find published conversations to
+// pull back.  This function is mapped to the path GET /review
+func ReviewIndex(c buffalo.Context) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	editor, err := currentArchive(c).Allows(tx, currentUserID(c), models.PermEditor)
+
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	if !editor {
+		return c.Error(403, errors.New("only editors can review conversations"))
+	}
+
+	conversations := &models.Conversations{}
+
+	// Paginate results. Params "page" and "per_page" control pagination.
+	// Default values are "page=1" and "per_page=20".
+	q := tx.Eager("Quotes").Eager("Quotes.Author").Eager("Quotes.CoAuthors").PaginateFromParams(c.Params()).Where("deleted_at IS NULL AND archive_id = ?", currentArchive(c).ID)
+
+	status := c.Param("status")
+
+	if len(status) > 0 {
+		q = q.Where("status = ?", status)
+	} else {
+		q = q.Where("status IN (?, ?)", models.StatusSubmitted, models.StatusApproved)
+	}
+
+	if err := q.Order("updated_at").All(conversations); err != nil {
+		return errors.WithStack(err)
+	}
+
+	conversations.DropRemovedQuotes()
+
+	c.Set("conversations", conversations)
+	c.Set("status", status)
+
+	// Add the paginator to the context so it can be used in the template.
+	c.Set("pagination", q.Paginator)
+
+	return c.Render(200, r.HTML("review/index.html"))
+}
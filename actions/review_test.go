@@ -0,0 +1,49 @@
+package actions
+
+import (
+	"time"
+
+	"github.com/navionguy/cloudquotes/models"
+)
+
+func (as *ActionSuite) Test_ReviewIndex_SignedOut() {
+	res := as.HTML("/review").Get()
+	as.Equal(302, res.Code)
+}
+
+func (as *ActionSuite) Test_ConversationsShow_Unpublished() {
+	archive, err := models.DefaultArchive(as.DB)
+	as.NoError(err)
+
+	cv := &models.Conversation{ArchiveID: archive.ID, OccurredOn: time.Now(), Status: models.StatusDraft}
+	as.NoError(as.DB.Create(cv))
+
+	// only the editors see what hasn't been published
+	res := as.HTML("/conversations/%s", cv.ID).Get()
+	as.Equal(404, res.Code)
+}
+
+// lines that aren't published stay out of a published conversation
+func (as *ActionSuite) Test_Conversations_UnpublishedLines() {
+	archive, err := models.DefaultArchive(as.DB)
+	as.NoError(err)
+
+	author := &models.Author{Name: "Bob", ArchiveID: archive.ID, Visibility: models.VisibilityFull}
+	as.NoError(as.DB.Create(author))
+
+	cv := &models.Conversation{ArchiveID: archive.ID, OccurredOn: time.Now(), Status: models.StatusPublished, Publish: true}
+	as.NoError(as.DB.Create(cv))
+
+	as.NoError(as.DB.Create(&models.Quote{ConversationID: cv.ID, AuthorID: &author.ID, SaidOn: time.Now(), Sequence: 0, Phrase: "Ship it.", Publish: true}))
+	as.NoError(as.DB.Create(&models.Quote{ConversationID: cv.ID, AuthorID: &author.ID, SaidOn: time.Now(), Sequence: 1, Phrase: "Off the record.", Publish: false}))
+
+	res := as.HTML("/conversations/%s", cv.ID).Get()
+	as.Equal(200, res.Code)
+	as.Contains(res.Body.String(), "Ship it.")
+	as.NotContains(res.Body.String(), "Off the record.")
+
+	res = as.HTML("/conversations").Get()
+	as.Equal(200, res.Code)
+	as.Contains(res.Body.String(), "Ship it.")
+	as.NotContains(res.Body.String(), "Off the record.")
+}
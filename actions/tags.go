@@ -0,0 +1,150 @@
+package actions
+
+import (
+	"strings"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/pop/v5"
+	"github.com/navionguy/cloudquotes/models"
+	"github.com/pkg/errors"
+)
+
+// TagsResource is the resource for the Tag model.  Tags get created by
+// typing them into the conversation form, so all this does is let you
+// look them over, rename them, and merge the duplicates.
+type TagsResource struct {
+	buffalo.Resource
+}
+
+// List all the known tags and how many conversations carry each.
+// This function is mapped to the path GET /tags
+func (v TagsResource) List(c buffalo.Context) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	// Paginate results. Params "page" and "per_page" control pagination.
+	// Default values are "page=1" and "per_page=20".
+	cq := tx.PaginateFromParams(c.Params()).RawQuery("SELECT tags.id, tags.name, COUNT(DISTINCT conversations.id) FROM tags LEFT JOIN conversations_tags ON conversations_tags.tag_id = tags.id LEFT JOIN conversations ON conversations.id = conversations_tags.conversation_id AND conversations.deleted_at IS NULL WHERE tags.archive_id = ? GROUP BY tags.id ORDER BY tags.name", currentArchive(c).ID)
+
+	tagCredits := &models.TagCredits{}
+
+	if err := cq.All(tagCredits); err != nil {
+		return errors.WithStack(err)
+	}
+
+	// Add the paginator to the context so it can be used in the template.
+	c.Set("pagination", cq.Paginator)
+
+	return c.Render(200, r.Auto(c, tagCredits))
+}
+
+// Edit renders the rename/merge form for a Tag. This function is
+// mapped to the path GET /tags/{tag_id}/edit
+func (v TagsResource) Edit(c buffalo.Context) error {
+	tag, err := v.loadTag(c)
+
+	if err != nil {
+		return c.Error(404, err)
+	}
+
+	if err = v.loadForm(tag, c); err != nil {
+		return errors.WithStack(err)
+	}
+
+	return c.Render(200, r.Auto(c, tag))
+}
+
+// Update renames a Tag, or merges it into another one.  Renaming a tag
+// to a name that is already taken merges the two.  This function is
+// mapped to the path PUT /tags/{tag_id}
+func (v TagsResource) Update(c buffalo.Context) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	tag, err := v.loadTag(c)
+
+	if err != nil {
+		return c.Error(404, err)
+	}
+
+	if into := c.Param("merge_into"); len(into) > 0 {
+		target := &models.Tag{}
+
+		if err := tx.Where("archive_id = ?", tag.ArchiveID).Find(target, into); err != nil {
+			return c.Error(404, err)
+		}
+
+		if err := tag.MergeInto(tx, target); err != nil {
+			return errors.WithStack(err)
+		}
+
+		c.Flash().Add("success", "Tag was merged successfully")
+
+		return c.Redirect(302, "/tags")
+	}
+
+	verrs, err := tag.Rename(tx, strings.TrimSpace(c.Param("name")))
+
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	if verrs.HasAny() {
+		if err = v.loadForm(tag, c); err != nil {
+			return errors.WithStack(err)
+		}
+
+		// set the verification errors into the context and send back the tag
+		c.Set("errors", verrs)
+
+		return c.Render(422, r.Auto(c, tag))
+	}
+
+	c.Flash().Add("success", "Tag was updated successfully")
+
+	return c.Redirect(302, "/tags")
+}
+
+// loadTag finds the tag named by the tag_id parameter
+func (v TagsResource) loadTag(c buffalo.Context) (*models.Tag, error) {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return nil, errors.WithStack(errors.New("no transaction found"))
+	}
+
+	tag := &models.Tag{}
+
+	if err := tx.Where("archive_id = ?", currentArchive(c).ID).Find(tag, c.Param("tag_id")); err != nil {
+		return nil, err
+	}
+
+	return tag, nil
+}
+
+// loadForm sets up everything the edit form needs, which is the tag
+// and the list of other tags it could be merged into.
+func (v TagsResource) loadForm(tag *models.Tag, c buffalo.Context) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	others := models.Tags{}
+
+	if err := tx.Where("id != ? AND archive_id = ?", tag.ID, tag.ArchiveID).Order("name").All(&others); err != nil {
+		return errors.WithStack(err)
+	}
+
+	c.Set("tag", tag)
+	c.Set("tags", others)
+
+	return nil
+}
@@ -0,0 +1,125 @@
+package actions
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/pop/v5"
+	"github.com/navionguy/cloudquotes/models"
+	"github.com/pkg/errors"
+)
+
+// tokenLifetimes are how many days a new token can be set to last, zero
+// is until it gets revoked
+var tokenLifetimes = []int{30, 90, 365, 0}
+
+// setTokensPage puts what the token settings page needs into the context
+func setTokensPage(c buffalo.Context, tx *pop.Connection, token *models.APIToken) error {
+	tokens, err := models.UserAPITokens(tx, currentUserID(c))
+
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	c.Set("tokens", tokens)
+	c.Set("token", token)
+	c.Set("scopes", models.Scopes)
+	c.Set("lifetimes", tokenLifetimes)
+	c.Set("now", time.Now())
+
+	return nil
+}
+
+// TokensIndex lists the signed in user's API tokens, along with the form
+// for issuing a new one.  This function is mapped to the path
+// GET /settings/tokens
+func TokensIndex(c buffalo.Context) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	if err := setTokensPage(c, tx, &models.APIToken{Scopes: models.ScopeRead}); err != nil {
+		return err
+	}
+
+	return c.Render(200, r.HTML("tokens/index.html"))
+}
+
+// TokensCreate issues a new API token to the signed in user.  The token
+// itself is only shown on the page that comes back, it isn't kept
+// anywhere it could be shown again.  This function is mapped to the path
+// POST /settings/tokens
+func TokensCreate(c buffalo.Context) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	if err := c.Request().ParseForm(); err != nil {
+		return errors.WithStack(err)
+	}
+
+	token := &models.APIToken{
+		UserID: currentUserID(c),
+		Name:   strings.TrimSpace(c.Param("name")),
+		Scopes: strings.Join(c.Request().Form["scopes"], ","),
+	}
+
+	if days, err := strconv.Atoi(c.Param("days")); err == nil && days > 0 {
+		expires := time.Now().AddDate(0, 0, days)
+		token.ExpiresAt = &expires
+	}
+
+	secret, verrs, err := token.Issue(tx)
+
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	if verrs.HasAny() {
+		c.Set("errors", verrs)
+
+		if err = setTokensPage(c, tx, token); err != nil {
+			return err
+		}
+
+		return c.Render(422, r.HTML("tokens/index.html"))
+	}
+
+	if err = setTokensPage(c, tx, &models.APIToken{Scopes: models.ScopeRead}); err != nil {
+		return err
+	}
+
+	c.Set("secret", secret)
+
+	return c.Render(201, r.HTML("tokens/index.html"))
+}
+
+// TokensRevoke stops one of the signed in user's tokens from working.
+// This function is mapped to the path DELETE /settings/tokens/{token_id}
+func TokensRevoke(c buffalo.Context) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	token := &models.APIToken{}
+
+	if err := tx.Where("user_id = ?", currentUserID(c)).Find(token, c.Param("token_id")); err != nil {
+		return c.Error(404, err)
+	}
+
+	if err := token.Revoke(tx); err != nil {
+		return errors.WithStack(err)
+	}
+
+	c.Flash().Add("success", "Token was revoked")
+
+	return c.Redirect(302, "/settings/tokens")
+}
@@ -0,0 +1,9 @@
+package actions
+
+func (as *ActionSuite) Test_Tokens_SignedOut() {
+	res := as.HTML("/settings/tokens").Get()
+	as.Equal(302, res.Code)
+
+	res = as.HTML("/settings/tokens").Post(map[string]string{"name": "script"})
+	as.Equal(302, res.Code)
+}
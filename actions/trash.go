@@ -0,0 +1,112 @@
+package actions
+
+import (
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/pop/v5"
+	"github.com/gofrs/uuid"
+	"github.com/navionguy/cloudquotes/models"
+	"github.com/pkg/errors"
+)
+
+// TrashIndex lists the conversations sitting in the trash, most recently
+// deleted first.  Only editors get to see the trash.  This function is
+// mapped to the path GET /trash
+func TrashIndex(c buffalo.Context) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	if err := requirePermission(c, models.PermEditor); err != nil {
+		return err
+	}
+
+	trashed := &models.Conversations{}
+
+	// Paginate results. Params "page" and "per_page" control pagination.
+	// Default values are "page=1" and "per_page=20".
+	q := tx.Eager("Quotes").Eager("Quotes.Author").Eager("Quotes.CoAuthors").PaginateFromParams(c.Params()).Where("deleted_at IS NOT NULL AND archive_id = ?", currentArchive(c).ID).Order("deleted_at DESC")
+
+	if err := q.All(trashed); err != nil {
+		return errors.WithStack(err)
+	}
+
+	trashed.DropRemovedQuotes()
+
+	c.Set("trashed", trashed)
+
+	// Add the paginator to the context so it can be used in the template.
+	c.Set("pagination", q.Paginator)
+
+	return c.Render(200, r.HTML("trash/index.html"))
+}
+
+// TrashRestore takes a conversation back out of the trash, which only an
+// editor can do.  This function is mapped to the path
+// POST /trash/{conversation_id}/restore
+func TrashRestore(c buffalo.Context) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	if err := requirePermission(c, models.PermEditor); err != nil {
+		return err
+	}
+
+	conversation, err := findTrashed(tx, currentArchive(c).ID, c.Param("conversation_id"))
+
+	if err != nil {
+		return c.Error(404, err)
+	}
+
+	if err = conversation.Restore(tx, currentUserID(c)); err != nil {
+		return errors.WithStack(err)
+	}
+
+	c.Flash().Add("success", "Conversation was restored successfully")
+
+	return c.Redirect(302, "/trash")
+}
+
+// TrashPurge removes a trashed conversation for good.  There's no
+// getting it back, so it takes a moderator.  This function is mapped to
+// the path DELETE /trash/{conversation_id}
+func TrashPurge(c buffalo.Context) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	if err := requirePermission(c, models.PermModerator); err != nil {
+		return err
+	}
+
+	conversation, err := findTrashed(tx, currentArchive(c).ID, c.Param("conversation_id"))
+
+	if err != nil {
+		return c.Error(404, err)
+	}
+
+	if err = conversation.Purge(tx); err != nil {
+		return errors.WithStack(err)
+	}
+
+	c.Flash().Add("success", "Conversation was purged for good")
+
+	return c.Redirect(302, "/trash")
+}
+
+// findTrashed loads a conversation, but only if it is in the archive's
+// trash
+func findTrashed(tx *pop.Connection, archive uuid.UUID, id string) (*models.Conversation, error) {
+	conversation := &models.Conversation{}
+
+	err := tx.Eager("Quotes", "Tags").Where("deleted_at IS NOT NULL AND archive_id = ?", archive).Find(conversation, id)
+	conversation.Quotes = conversation.LiveQuotes()
+
+	return conversation, err
+}
@@ -0,0 +1,53 @@
+package actions
+
+import "github.com/navionguy/cloudquotes/models"
+
+func (as *ActionSuite) Test_TrashIndex() {
+	as.signIn(models.PermEditor)
+
+	res := as.HTML("/trash").Get()
+	as.Equal(200, res.Code)
+}
+
+func (as *ActionSuite) Test_TrashRestore_NotFound() {
+	as.signIn(models.PermEditor)
+
+	res := as.HTML("/trash/563cd207-ab16-4a46-b44e-7317b96c6ba9/restore").Post(nil)
+	as.Equal(404, res.Code)
+}
+
+func (as *ActionSuite) Test_Trash_SignedOut() {
+	res := as.HTML("/trash").Get()
+	as.Equal(302, res.Code)
+
+	res = as.HTML("/trash/563cd207-ab16-4a46-b44e-7317b96c6ba9/restore").Post(nil)
+	as.Equal(302, res.Code)
+
+	res = as.HTML("/trash/563cd207-ab16-4a46-b44e-7317b96c6ba9").Delete()
+	as.Equal(302, res.Code)
+}
+
+func (as *ActionSuite) Test_Trash_NotEditor() {
+	as.signIn()
+
+	res := as.HTML("/trash").Get()
+	as.Equal(403, res.Code)
+
+	res = as.HTML("/trash/563cd207-ab16-4a46-b44e-7317b96c6ba9/restore").Post(nil)
+	as.Equal(403, res.Code)
+
+	res = as.HTML("/trash/563cd207-ab16-4a46-b44e-7317b96c6ba9").Delete()
+	as.Equal(403, res.Code)
+}
+
+func (as *ActionSuite) Test_TrashPurge_NeedsModerator() {
+	as.signIn(models.PermEditor)
+
+	res := as.HTML("/trash/563cd207-ab16-4a46-b44e-7317b96c6ba9").Delete()
+	as.Equal(403, res.Code)
+
+	as.signIn(models.PermModerator)
+
+	res = as.HTML("/trash/563cd207-ab16-4a46-b44e-7317b96c6ba9").Delete()
+	as.Equal(404, res.Code)
+}
@@ -3,6 +3,7 @@ package actions
 import (
 	"github.com/gobuffalo/buffalo"
 	"github.com/gobuffalo/pop/v5"
+	"github.com/gofrs/uuid"
 	"github.com/navionguy/cloudquotes/models"
 	"github.com/pkg/errors"
 )
@@ -56,6 +57,21 @@ func SetCurrentUser(next buffalo.Handler) buffalo.Handler {
 	}
 }
 
+// currentUserID returns the ID of the logged in user, or uuid.Nil when
+// nobody is logged in.  API requests have no session, the user their
+// token belongs to is in the context instead.
+func currentUserID(c buffalo.Context) uuid.UUID {
+	if u, ok := c.Value("current_user").(*models.User); ok {
+		return u.ID
+	}
+
+	if uid, ok := c.Session().Get("current_user_id").(uuid.UUID); ok {
+		return uid
+	}
+
+	return uuid.Nil
+}
+
 // Authorize require a user be logged in before accessing a route
 func Authorize(next buffalo.Handler) buffalo.Handler {
 	return func(c buffalo.Context) error {
@@ -0,0 +1,301 @@
+package actions
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/buffalo/worker"
+	"github.com/gobuffalo/pop/v5"
+	"github.com/navionguy/cloudquotes/models"
+	"github.com/pkg/errors"
+)
+
+// webhookJob is the name the delivery job is registered under
+const webhookJob = "deliver_webhooks"
+
+// webhookInterval is how often the delivery job looks for deliveries
+// that are due
+const webhookInterval = 30 * time.Second
+
+// webhookClient sends the deliveries.  Receivers get a few seconds to
+// answer, a slow one gets tried again later like any other failure.  It
+// only connects to public addresses, whatever the webhook's URL resolves
+// to by the time it is sent.
+var webhookClient = &http.Client{
+	Timeout: 10 * time.Second,
+	Transport: &http.Transport{
+		DialContext: models.WebhookDialer(10 * time.Second).DialContext,
+	},
+}
+
+// startWebhookDelivery registers the job that sends webhook deliveries
+// and gets it going.  The job lines itself back up every time it runs.
+func startWebhookDelivery(w worker.Worker) error {
+	job := worker.Job{Handler: webhookJob}
+
+	err := w.Register(webhookJob, func(worker.Args) error {
+		defer w.PerformIn(job, webhookInterval)
+
+		_, err := models.DeliverWebhooks(models.DB, webhookClient, time.Now())
+
+		return err
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return w.PerformIn(job, webhookInterval)
+}
+
+// loadAdminArchive finds the archive in the path, turning away anyone
+// who isn't one of its admins
+func loadAdminArchive(c buffalo.Context) (*models.Archive, error) {
+	archive, role, err := loadMemberArchive(c)
+
+	if err != nil {
+		return nil, c.Error(404, err)
+	}
+
+	if role != models.RoleAdmin {
+		return nil, c.Error(403, errors.New("only an archive's admins can manage its webhooks"))
+	}
+
+	return archive, nil
+}
+
+// loadWebhook finds the webhook in the path, as long as it belongs to
+// the archive
+func loadWebhook(c buffalo.Context, tx *pop.Connection, archive *models.Archive) (*models.Webhook, error) {
+	hook := &models.Webhook{}
+
+	if err := tx.Where("archive_id = ?", archive.ID).Find(hook, c.Param("webhook_id")); err != nil {
+		return nil, c.Error(404, err)
+	}
+
+	return hook, nil
+}
+
+// setWebhooksPage puts what the webhook list needs into the context
+func setWebhooksPage(c buffalo.Context, tx *pop.Connection, archive *models.Archive, hook *models.Webhook) error {
+	hooks := models.Webhooks{}
+
+	if err := tx.Where("archive_id = ?", archive.ID).Order("created_at ASC").All(&hooks); err != nil {
+		return errors.WithStack(err)
+	}
+
+	c.Set("archive", archive)
+	c.Set("webhooks", hooks)
+	c.Set("webhook", hook)
+	c.Set("events", models.Events)
+
+	return nil
+}
+
+// WebhooksIndex lists the archive's webhooks, along with the form for
+// adding one.  This function is mapped to the path
+// GET /archives/{archive_id}/webhooks
+func WebhooksIndex(c buffalo.Context) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	archive, err := loadAdminArchive(c)
+
+	if err != nil {
+		return err
+	}
+
+	if err = setWebhooksPage(c, tx, archive, &models.Webhook{}); err != nil {
+		return err
+	}
+
+	return c.Render(200, r.HTML("webhooks/index.html"))
+}
+
+// WebhooksCreate adds a webhook to the archive.  This function is mapped
+// to the path POST /archives/{archive_id}/webhooks
+func WebhooksCreate(c buffalo.Context) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	archive, err := loadAdminArchive(c)
+
+	if err != nil {
+		return err
+	}
+
+	if err = c.Request().ParseForm(); err != nil {
+		return errors.WithStack(err)
+	}
+
+	hook := &models.Webhook{
+		ArchiveID: archive.ID,
+		URL:       strings.TrimSpace(c.Param("url")),
+		Events:    strings.Join(c.Request().Form["events"], ","),
+	}
+
+	verrs, err := hook.Create(tx)
+
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	if verrs.HasAny() {
+		c.Set("errors", verrs)
+
+		if err = setWebhooksPage(c, tx, archive, hook); err != nil {
+			return err
+		}
+
+		return c.Render(422, r.HTML("webhooks/index.html"))
+	}
+
+	c.Flash().Add("success", "Webhook was added successfully")
+
+	return c.Redirect(302, "/archives/%s/webhooks/%s", archive.ID, hook.ID)
+}
+
+// WebhooksShow shows a webhook's secret and the log of what was sent to
+// it.  This function is mapped to the path
+// GET /archives/{archive_id}/webhooks/{webhook_id}
+func WebhooksShow(c buffalo.Context) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	archive, err := loadAdminArchive(c)
+
+	if err != nil {
+		return err
+	}
+
+	hook, err := loadWebhook(c, tx, archive)
+
+	if err != nil {
+		return err
+	}
+
+	deliveries := models.WebhookDeliveries{}
+
+	q := tx.Where("webhook_id = ?", hook.ID).Order("created_at DESC").PaginateFromParams(c.Params())
+
+	if err = q.All(&deliveries); err != nil {
+		return errors.WithStack(err)
+	}
+
+	c.Set("archive", archive)
+	c.Set("webhook", hook)
+	c.Set("deliveries", deliveries)
+	c.Set("pagination", q.Paginator)
+
+	return c.Render(200, r.HTML("webhooks/show.html"))
+}
+
+// WebhooksToggle switches a webhook off, or back on.  Deliveries for a
+// webhook that is off wait until it is back on.  This function is mapped
+// to the path POST /archives/{archive_id}/webhooks/{webhook_id}/toggle
+func WebhooksToggle(c buffalo.Context) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	archive, err := loadAdminArchive(c)
+
+	if err != nil {
+		return err
+	}
+
+	hook, err := loadWebhook(c, tx, archive)
+
+	if err != nil {
+		return err
+	}
+
+	hook.Active = !hook.Active
+
+	if err = tx.Update(hook); err != nil {
+		return errors.WithStack(err)
+	}
+
+	return c.Redirect(302, "/archives/%s/webhooks/%s", archive.ID, hook.ID)
+}
+
+// WebhooksDestroy removes a webhook, and its delivery log with it.  This
+// function is mapped to the path
+// DELETE /archives/{archive_id}/webhooks/{webhook_id}
+func WebhooksDestroy(c buffalo.Context) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	archive, err := loadAdminArchive(c)
+
+	if err != nil {
+		return err
+	}
+
+	hook, err := loadWebhook(c, tx, archive)
+
+	if err != nil {
+		return err
+	}
+
+	if err = tx.Destroy(hook); err != nil {
+		return errors.WithStack(err)
+	}
+
+	c.Flash().Add("success", "Webhook was removed")
+
+	return c.Redirect(302, "/archives/%s/webhooks", archive.ID)
+}
+
+// WebhooksRedeliver sends a delivery again, say once the receiver has
+// been fixed.  This function is mapped to the path
+// POST /archives/{archive_id}/webhooks/{webhook_id}/deliveries/{delivery_id}/redeliver
+func WebhooksRedeliver(c buffalo.Context) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	archive, err := loadAdminArchive(c)
+
+	if err != nil {
+		return err
+	}
+
+	hook, err := loadWebhook(c, tx, archive)
+
+	if err != nil {
+		return err
+	}
+
+	d := &models.WebhookDelivery{}
+
+	if err = tx.Where("webhook_id = ?", hook.ID).Find(d, c.Param("delivery_id")); err != nil {
+		return c.Error(404, err)
+	}
+
+	if err = d.Redeliver(tx); err != nil {
+		return errors.WithStack(err)
+	}
+
+	c.Flash().Add("success", "Delivery will be sent again shortly")
+
+	return c.Redirect(302, "/archives/%s/webhooks/%s", archive.ID, hook.ID)
+}
@@ -0,0 +1,82 @@
+package actions
+
+import (
+	"github.com/navionguy/cloudquotes/models"
+)
+
+func (as *ActionSuite) Test_Webhooks_SignedOut() {
+	res := as.HTML("/archives/563cd207-ab16-4a46-b44e-7317b96c6ba9/webhooks").Get()
+	as.Equal(302, res.Code)
+}
+
+func (as *ActionSuite) Test_Webhooks_AdminOnly() {
+	u := as.signIn()
+
+	archive := &models.Archive{Name: "Hooks", Slug: "hooks"}
+	as.NoError(as.DB.Create(archive))
+
+	// someone who doesn't belong to the archive can't tell it's there
+	res := as.HTML("/archives/%s/webhooks", archive.ID).Get()
+	as.Equal(404, res.Code)
+
+	verrs, err := archive.SetMember(as.DB, u.ID, models.RoleMember)
+	as.NoError(err)
+	as.False(verrs.HasAny())
+
+	res = as.HTML("/archives/%s/webhooks", archive.ID).Get()
+	as.Equal(403, res.Code)
+
+	res = as.HTML("/archives/%s/webhooks", archive.ID).Post(map[string]string{"url": "https://example.com/hook", "events": models.EventConversationCreated})
+	as.Equal(403, res.Code)
+
+	verrs, err = archive.SetMember(as.DB, u.ID, models.RoleAdmin)
+	as.NoError(err)
+	as.False(verrs.HasAny())
+
+	res = as.HTML("/archives/%s/webhooks", archive.ID).Get()
+	as.Equal(200, res.Code)
+}
+
+func (as *ActionSuite) Test_Webhooks_CreateToggleRedeliver() {
+	u := as.signIn()
+
+	archive := &models.Archive{Name: "Hooks", Slug: "hooks"}
+	verrs, err := archive.Create(as.DB, u.ID)
+	as.NoError(err)
+	as.False(verrs.HasAny())
+
+	// only http and https addresses are taken
+	res := as.HTML("/archives/%s/webhooks", archive.ID).Post(map[string]string{"url": "ftp://example.com/hook", "events": models.EventConversationCreated})
+	as.Equal(422, res.Code)
+
+	res = as.HTML("/archives/%s/webhooks", archive.ID).Post(map[string]string{"url": "https://example.com/hook", "events": models.EventConversationCreated})
+	as.Equal(302, res.Code)
+
+	hook := &models.Webhook{}
+	as.NoError(as.DB.Where("archive_id = ?", archive.ID).First(hook))
+	as.Equal("https://example.com/hook", hook.URL)
+	as.True(hook.Active)
+	as.NotEmpty(hook.Secret)
+
+	res = as.HTML("/archives/%s/webhooks/%s/toggle", archive.ID, hook.ID).Post(nil)
+	as.Equal(302, res.Code)
+	as.NoError(as.DB.Reload(hook))
+	as.False(hook.Active)
+
+	d := &models.WebhookDelivery{
+		WebhookID: hook.ID,
+		Event:     models.EventConversationCreated,
+		Payload:   "{}",
+		Status:    models.DeliveryFailed,
+		Attempts:  models.WebhookMaxAttempts,
+	}
+	as.NoError(as.DB.Create(d))
+
+	res = as.HTML("/archives/%s/webhooks/%s/deliveries/%s/redeliver", archive.ID, hook.ID, d.ID).Post(nil)
+	as.Equal(302, res.Code)
+
+	as.NoError(as.DB.Reload(d))
+	as.Equal(models.DeliveryPending, d.Status)
+	as.Equal(0, d.Attempts)
+	as.NotNil(d.NextAttemptAt)
+}
@@ -15,12 +15,15 @@ const srcParam = "src"
 const destParam = "dest"
 const seedCmd = "seed"
 const exportCmd = "export"
+const purgeCmd = "purge"
+const daysParam = "days"
+const archiveParam = "archive"
 
 var _ = grift.Namespace("db", func() {
 
 	// "seed" is used to load the contents of a json file.  Look at the source in
 	// loader.go for the format of the json.
-	grift.Desc(seedCmd, "Seeds the QuoteArchive database from a json file, example: buffalo task db:seed src:filename v:[0-4]")
+	grift.Desc(seedCmd, "Seeds the QuoteArchive database from a json file, example: buffalo task db:seed src:filename v:[0-4] archive:slug")
 	grift.Add(seedCmd, func(c *grift.Context) error {
 		// Add DB seeding stuff here
 
@@ -28,6 +31,8 @@ var _ = grift.Namespace("db", func() {
 		// src:filename (reqd) example: 'buffalo task db:seed src:file.json'
 		// v:level (optional) example: 'buffalo task db:seed v:4 src:file.json'
 		// v default is zero, max is 4
+		// archive:slug (optional) which archive gets the quotes, default
+		// is the default archive
 
 		if len(c.Args) == 0 {
 			return errors.New("no valid arguement to seed")
@@ -49,6 +54,10 @@ var _ = grift.Namespace("db", func() {
 				tracemsg(fmt.Sprintf("verbosity set to %d", verbosity), 1)
 			}
 
+			if len(parts) == 2 && strings.Compare(parts[0], archiveParam) == 0 {
+				archiveSlug = parts[1]
+			}
+
 			if len(parts) == 2 && strings.Compare(parts[0], srcParam) == 0 {
 				tracemsg(fmt.Sprintf("seeding from file %s", parts[1]), 1)
 				defer seedQuoteDB(parts[1])
@@ -57,7 +66,7 @@ var _ = grift.Namespace("db", func() {
 		return nil
 	})
 
-	grift.Desc(exportCmd, "Exports the QuoteArchive to a json file, example: buffalo task db:export dest:filename")
+	grift.Desc(exportCmd, "Exports the QuoteArchive to a json file, example: buffalo task db:export dest:filename archive:slug")
 
 	grift.Add(exportCmd, func(c *grift.Context) error {
 		// Drop the archive into a json for the online quotewall
@@ -66,6 +75,10 @@ var _ = grift.Namespace("db", func() {
 			fmt.Printf("arg = %s\n", arg)
 			parts := strings.Split(arg, ":")
 
+			if len(parts) == 2 && strings.Compare(parts[0], archiveParam) == 0 {
+				archiveSlug = parts[1]
+			}
+
 			if len(parts) == 2 && strings.Compare(parts[0], destParam) == 0 {
 				tracemsg(fmt.Sprintf("seeding from file %s", parts[1]), 1)
 				defer exportArchive(parts[1])
@@ -75,4 +88,33 @@ var _ = grift.Namespace("db", func() {
 		return nil
 	})
 
+	grift.Desc(purgeCmd, "Purges conversations that have been in the trash more than N days, example: buffalo task db:purge days:30")
+
+	grift.Add(purgeCmd, func(c *grift.Context) error {
+		// days:N (optional) how long something sits in the trash before
+		// it gets purged, default is 30
+
+		days := 30
+
+		for _, arg := range c.Args {
+			fmt.Printf("arg = %s\n", arg)
+			parts := strings.Split(arg, ":")
+
+			if len(parts) == 2 && strings.Compare(parts[0], daysParam) == 0 {
+				nd, err := strconv.Atoi(parts[1])
+				if err != nil {
+					return err
+				}
+
+				if nd < 0 {
+					return errors.New("days can't be negative")
+				}
+
+				days = nd
+			}
+		}
+
+		return purgeTrash(days)
+	})
+
 })
@@ -3,10 +3,12 @@ package grifts
 import (
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"strconv"
 
 	"github.com/navionguy/cloudquotes/models"
+	"github.com/navionguy/cloudquotes/storage"
 )
 
 // exportArchive loads the entire database of quotes and then converts them into
@@ -20,11 +22,19 @@ func exportArchive(dest string) error {
 		return err
 	}
 
+	archive, err := findArchive()
+
+	if err != nil {
+		fmt.Printf("unable to find archive %s, error = %s\n", archiveSlug, err.Error())
+		return err
+	}
+
 	conversations := []models.Conversation{}
 
-	// Load the whole database
+	// Load everything in the archive that has made it through review,
+	// except what is sitting in the trash
 
-	err = models.DB.Eager("Quotes.Conversation").Eager("Quotes").Eager("Quotes.Author").Eager("Quotes.Annotation").All(&conversations)
+	err = models.DB.Eager("Quotes.Conversation").Eager("Quotes").Eager("Quotes.Author").Eager("Quotes.CoAuthors").Eager("Quotes.Annotation").Where("status = ? AND deleted_at IS NULL AND archive_id = ?", models.StatusPublished, archive.ID).All(&conversations)
 
 	if err != nil {
 		fmt.Printf("query db failed, %s\n", err.Error())
@@ -38,7 +48,18 @@ func exportArchive(dest string) error {
 	var arc archivetype
 
 	for _, cv := range conversations {
-		var nc conversationtype
+		nc := conversationtype{
+			Meeting:  cv.Meeting,
+			Location: cv.Location,
+			Channel:  cv.Channel,
+			Setup:    cv.Setup,
+			Source:   cv.Source,
+		}
+
+		// the export leaves the building, so authors are named the way
+		// they agreed to be and hidden authors are left out
+		cv.Quotes = cv.PublishedQuotes()
+		cv = cv.MaskAuthors()
 
 		for _, qt := range cv.Quotes {
 			note := ""
@@ -47,17 +68,35 @@ func exportArchive(dest string) error {
 				note = qt.Annotation.Note
 			}
 
+			// narrator lines go out without a name
 			nq := utterancestype{
 				Name:       qt.Author.Name,
 				Quote:      qt.Phrase,
-				Date:       CustomTime{Time: qt.SaidOn},
+				Date:       CustomTime{Time: qt.SaidOn, Precision: qt.SaidPrecision},
 				Publish:    strconv.FormatBool(qt.Publish),
 				Annotation: note,
 			}
 
+			for _, a := range qt.CoAuthors {
+				nq.With = append(nq.With, a.Name)
+			}
+
 			nc.Conversation = append(nc.Conversation, nq)
 		}
 
+		// nothing in it was cleared for publishing, or left once hidden
+		// authors were taken out
+		if len(nc.Conversation) == 0 {
+			continue
+		}
+
+		nc.Attachments, err = exportAttachments(cv)
+
+		if err != nil {
+			fmt.Printf("reading attachments failed, %s\n", err.Error())
+			return err
+		}
+
 		arc.Quotearchive.Conversations = append(arc.Quotearchive.Conversations, nc)
 	}
 
@@ -76,3 +115,38 @@ func exportArchive(dest string) error {
 
 	return err
 }
+
+// exportAttachments reads the files attached to the conversation out of
+// storage so they go into the archive along with it
+func exportAttachments(cv models.Conversation) ([]attachmenttype, error) {
+	attachments, err := models.ConversationAttachments(models.DB, cv.ID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var list []attachmenttype
+
+	for _, a := range attachments {
+		file, err := storage.Default().Get(a.ID.String())
+
+		if err != nil {
+			return nil, err
+		}
+
+		data, err := ioutil.ReadAll(file)
+		file.Close()
+
+		if err != nil {
+			return nil, err
+		}
+
+		list = append(list, attachmenttype{
+			Filename:    a.Filename,
+			ContentType: a.ContentType,
+			Data:        data,
+		})
+	}
+
+	return list, nil
+}
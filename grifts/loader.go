@@ -10,6 +10,7 @@ import (
 
 	"github.com/gofrs/uuid"
 	"github.com/navionguy/cloudquotes/models"
+	"github.com/navionguy/cloudquotes/storage"
 )
 
 /*
@@ -22,19 +23,31 @@ import (
 		{ "conversation" : [
 			{ "name" : "Beth Smith", "Quote" : "Anytime they say, \u0027All you have to do...\u0027, you\u0027re screwed", "date" : "10/1/1997", "publish" : "True" }
 			]
+		},
+		{ "conversation" : [
+			{ "name" : "", "Quote" : "(phone rings)", "date" : "spring 1998", "publish" : "True" },
+			{ "name" : "Bob McGowan", "with" : [ "Beth Smith" ], "Quote" : "Not it!", "date" : "spring 1998", "publish" : "True" }
+			]
 		}
     ]}
 }
+
+A blank name makes a narrator line, and "with" names whoever said the
+line along with "name".
 */
 
 const ctLayout = "1/2/2006" // the date format used in my json file
 
-// CustomTime -- allows me to handle my date format in the JSON File
+// CustomTime -- allows me to handle my date format in the JSON File.
+// Older material is often only known as "spring 1998" or "circa 2003",
+// so Precision says how much of the date is real.
 type CustomTime struct {
 	time.Time
+	Precision string
 }
 
-// UnmarshalJSON  -- extracts out my format
+// UnmarshalJSON  -- extracts out my format, or any of the partial dates
+// models.ParseDate understands
 func (ct *CustomTime) UnmarshalJSON(b []byte) (err error) {
 	if b[0] == '"' && b[len(b)-1] == '"' {
 		b = b[1 : len(b)-1]
@@ -43,13 +56,7 @@ func (ct *CustomTime) UnmarshalJSON(b []byte) (err error) {
 	s := string(b)
 	fmt.Printf("unmarshaling %s\n", s)
 
-	parts := strings.Split(s, "/")
-
-	if len(parts) != 3 {
-		err = errors.New("invalid date found")
-		return
-	}
-	ct.Time, err = time.Parse(ctLayout, string(b))
+	ct.Time, ct.Precision, err = models.ParseDate(s)
 
 	if err != nil {
 		fmt.Printf("unmarshal got %s\n", err)
@@ -57,9 +64,23 @@ func (ct *CustomTime) UnmarshalJSON(b []byte) (err error) {
 	return
 }
 
-// MarshalJSON CustomTime to my JSON format
+// MarshalJSON CustomTime to my JSON format, leaving off whatever part
+// of the date isn't known
 func (ct *CustomTime) MarshalJSON() ([]byte, error) {
-	s := ct.Format(ctLayout)
+	var s string
+
+	switch ct.Precision {
+	case models.PrecisionMonth:
+		s = ct.Format("1/2006")
+	case models.PrecisionSeason:
+		s = strings.ToLower(models.FormatDate(ct.Time, ct.Precision))
+	case models.PrecisionYear:
+		s = ct.Format("2006")
+	case models.PrecisionCirca:
+		s = "circa " + ct.Format("2006")
+	default:
+		s = ct.Format(ctLayout)
+	}
 
 	return json.Marshal(s)
 }
@@ -69,13 +90,30 @@ var sdft time.Time // date time stamp of quote file I'm using
 //Utterancestype --
 type utterancestype struct {
 	Name       string
+	With       []string `json:",omitempty"`
 	Quote      string
 	Date       CustomTime
 	Publish    string
 	Annotation string
 }
 
+// attachmenttype carries an attached file inside the archive, the data
+// gets base64 encoded by encoding/json
+type attachmenttype struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
+// the context fields and attachments came along after the archive
+// format, so they are left out when empty and old archives still load
 type conversationtype struct {
+	Meeting      string           `json:",omitempty"`
+	Location     string           `json:",omitempty"`
+	Channel      string           `json:",omitempty"`
+	Setup        string           `json:",omitempty"`
+	Source       string           `json:",omitempty"`
+	Attachments  []attachmenttype `json:",omitempty"`
 	Conversation []utterancestype
 }
 
@@ -93,6 +131,22 @@ var authorCache authormap
 
 type authormap map[string]uuid.UUID
 
+// archiveSlug names the archive being seeded or exported, empty means
+// the default archive
+var archiveSlug string
+
+// archive is where everything being seeded ends up
+var archive *models.Archive
+
+// findArchive looks up the archive named by archiveSlug
+func findArchive() (*models.Archive, error) {
+	if len(archiveSlug) == 0 {
+		return models.DefaultArchive(models.DB)
+	}
+
+	return models.FindArchive(models.DB, archiveSlug)
+}
+
 // seedQuoteDB()
 //
 // 1. Load the json file of quotes
@@ -116,6 +170,13 @@ func seedQuoteDB(seedfile string) error {
 		return errors.New("no quotes found in seed file")
 	}
 
+	archive, err = findArchive()
+
+	if err != nil {
+		fmt.Printf("unable to find archive %s, error = %s\n", archiveSlug, err.Error())
+		return err
+	}
+
 	// re-create the authorCache
 	authorCache = make(authormap)
 
@@ -131,11 +192,44 @@ func seedQuoteDB(seedfile string) error {
 // Builds the database entries for one conversation
 //
 func createConversation(cv conversationtype) error {
+	// skip anything that looks like it is already in the archive, the
+	// same way the web form warns about it
+
+	phrases := []string{}
+	for _, qt := range cv.Conversation {
+		phrases = append(phrases, qt.Quote)
+	}
+
+	dups, err := models.FindDuplicates(models.DB, archive.ID, phrases)
+
+	if err != nil {
+		return err
+	}
+
+	if len(dups) > 0 {
+		fmt.Printf("skipping likely duplicate of conversation %s, \"%s\" matches \"%s\"\n", dups[0].ConversationID, dups[0].Phrase, dups[0].Existing)
+		return nil
+	}
+
 	// create the conversation and give it a unique ID
 
 	conv := &models.Conversation{}
+	conv.ArchiveID = archive.ID
 	conv.OccurredOn = cv.Conversation[0].Date.Time
+	conv.OccurredPrecision = cv.Conversation[0].Date.Precision
+	conv.Meeting = cv.Meeting
+	conv.Location = cv.Location
+	conv.Channel = cv.Channel
+	conv.Setup = cv.Setup
+	conv.Source = cv.Source
 	conv.Publish = (strings.Compare("true", strings.ToLower(cv.Conversation[0].Publish)) == 0)
+
+	// archives from before the review workflow only knew published or not
+	conv.Status = models.StatusDraft
+	if conv.Publish {
+		conv.Status = models.StatusPublished
+	}
+
 	tracemsg(fmt.Sprintf("creating conversation, publish = %v, src = %s", conv.Publish, cv.Conversation[0].Publish), 4)
 
 	if err := models.DB.Create(conv); err != nil {
@@ -151,6 +245,20 @@ func createConversation(cv conversationtype) error {
 		}
 	}
 
+	for _, at := range cv.Attachments {
+		attachment := models.NewAttachment(at.Filename, at.Data)
+
+		verrs, err := attachment.Save(models.DB, storage.Default(), conv.ID, at.Data, uuid.Nil)
+
+		if err != nil {
+			return err
+		}
+
+		if verrs.HasAny() {
+			fmt.Printf("skipping attachment %s, %s\n", at.Filename, verrs.Error())
+		}
+	}
+
 	return nil
 }
 
@@ -164,19 +272,28 @@ func createConversation(cv conversationtype) error {
 func createQuote(cv uuid.UUID, sequence int, qt utterancestype) error {
 	tracemsg(fmt.Sprintf("creating quote %s", qt.Quote), 4)
 
-	// find or create the ID for the author
-	authID, err := findOrCreateAuthor(qt.Name)
+	// find or create the ID for the author, narrator lines don't have one
+	var authID *uuid.UUID
 
-	// if that didn't go well, get out
+	if len(qt.Name) > 0 {
+		id, err := findOrCreateAuthor(qt.Name)
 
-	if err != nil {
-		return err
+		// if that didn't go well, get out
+
+		if err != nil {
+			return err
+		}
+
+		authID = &id
 	}
 
+	var err error
+
 	// create the quote with as much stuff as we know
 
 	aQuote := &models.Quote{
 		SaidOn:         qt.Date.Time,
+		SaidPrecision:  qt.Date.Precision,
 		Sequence:       sequence,
 		Phrase:         qt.Quote,
 		AuthorID:       authID,
@@ -205,6 +322,45 @@ func createQuote(cv uuid.UUID, sequence int, qt utterancestype) error {
 		return err
 	}
 
+	return createCoAuthors(aQuote, qt.With)
+}
+
+// createCoAuthors()
+//
+// Credits the quote to everybody named in the utterance's "with" list
+// along with its author.  Nobody gets credited twice.
+//
+func createCoAuthors(qt *models.Quote, with []string) error {
+	if len(with) > 0 && qt.Narration() {
+		return fmt.Errorf("narrator line %q can't be said with anybody", qt.Phrase)
+	}
+
+	seen := map[uuid.UUID]bool{}
+
+	if qt.AuthorID != nil {
+		seen[*qt.AuthorID] = true
+	}
+
+	for _, name := range with {
+		id, err := findOrCreateAuthor(name)
+
+		if err != nil {
+			return err
+		}
+
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		err = models.DB.Create(&models.QuoteAuthor{QuoteID: qt.ID, AuthorID: id})
+
+		if err != nil {
+			fmt.Printf("co-author create failed with error %s\n", err)
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -224,26 +380,25 @@ func findOrCreateAuthor(author string) (uuid.UUID, error) {
 		return id, nil
 	}
 
-	// try the database
+	// try the database, the name might be a known alias or differ only by case
 
-	authRecs := []models.Author{}
-	query := models.DB.Where(fmt.Sprintf("name = '%s'", author))
-	err := query.All(&authRecs)
+	authRec := models.Author{Name: author, ArchiveID: archive.ID}
+	err := authRec.FindByExactName(models.DB)
 
-	if err != nil {
-		fmt.Printf("Author query returns an error: %s.", err)
-		return id, err
-	}
+	if err == nil {
+		tracemsg(fmt.Sprintf("found author %s as %s in database at %s", author, authRec.Name, authRec.ID), 4)
 
-	if len(authRecs) > 0 {
-		tracemsg(fmt.Sprintf("found author %s in database at %s", authRecs[0].Name, authRecs[0].ID), 4)
+		// and add to the cache, under the spelling I was handed
 
-		// and add to the cache
+		authorCache[author] = authRec.ID
+		tracemsg(fmt.Sprintf("added author %s to cache", author), 3)
 
-		authorCache[authRecs[0].Name] = authRecs[0].ID
-		tracemsg(fmt.Sprintf("added author %s to cache", authRecs[0].Name), 3)
+		return authRec.ID, nil
+	}
 
-		return authRecs[0].ID, nil
+	if err != models.ErrAuthorNotFound {
+		fmt.Printf("Author query returns an error: %s.", err)
+		return id, err
 	}
 
 	id, err = createAuthor(author)
@@ -266,7 +421,7 @@ func findOrCreateAuthor(author string) (uuid.UUID, error) {
 
 func findOrCreateAnnotation(annotation string) (*uuid.UUID, error) {
 	annotateRecs := []models.Annotation{}
-	query := models.DB.Where(fmt.Sprintf("note = '%s'", annotation))
+	query := models.DB.Where("note = ? AND archive_id = ?", annotation, archive.ID)
 	err := query.All(&annotateRecs)
 
 	if err != nil {
@@ -304,7 +459,8 @@ func createAuthor(author string) (uuid.UUID, error) {
 	// create a database record
 
 	rec := models.Author{
-		Name: author,
+		Name:      author,
+		ArchiveID: archive.ID,
 	}
 
 	err := models.DB.Create(&rec)
@@ -326,7 +482,8 @@ func createAnnotation(annotation string) (uuid.UUID, error) {
 
 	// create new object with the annotation text
 	rec := models.Annotation{
-		Note: annotation,
+		Note:      annotation,
+		ArchiveID: archive.ID,
 	}
 
 	err := models.DB.Create(&rec)
@@ -351,7 +508,11 @@ func loadquotedata(filename string) error {
 	if e != nil {
 		return e
 	}
-	json.Unmarshal(file, &quotes)
+
+	if e = json.Unmarshal(file, &quotes); e != nil {
+		return e
+	}
+
 	tracemsg(fmt.Sprintf("found %d quotes", len(quotes.Quotearchive.Conversations)), 1)
 
 	return nil
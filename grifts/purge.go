@@ -0,0 +1,54 @@
+package grifts
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gobuffalo/pop/v5"
+	"github.com/navionguy/cloudquotes/models"
+	"github.com/navionguy/cloudquotes/storage"
+)
+
+// purgeTrash removes every conversation that has been sitting in the
+// trash for more than the passed number of days.
+func purgeTrash(days int) error {
+	cutoff := time.Now().AddDate(0, 0, -days)
+
+	err := models.DB.Transaction(func(tx *pop.Connection) error {
+		n, err := models.PurgeTrash(tx, cutoff)
+
+		if err != nil {
+			fmt.Printf("purge failed with %s\n", err.Error())
+			return err
+		}
+
+		fmt.Printf("purged %d conversations deleted before %s\n", n, cutoff.Format("Jan _2, 2006"))
+
+		return nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	// the purge is committed, so the attached files can go too
+	removed := 0
+
+	for {
+		n, err := models.RemoveFiles(models.DB, storage.Default())
+		removed += n
+
+		if err != nil {
+			fmt.Printf("removing attached files failed with %s\n", err.Error())
+			return err
+		}
+
+		if n == 0 {
+			break
+		}
+	}
+
+	fmt.Printf("removed %d attached files\n", removed)
+
+	return nil
+}
@@ -3,7 +3,9 @@ package grifts
 import (
 	"errors"
 	"fmt"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/markbates/grift/grift"
 	"github.com/navionguy/cloudquotes/models"
@@ -18,6 +20,17 @@ const pwdParam = "pwd"
 
 const rmvCmd = "rmv"
 
+const grantCmd = "grant"
+const revokeCmd = "revoke"
+const permParam = "perm"
+
+const joinCmd = "join"
+const roleParam = "role"
+
+const tokenCmd = "token"
+const nameParam = "name"
+const scopesParam = "scopes"
+
 var _ = grift.Namespace(nameSpace, func() {
 	// "add" creates a new user in the database
 	grift.Desc(addCmd, "Adds a user account for working with quotes, example: buffalo task user:add email:emailaddr pwd:initialpassword")
@@ -103,4 +116,166 @@ var _ = grift.Namespace(nameSpace, func() {
 
 		return models.DB.Destroy(u)
 	})
+
+	grift.Desc(grantCmd, "Grants a user a permission, example: buffalo task user:grant email:emailaddr perm:editor")
+	grift.Add(grantCmd, func(c *grift.Context) error {
+		u, perm, err := permissionArgs(c, permParam, "")
+
+		if err != nil {
+			return err
+		}
+
+		has, err := models.HasPermission(models.DB, u.ID, perm)
+
+		if err != nil || has {
+			return err
+		}
+
+		verrs, err := models.DB.ValidateAndCreate(&models.Permission{Name: perm, UserID: u.ID})
+
+		if verrs.HasAny() {
+			return errors.New("permission failed validation")
+		}
+
+		return err
+	})
+
+	grift.Desc(revokeCmd, "Takes a permission away from a user, example: buffalo task user:revoke email:emailaddr perm:editor")
+	grift.Add(revokeCmd, func(c *grift.Context) error {
+		u, perm, err := permissionArgs(c, permParam, "")
+
+		if err != nil {
+			return err
+		}
+
+		return models.DB.RawQuery("DELETE FROM permissions WHERE user_id = ? AND name = ?", u.ID, perm).Exec()
+	})
+
+	grift.Desc(joinCmd, "Adds a user to an archive, or changes their role there, example: buffalo task user:join email:emailaddr archive:slug role:admin")
+	grift.Add(joinCmd, func(c *grift.Context) error {
+		// archive:slug (optional) default is the default archive
+		// role:name (optional) default is member
+
+		u, role, err := permissionArgs(c, roleParam, models.RoleMember)
+
+		if err != nil {
+			return err
+		}
+
+		archiveSlug = ""
+
+		for _, arg := range c.Args {
+			parts := strings.Split(arg, ":")
+
+			if len(parts) == 2 && strings.Compare(parts[0], archiveParam) == 0 {
+				archiveSlug = parts[1]
+			}
+		}
+
+		a, err := findArchive()
+
+		if err != nil {
+			return err
+		}
+
+		verrs, err := a.SetMember(models.DB, u.ID, role)
+
+		if verrs != nil && verrs.HasAny() {
+			return errors.New("membership failed validation")
+		}
+
+		return err
+	})
+
+	grift.Desc(tokenCmd, "Issues an API token, say for a service account, example: buffalo task user:token email:emailaddr name:nightly-export scopes:read,write days:90")
+	grift.Add(tokenCmd, func(c *grift.Context) error {
+		// name:label (reqd) what the token is for
+		// scopes:list (optional) comma separated, default is read
+		// days:count (optional) how long the token lasts, default is
+		// until it gets revoked
+
+		t := &models.APIToken{Scopes: models.ScopeRead}
+		email := ""
+
+		for _, arg := range c.Args {
+			parts := strings.SplitN(arg, ":", 2)
+
+			if len(parts) != 2 {
+				continue
+			}
+
+			switch parts[0] {
+			case emailParam:
+				email = parts[1]
+			case nameParam:
+				t.Name = parts[1]
+			case scopesParam:
+				t.Scopes = parts[1]
+			case daysParam:
+				days, err := strconv.Atoi(parts[1])
+				if err != nil || days < 1 {
+					return errors.New("days has to be a positive number")
+				}
+
+				expires := time.Now().AddDate(0, 0, days)
+				t.ExpiresAt = &expires
+			}
+		}
+
+		if len(email) == 0 || len(t.Name) == 0 {
+			return errors.New("required parameter not supplied")
+		}
+
+		u := &models.User{}
+
+		if err := models.DB.Where("Email = ?", email).First(u); err != nil {
+			return err
+		}
+
+		t.UserID = u.ID
+		token, verrs, err := t.Issue(models.DB)
+
+		if err != nil {
+			return err
+		}
+
+		if verrs.HasAny() {
+			return errors.New("token failed validation: " + verrs.Error())
+		}
+
+		// this is the only time the token can be seen
+		fmt.Println(token)
+
+		return nil
+	})
 })
+
+// permissionArgs picks the user and permission name out of the
+// arguements to user:grant, user:revoke and user:join.  The permission
+// comes from the named parameter, or falls back to def.
+func permissionArgs(c *grift.Context, param string, def string) (*models.User, string, error) {
+	u := &models.User{}
+	perm := def
+
+	for _, arg := range c.Args {
+		parts := strings.Split(arg, ":")
+
+		if len(parts) == 2 && strings.Compare(parts[0], emailParam) == 0 {
+			u.Email = parts[1]
+		}
+
+		if len(parts) == 2 && strings.Compare(parts[0], param) == 0 {
+			perm = parts[1]
+		}
+	}
+
+	if len(u.Email) == 0 || len(perm) == 0 {
+		return nil, "", errors.New("required parameter not supplied")
+	}
+
+	if err := models.DB.Where("Email = ?", u.Email).First(u); err != nil {
+		return nil, "", err
+	}
+
+	return u, perm, nil
+}
@@ -46,10 +46,10 @@ $$;
 ALTER FUNCTION public.pick_from_range(bottom integer, top integer) OWNER TO cloudquotes;
 
 --
--- Name: shuffle_deck(); Type: FUNCTION; Schema: public; Owner: cloudquotes
+-- Name: shuffle_deck(uuid); Type: FUNCTION; Schema: public; Owner: cloudquotes
 --
 
-CREATE FUNCTION public.shuffle_deck() RETURNS integer
+CREATE FUNCTION public.shuffle_deck(deck uuid) RETURNS integer
     LANGUAGE plpgsql STRICT
     AS $$
 DECLARE
@@ -57,27 +57,16 @@ DECLARE
     i           integer;
     j           integer;
     keys        uuid[];
-    marker      text;
 BEGIN
 
-    /* fastest way to clear the table */
-    IF EXISTS (SELECT * FROM pg_tables WHERE tablename='shuffled_conversations')
-         THEN
-             DROP TABLE shuffled_conversations;
-    END IF;    
+    /* throw out the archive's old deck */
+    DELETE FROM shuffled_conversations WHERE archive_id = deck;
 
-    CREATE TABLE shuffled_conversations (
-        sequence        integer NOT NULL PRIMARY KEY,
-        conversation_ID uuid NOT NULL
-    );
-    ALTER TABLE shuffled_conversations
-        ADD CONSTRAINT conversation_id_fkey FOREIGN KEY (conversation_id) REFERENCES public.conversations(id) ON DELETE RESTRICT DEFERRABLE INITIALLY DEFERRED;
+    keys := ARRAY(SELECT id FROM conversations WHERE archive_id = deck);    /* load up the archive's conversation ID values */
+    i := 0;                                                                 /* rolls over the entire array doing the shuffle */
+    max_rec := COALESCE(array_length(keys,1), 0);                           /* get number of conversations in the array */
 
-    keys := ARRAY(SELECT id FROM conversations);    /* load up all the conversation ID values */
-    i := 0;                                         /* rolls over the entire array doing the shuffle */
-    max_rec := array_length(keys,1);                /* get number of conversations in the array */
-
-    LOOP
+    WHILE i < max_rec LOOP
         i := i + 1; /* move forward, there is no 0 element */
 
         /* pick a random element still in the array */
@@ -85,30 +74,21 @@ BEGIN
         /* then put the current element into its position in the array */
         /* by the time I'm done, the Keys array is trashed, don't try to use it */
 
-        j := pick_from_range(i,max_rec);    
-        INSERT INTO shuffled_conversations( sequence, conversation_ID) VALUES( i, keys[j] );
+        j := pick_from_range(i,max_rec);
+        INSERT INTO shuffled_conversations( archive_id, sequence, conversation_id) VALUES( deck, i, keys[j] );
         keys[j] := keys[i];
-
-        EXIT WHEN i = max_rec;
     END LOOP;
 
-    /* set the current date as a comment on the table */
-    marker := (SELECT CURRENT_DATE);
-    EXECUTE FORMAT('COMMENT ON TABLE shuffled_conversations IS ''%I''', marker);
-
-    /* and the record count as a comment on the id column */
-    EXECUTE FORMAT('COMMENT ON COLUMN shuffled_conversations.sequence IS ''%I''', max_rec);
-    
-    /* tag this run of the record shuffle */
-    keys[1] := (SELECT uuid_generate_v4());
-    EXECUTE FORMAT('COMMENT ON COLUMN shuffled_conversations.conversation_id IS ''%I''', keys[1]);
-    
+    /* remember the day it was dealt */
+    INSERT INTO decks( archive_id, dealt_on) VALUES( deck, CURRENT_DATE )
+        ON CONFLICT (archive_id) DO UPDATE SET dealt_on = EXCLUDED.dealt_on;
+
     RETURN max_rec;
 END
 $$;
 
 
-ALTER FUNCTION public.shuffle_deck() OWNER TO cloudquotes;
+ALTER FUNCTION public.shuffle_deck(deck uuid) OWNER TO cloudquotes;
 
 SET default_tablespace = '';
 
@@ -120,14 +100,68 @@ SET default_with_oids = false;
 
 CREATE TABLE public.annotations (
     id uuid NOT NULL,
-    note character varying(255) NOT NULL,
+    note text NOT NULL,
     created_at timestamp without time zone NOT NULL,
-    updated_at timestamp without time zone NOT NULL
+    updated_at timestamp without time zone NOT NULL,
+    archive_id uuid NOT NULL
 );
 
 
 ALTER TABLE public.annotations OWNER TO cloudquotes;
 
+--
+-- Name: api_tokens; Type: TABLE; Schema: public; Owner: cloudquotes
+--
+
+CREATE TABLE public.api_tokens (
+    id uuid NOT NULL,
+    user_id uuid NOT NULL,
+    name character varying(255) NOT NULL,
+    token_hash character varying(64) NOT NULL,
+    created_at timestamp without time zone NOT NULL,
+    updated_at timestamp without time zone NOT NULL,
+    scopes character varying(255) DEFAULT 'read'::character varying NOT NULL,
+    expires_at timestamp without time zone,
+    last_used_at timestamp without time zone,
+    revoked_at timestamp without time zone
+);
+
+
+ALTER TABLE public.api_tokens OWNER TO cloudquotes;
+
+--
+-- Name: archives; Type: TABLE; Schema: public; Owner: cloudquotes
+--
+
+CREATE TABLE public.archives (
+    id uuid NOT NULL,
+    name character varying(255) NOT NULL,
+    slug character varying(64) NOT NULL,
+    created_at timestamp without time zone NOT NULL,
+    updated_at timestamp without time zone NOT NULL
+);
+
+
+ALTER TABLE public.archives OWNER TO cloudquotes;
+
+--
+-- Name: attachments; Type: TABLE; Schema: public; Owner: cloudquotes
+--
+
+CREATE TABLE public.attachments (
+    id uuid NOT NULL,
+    conversation_id uuid NOT NULL,
+    user_id uuid,
+    filename character varying(255) NOT NULL,
+    content_type character varying(100) NOT NULL,
+    size integer NOT NULL,
+    created_at timestamp without time zone NOT NULL,
+    updated_at timestamp without time zone NOT NULL
+);
+
+
+ALTER TABLE public.attachments OWNER TO cloudquotes;
+
 --
 -- Name: author_counts; Type: VIEW; Schema: public; Owner: cloudquotes
 --
@@ -143,6 +177,21 @@ SELECT
 
 ALTER TABLE public.author_counts OWNER TO cloudquotes;
 
+--
+-- Name: author_aliases; Type: TABLE; Schema: public; Owner: cloudquotes
+--
+
+CREATE TABLE public.author_aliases (
+    id uuid NOT NULL,
+    name character varying(255) NOT NULL,
+    author_id uuid NOT NULL,
+    created_at timestamp without time zone NOT NULL,
+    updated_at timestamp without time zone NOT NULL
+);
+
+
+ALTER TABLE public.author_aliases OWNER TO cloudquotes;
+
 --
 -- Name: authors; Type: TABLE; Schema: public; Owner: cloudquotes
 --
@@ -151,12 +200,69 @@ CREATE TABLE public.authors (
     id uuid NOT NULL,
     name character varying(255) NOT NULL,
     created_at timestamp without time zone NOT NULL,
-    updated_at timestamp without time zone NOT NULL
+    updated_at timestamp without time zone NOT NULL,
+    bio text DEFAULT ''::text NOT NULL,
+    team character varying(255) DEFAULT ''::character varying NOT NULL,
+    archive_id uuid NOT NULL,
+    visibility character varying(16) DEFAULT 'full'::character varying NOT NULL,
+    user_id uuid,
+    opted_out_at timestamp without time zone
 );
 
 
 ALTER TABLE public.authors OWNER TO cloudquotes;
 
+--
+-- Name: collection_entries; Type: TABLE; Schema: public; Owner: cloudquotes
+--
+
+CREATE TABLE public.collection_entries (
+    id uuid NOT NULL,
+    collection_id uuid NOT NULL,
+    conversation_id uuid NOT NULL,
+    "position" integer NOT NULL,
+    created_at timestamp without time zone NOT NULL,
+    updated_at timestamp without time zone NOT NULL
+);
+
+
+ALTER TABLE public.collection_entries OWNER TO cloudquotes;
+
+--
+-- Name: collections; Type: TABLE; Schema: public; Owner: cloudquotes
+--
+
+CREATE TABLE public.collections (
+    id uuid NOT NULL,
+    archive_id uuid NOT NULL,
+    user_id uuid NOT NULL,
+    name character varying(255) NOT NULL,
+    public boolean DEFAULT false NOT NULL,
+    token character varying(32) NOT NULL,
+    created_at timestamp without time zone NOT NULL,
+    updated_at timestamp without time zone NOT NULL
+);
+
+
+ALTER TABLE public.collections OWNER TO cloudquotes;
+
+--
+-- Name: comments; Type: TABLE; Schema: public; Owner: cloudquotes
+--
+
+CREATE TABLE public.comments (
+    id uuid NOT NULL,
+    conversation_id uuid NOT NULL,
+    user_id uuid,
+    body text NOT NULL,
+    removed_at timestamp without time zone,
+    created_at timestamp without time zone NOT NULL,
+    updated_at timestamp without time zone NOT NULL
+);
+
+
+ALTER TABLE public.comments OWNER TO cloudquotes;
+
 --
 -- Name: conversations; Type: TABLE; Schema: public; Owner: cloudquotes
 --
@@ -166,12 +272,78 @@ CREATE TABLE public.conversations (
     occurredon timestamp without time zone NOT NULL,
     publish boolean NOT NULL,
     created_at timestamp without time zone NOT NULL,
-    updated_at timestamp without time zone NOT NULL
+    updated_at timestamp without time zone NOT NULL,
+    deleted_at timestamp without time zone,
+    status character varying(16) DEFAULT 'draft'::character varying NOT NULL,
+    meeting character varying(255) DEFAULT ''::character varying NOT NULL,
+    location character varying(255) DEFAULT ''::character varying NOT NULL,
+    channel character varying(255) DEFAULT ''::character varying NOT NULL,
+    setup text DEFAULT ''::text NOT NULL,
+    source character varying(255) DEFAULT ''::character varying NOT NULL,
+    archive_id uuid NOT NULL,
+    occurred_precision character varying(8) DEFAULT 'day'::character varying NOT NULL
 );
 
 
 ALTER TABLE public.conversations OWNER TO cloudquotes;
 
+--
+-- Name: conversations_tags; Type: TABLE; Schema: public; Owner: cloudquotes
+--
+
+CREATE TABLE public.conversations_tags (
+    id uuid NOT NULL,
+    conversation_id uuid NOT NULL,
+    tag_id uuid NOT NULL,
+    created_at timestamp without time zone NOT NULL,
+    updated_at timestamp without time zone NOT NULL
+);
+
+
+ALTER TABLE public.conversations_tags OWNER TO cloudquotes;
+
+--
+-- Name: decks; Type: TABLE; Schema: public; Owner: cloudquotes
+--
+
+CREATE TABLE public.decks (
+    archive_id uuid NOT NULL,
+    dealt_on date NOT NULL
+);
+
+
+ALTER TABLE public.decks OWNER TO cloudquotes;
+
+--
+-- Name: file_removals; Type: TABLE; Schema: public; Owner: cloudquotes
+--
+
+CREATE TABLE public.file_removals (
+    id uuid NOT NULL,
+    key character varying(255) NOT NULL,
+    created_at timestamp without time zone NOT NULL,
+    updated_at timestamp without time zone NOT NULL
+);
+
+
+ALTER TABLE public.file_removals OWNER TO cloudquotes;
+
+--
+-- Name: memberships; Type: TABLE; Schema: public; Owner: cloudquotes
+--
+
+CREATE TABLE public.memberships (
+    id uuid NOT NULL,
+    archive_id uuid NOT NULL,
+    user_id uuid NOT NULL,
+    role character varying(16) DEFAULT 'member'::character varying NOT NULL,
+    created_at timestamp without time zone NOT NULL,
+    updated_at timestamp without time zone NOT NULL
+);
+
+
+ALTER TABLE public.memberships OWNER TO cloudquotes;
+
 --
 -- Name: permissions; Type: TABLE; Schema: public; Owner: cloudquotes
 --
@@ -187,6 +359,21 @@ CREATE TABLE public.permissions (
 
 ALTER TABLE public.permissions OWNER TO cloudquotes;
 
+--
+-- Name: quote_authors; Type: TABLE; Schema: public; Owner: cloudquotes
+--
+
+CREATE TABLE public.quote_authors (
+    id uuid NOT NULL,
+    quote_id uuid NOT NULL,
+    author_id uuid NOT NULL,
+    created_at timestamp without time zone NOT NULL,
+    updated_at timestamp without time zone NOT NULL
+);
+
+
+ALTER TABLE public.quote_authors OWNER TO cloudquotes;
+
 --
 -- Name: quotes; Type: TABLE; Schema: public; Owner: cloudquotes
 --
@@ -195,18 +382,57 @@ CREATE TABLE public.quotes (
     id uuid NOT NULL,
     saidon timestamp without time zone NOT NULL,
     sequence integer NOT NULL,
-    phrase character varying(255) NOT NULL,
+    phrase text NOT NULL,
     publish boolean NOT NULL,
     annotation_id uuid,
-    author_id uuid NOT NULL,
+    author_id uuid,
     conversation_id uuid NOT NULL,
     created_at timestamp without time zone NOT NULL,
-    updated_at timestamp without time zone NOT NULL
+    updated_at timestamp without time zone NOT NULL,
+    deleted_at timestamp without time zone,
+    said_precision character varying(8) DEFAULT 'day'::character varying NOT NULL
 );
 
 
 ALTER TABLE public.quotes OWNER TO cloudquotes;
 
+--
+-- Name: reviews; Type: TABLE; Schema: public; Owner: cloudquotes
+--
+
+CREATE TABLE public.reviews (
+    id uuid NOT NULL,
+    conversation_id uuid NOT NULL,
+    user_id uuid,
+    from_status character varying(16) NOT NULL,
+    to_status character varying(16) NOT NULL,
+    comment text DEFAULT ''::text NOT NULL,
+    created_at timestamp without time zone NOT NULL,
+    updated_at timestamp without time zone NOT NULL
+);
+
+
+ALTER TABLE public.reviews OWNER TO cloudquotes;
+
+--
+-- Name: revisions; Type: TABLE; Schema: public; Owner: cloudquotes
+--
+
+CREATE TABLE public.revisions (
+    id uuid NOT NULL,
+    conversation_id uuid,
+    item_type character varying(32) NOT NULL,
+    item_id uuid NOT NULL,
+    action character varying(32) NOT NULL,
+    user_id uuid,
+    snapshot text NOT NULL,
+    created_at timestamp without time zone NOT NULL,
+    updated_at timestamp without time zone NOT NULL
+);
+
+
+ALTER TABLE public.revisions OWNER TO cloudquotes;
+
 --
 -- Name: schema_migration; Type: TABLE; Schema: public; Owner: cloudquotes
 --
@@ -218,6 +444,34 @@ CREATE TABLE public.schema_migration (
 
 ALTER TABLE public.schema_migration OWNER TO cloudquotes;
 
+--
+-- Name: shuffled_conversations; Type: TABLE; Schema: public; Owner: cloudquotes
+--
+
+CREATE TABLE public.shuffled_conversations (
+    archive_id uuid NOT NULL,
+    sequence integer NOT NULL,
+    conversation_id uuid NOT NULL
+);
+
+
+ALTER TABLE public.shuffled_conversations OWNER TO cloudquotes;
+
+--
+-- Name: tags; Type: TABLE; Schema: public; Owner: cloudquotes
+--
+
+CREATE TABLE public.tags (
+    id uuid NOT NULL,
+    name character varying(255) NOT NULL,
+    created_at timestamp without time zone NOT NULL,
+    updated_at timestamp without time zone NOT NULL,
+    archive_id uuid NOT NULL
+);
+
+
+ALTER TABLE public.tags OWNER TO cloudquotes;
+
 --
 -- Name: users; Type: TABLE; Schema: public; Owner: cloudquotes
 --
@@ -233,6 +487,61 @@ CREATE TABLE public.users (
 
 ALTER TABLE public.users OWNER TO cloudquotes;
 
+--
+-- Name: votes; Type: TABLE; Schema: public; Owner: cloudquotes
+--
+
+CREATE TABLE public.votes (
+    id uuid NOT NULL,
+    conversation_id uuid NOT NULL,
+    user_id uuid NOT NULL,
+    created_at timestamp without time zone NOT NULL,
+    updated_at timestamp without time zone NOT NULL
+);
+
+
+ALTER TABLE public.votes OWNER TO cloudquotes;
+
+--
+-- Name: webhook_deliveries; Type: TABLE; Schema: public; Owner: cloudquotes
+--
+
+CREATE TABLE public.webhook_deliveries (
+    id uuid NOT NULL,
+    webhook_id uuid NOT NULL,
+    event character varying(64) NOT NULL,
+    payload text NOT NULL,
+    status character varying(16) DEFAULT 'pending'::character varying NOT NULL,
+    attempts integer DEFAULT 0 NOT NULL,
+    next_attempt_at timestamp without time zone,
+    response_code integer DEFAULT 0 NOT NULL,
+    last_error text DEFAULT ''::text NOT NULL,
+    delivered_at timestamp without time zone,
+    created_at timestamp without time zone NOT NULL,
+    updated_at timestamp without time zone NOT NULL
+);
+
+
+ALTER TABLE public.webhook_deliveries OWNER TO cloudquotes;
+
+--
+-- Name: webhooks; Type: TABLE; Schema: public; Owner: cloudquotes
+--
+
+CREATE TABLE public.webhooks (
+    id uuid NOT NULL,
+    archive_id uuid NOT NULL,
+    url character varying(2048) NOT NULL,
+    secret character varying(64) NOT NULL,
+    events character varying(255) NOT NULL,
+    active boolean DEFAULT true NOT NULL,
+    created_at timestamp without time zone NOT NULL,
+    updated_at timestamp without time zone NOT NULL
+);
+
+
+ALTER TABLE public.webhooks OWNER TO cloudquotes;
+
 --
 -- Name: annotations annotations_pkey; Type: CONSTRAINT; Schema: public; Owner: cloudquotes
 --
@@ -242,97 +551,700 @@ ALTER TABLE ONLY public.annotations
 
 
 --
--- Name: authors authors_pkey; Type: CONSTRAINT; Schema: public; Owner: cloudquotes
+-- Name: api_tokens api_tokens_pkey; Type: CONSTRAINT; Schema: public; Owner: cloudquotes
 --
 
-ALTER TABLE ONLY public.authors
-    ADD CONSTRAINT authors_pkey PRIMARY KEY (id);
+ALTER TABLE ONLY public.api_tokens
+    ADD CONSTRAINT api_tokens_pkey PRIMARY KEY (id);
 
 
 --
--- Name: conversations conversations_pkey; Type: CONSTRAINT; Schema: public; Owner: cloudquotes
+-- Name: archives archives_pkey; Type: CONSTRAINT; Schema: public; Owner: cloudquotes
 --
 
-ALTER TABLE ONLY public.conversations
-    ADD CONSTRAINT conversations_pkey PRIMARY KEY (id);
+ALTER TABLE ONLY public.archives
+    ADD CONSTRAINT archives_pkey PRIMARY KEY (id);
 
 
 --
--- Name: permissions permissions_pkey; Type: CONSTRAINT; Schema: public; Owner: cloudquotes
+-- Name: attachments attachments_pkey; Type: CONSTRAINT; Schema: public; Owner: cloudquotes
 --
 
-ALTER TABLE ONLY public.permissions
-    ADD CONSTRAINT permissions_pkey PRIMARY KEY (id);
+ALTER TABLE ONLY public.attachments
+    ADD CONSTRAINT attachments_pkey PRIMARY KEY (id);
 
 
 --
--- Name: quotes quotes_pkey; Type: CONSTRAINT; Schema: public; Owner: cloudquotes
+-- Name: author_aliases author_aliases_pkey; Type: CONSTRAINT; Schema: public; Owner: cloudquotes
 --
 
-ALTER TABLE ONLY public.quotes
-    ADD CONSTRAINT quotes_pkey PRIMARY KEY (id);
+ALTER TABLE ONLY public.author_aliases
+    ADD CONSTRAINT author_aliases_pkey PRIMARY KEY (id);
 
 
 --
--- Name: users users_pkey; Type: CONSTRAINT; Schema: public; Owner: cloudquotes
+-- Name: authors authors_pkey; Type: CONSTRAINT; Schema: public; Owner: cloudquotes
 --
 
-ALTER TABLE ONLY public.users
-    ADD CONSTRAINT users_pkey PRIMARY KEY (id);
+ALTER TABLE ONLY public.authors
+    ADD CONSTRAINT authors_pkey PRIMARY KEY (id);
 
 
 --
--- Name: schema_migration_version_idx; Type: INDEX; Schema: public; Owner: cloudquotes
+-- Name: collection_entries collection_entries_pkey; Type: CONSTRAINT; Schema: public; Owner: cloudquotes
 --
 
-CREATE UNIQUE INDEX schema_migration_version_idx ON public.schema_migration USING btree (version);
+ALTER TABLE ONLY public.collection_entries
+    ADD CONSTRAINT collection_entries_pkey PRIMARY KEY (id);
 
 
 --
--- Name: author_counts _RETURN; Type: RULE; Schema: public; Owner: cloudquotes
+-- Name: collections collections_pkey; Type: CONSTRAINT; Schema: public; Owner: cloudquotes
 --
 
-CREATE OR REPLACE VIEW public.author_counts AS
- SELECT a.id,
-    a.name,
-    a.created_at,
-    a.updated_at,
-    count(a.id) AS count
-   FROM (public.authors a
-     JOIN public.quotes q ON ((a.id = q.author_id)))
-  GROUP BY a.id;
+ALTER TABLE ONLY public.collections
+    ADD CONSTRAINT collections_pkey PRIMARY KEY (id);
 
 
 --
--- Name: permissions permissions_user_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: cloudquotes
+-- Name: comments comments_pkey; Type: CONSTRAINT; Schema: public; Owner: cloudquotes
 --
 
-ALTER TABLE ONLY public.permissions
-    ADD CONSTRAINT permissions_user_id_fkey FOREIGN KEY (user_id) REFERENCES public.users(id) ON DELETE RESTRICT DEFERRABLE INITIALLY DEFERRED;
+ALTER TABLE ONLY public.comments
+    ADD CONSTRAINT comments_pkey PRIMARY KEY (id);
 
 
 --
--- Name: quotes quotes_annotation_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: cloudquotes
+-- Name: conversations conversations_pkey; Type: CONSTRAINT; Schema: public; Owner: cloudquotes
 --
 
-ALTER TABLE ONLY public.quotes
-    ADD CONSTRAINT quotes_annotation_id_fkey FOREIGN KEY (annotation_id) REFERENCES public.annotations(id);
+ALTER TABLE ONLY public.conversations
+    ADD CONSTRAINT conversations_pkey PRIMARY KEY (id);
 
 
 --
--- Name: quotes quotes_author_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: cloudquotes
+-- Name: conversations_tags conversations_tags_pkey; Type: CONSTRAINT; Schema: public; Owner: cloudquotes
 --
 
-ALTER TABLE ONLY public.quotes
-    ADD CONSTRAINT quotes_author_id_fkey FOREIGN KEY (author_id) REFERENCES public.authors(id) ON DELETE RESTRICT DEFERRABLE INITIALLY DEFERRED;
+ALTER TABLE ONLY public.conversations_tags
+    ADD CONSTRAINT conversations_tags_pkey PRIMARY KEY (id);
 
 
 --
--- Name: quotes quotes_conversation_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: cloudquotes
+-- Name: decks decks_pkey; Type: CONSTRAINT; Schema: public; Owner: cloudquotes
 --
 
-ALTER TABLE ONLY public.quotes
-    ADD CONSTRAINT quotes_conversation_id_fkey FOREIGN KEY (conversation_id) REFERENCES public.conversations(id) ON DELETE RESTRICT DEFERRABLE INITIALLY DEFERRED;
+ALTER TABLE ONLY public.decks
+    ADD CONSTRAINT decks_pkey PRIMARY KEY (archive_id);
+
+
+--
+-- Name: file_removals file_removals_pkey; Type: CONSTRAINT; Schema: public; Owner: cloudquotes
+--
+
+ALTER TABLE ONLY public.file_removals
+    ADD CONSTRAINT file_removals_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: memberships memberships_pkey; Type: CONSTRAINT; Schema: public; Owner: cloudquotes
+--
+
+ALTER TABLE ONLY public.memberships
+    ADD CONSTRAINT memberships_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: permissions permissions_pkey; Type: CONSTRAINT; Schema: public; Owner: cloudquotes
+--
+
+ALTER TABLE ONLY public.permissions
+    ADD CONSTRAINT permissions_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: quote_authors quote_authors_pkey; Type: CONSTRAINT; Schema: public; Owner: cloudquotes
+--
+
+ALTER TABLE ONLY public.quote_authors
+    ADD CONSTRAINT quote_authors_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: quotes quotes_pkey; Type: CONSTRAINT; Schema: public; Owner: cloudquotes
+--
+
+ALTER TABLE ONLY public.quotes
+    ADD CONSTRAINT quotes_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: reviews reviews_pkey; Type: CONSTRAINT; Schema: public; Owner: cloudquotes
+--
+
+ALTER TABLE ONLY public.reviews
+    ADD CONSTRAINT reviews_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: revisions revisions_pkey; Type: CONSTRAINT; Schema: public; Owner: cloudquotes
+--
+
+ALTER TABLE ONLY public.revisions
+    ADD CONSTRAINT revisions_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: shuffled_conversations shuffled_conversations_pkey; Type: CONSTRAINT; Schema: public; Owner: cloudquotes
+--
+
+ALTER TABLE ONLY public.shuffled_conversations
+    ADD CONSTRAINT shuffled_conversations_pkey PRIMARY KEY (archive_id, sequence);
+
+
+--
+-- Name: tags tags_pkey; Type: CONSTRAINT; Schema: public; Owner: cloudquotes
+--
+
+ALTER TABLE ONLY public.tags
+    ADD CONSTRAINT tags_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: users users_pkey; Type: CONSTRAINT; Schema: public; Owner: cloudquotes
+--
+
+ALTER TABLE ONLY public.users
+    ADD CONSTRAINT users_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: votes votes_pkey; Type: CONSTRAINT; Schema: public; Owner: cloudquotes
+--
+
+ALTER TABLE ONLY public.votes
+    ADD CONSTRAINT votes_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: webhook_deliveries webhook_deliveries_pkey; Type: CONSTRAINT; Schema: public; Owner: cloudquotes
+--
+
+ALTER TABLE ONLY public.webhook_deliveries
+    ADD CONSTRAINT webhook_deliveries_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: webhooks webhooks_pkey; Type: CONSTRAINT; Schema: public; Owner: cloudquotes
+--
+
+ALTER TABLE ONLY public.webhooks
+    ADD CONSTRAINT webhooks_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: annotations_archive_id_idx; Type: INDEX; Schema: public; Owner: cloudquotes
+--
+
+CREATE INDEX annotations_archive_id_idx ON public.annotations USING btree (archive_id);
+
+
+--
+-- Name: annotations_note_fts_idx; Type: INDEX; Schema: public; Owner: cloudquotes
+--
+
+CREATE INDEX annotations_note_fts_idx ON public.annotations USING gin (to_tsvector('english'::regconfig, note));
+
+
+--
+-- Name: api_tokens_token_hash_idx; Type: INDEX; Schema: public; Owner: cloudquotes
+--
+
+CREATE UNIQUE INDEX api_tokens_token_hash_idx ON public.api_tokens USING btree (token_hash);
+
+
+--
+-- Name: api_tokens_user_id_idx; Type: INDEX; Schema: public; Owner: cloudquotes
+--
+
+CREATE INDEX api_tokens_user_id_idx ON public.api_tokens USING btree (user_id);
+
+
+--
+-- Name: archives_slug_idx; Type: INDEX; Schema: public; Owner: cloudquotes
+--
+
+CREATE UNIQUE INDEX archives_slug_idx ON public.archives USING btree (slug);
+
+
+--
+-- Name: attachments_conversation_id_idx; Type: INDEX; Schema: public; Owner: cloudquotes
+--
+
+CREATE INDEX attachments_conversation_id_idx ON public.attachments USING btree (conversation_id);
+
+
+--
+-- Name: author_aliases_lower_name_idx; Type: INDEX; Schema: public; Owner: cloudquotes
+--
+
+CREATE INDEX author_aliases_lower_name_idx ON public.author_aliases USING btree (lower((name)::text));
+
+
+--
+-- Name: authors_archive_id_idx; Type: INDEX; Schema: public; Owner: cloudquotes
+--
+
+CREATE INDEX authors_archive_id_idx ON public.authors USING btree (archive_id);
+
+
+--
+-- Name: authors_name_fts_idx; Type: INDEX; Schema: public; Owner: cloudquotes
+--
+
+CREATE INDEX authors_name_fts_idx ON public.authors USING gin (to_tsvector('english'::regconfig, (name)::text));
+
+
+--
+-- Name: collection_entries_collection_id_conversation_id_idx; Type: INDEX; Schema: public; Owner: cloudquotes
+--
+
+CREATE UNIQUE INDEX collection_entries_collection_id_conversation_id_idx ON public.collection_entries USING btree (collection_id, conversation_id);
+
+
+--
+-- Name: collections_archive_id_user_id_idx; Type: INDEX; Schema: public; Owner: cloudquotes
+--
+
+CREATE INDEX collections_archive_id_user_id_idx ON public.collections USING btree (archive_id, user_id);
+
+
+--
+-- Name: collections_token_idx; Type: INDEX; Schema: public; Owner: cloudquotes
+--
+
+CREATE UNIQUE INDEX collections_token_idx ON public.collections USING btree (token);
+
+
+--
+-- Name: comments_conversation_id_created_at_idx; Type: INDEX; Schema: public; Owner: cloudquotes
+--
+
+CREATE INDEX comments_conversation_id_created_at_idx ON public.comments USING btree (conversation_id, created_at);
+
+
+--
+-- Name: conversations_archive_id_idx; Type: INDEX; Schema: public; Owner: cloudquotes
+--
+
+CREATE INDEX conversations_archive_id_idx ON public.conversations USING btree (archive_id);
+
+
+--
+-- Name: conversations_deleted_at_idx; Type: INDEX; Schema: public; Owner: cloudquotes
+--
+
+CREATE INDEX conversations_deleted_at_idx ON public.conversations USING btree (deleted_at);
+
+
+--
+-- Name: conversations_status_idx; Type: INDEX; Schema: public; Owner: cloudquotes
+--
+
+CREATE INDEX conversations_status_idx ON public.conversations USING btree (status);
+
+
+--
+-- Name: conversations_tags_conversation_id_tag_id_idx; Type: INDEX; Schema: public; Owner: cloudquotes
+--
+
+CREATE UNIQUE INDEX conversations_tags_conversation_id_tag_id_idx ON public.conversations_tags USING btree (conversation_id, tag_id);
+
+
+--
+-- Name: memberships_archive_id_user_id_idx; Type: INDEX; Schema: public; Owner: cloudquotes
+--
+
+CREATE UNIQUE INDEX memberships_archive_id_user_id_idx ON public.memberships USING btree (archive_id, user_id);
+
+
+--
+-- Name: quote_authors_quote_id_author_id_idx; Type: INDEX; Schema: public; Owner: cloudquotes
+--
+
+CREATE UNIQUE INDEX quote_authors_quote_id_author_id_idx ON public.quote_authors USING btree (quote_id, author_id);
+
+
+--
+-- Name: quotes_phrase_fts_idx; Type: INDEX; Schema: public; Owner: cloudquotes
+--
+
+CREATE INDEX quotes_phrase_fts_idx ON public.quotes USING gin (to_tsvector('english'::regconfig, phrase));
+
+
+--
+-- Name: reviews_conversation_id_created_at_idx; Type: INDEX; Schema: public; Owner: cloudquotes
+--
+
+CREATE INDEX reviews_conversation_id_created_at_idx ON public.reviews USING btree (conversation_id, created_at);
+
+
+--
+-- Name: revisions_conversation_id_created_at_idx; Type: INDEX; Schema: public; Owner: cloudquotes
+--
+
+CREATE INDEX revisions_conversation_id_created_at_idx ON public.revisions USING btree (conversation_id, created_at);
+
+
+--
+-- Name: revisions_item_type_item_id_created_at_idx; Type: INDEX; Schema: public; Owner: cloudquotes
+--
+
+CREATE INDEX revisions_item_type_item_id_created_at_idx ON public.revisions USING btree (item_type, item_id, created_at);
+
+
+--
+-- Name: schema_migration_version_idx; Type: INDEX; Schema: public; Owner: cloudquotes
+--
+
+CREATE UNIQUE INDEX schema_migration_version_idx ON public.schema_migration USING btree (version);
+
+
+--
+-- Name: shuffled_conversations_conversation_id_idx; Type: INDEX; Schema: public; Owner: cloudquotes
+--
+
+CREATE INDEX shuffled_conversations_conversation_id_idx ON public.shuffled_conversations USING btree (conversation_id);
+
+
+--
+-- Name: tags_archive_id_name_idx; Type: INDEX; Schema: public; Owner: cloudquotes
+--
+
+CREATE UNIQUE INDEX tags_archive_id_name_idx ON public.tags USING btree (archive_id, name);
+
+
+--
+-- Name: votes_conversation_id_user_id_idx; Type: INDEX; Schema: public; Owner: cloudquotes
+--
+
+CREATE UNIQUE INDEX votes_conversation_id_user_id_idx ON public.votes USING btree (conversation_id, user_id);
+
+
+--
+-- Name: webhook_deliveries_status_next_attempt_at_idx; Type: INDEX; Schema: public; Owner: cloudquotes
+--
+
+CREATE INDEX webhook_deliveries_status_next_attempt_at_idx ON public.webhook_deliveries USING btree (status, next_attempt_at);
+
+
+--
+-- Name: webhook_deliveries_webhook_id_created_at_idx; Type: INDEX; Schema: public; Owner: cloudquotes
+--
+
+CREATE INDEX webhook_deliveries_webhook_id_created_at_idx ON public.webhook_deliveries USING btree (webhook_id, created_at);
+
+
+--
+-- Name: webhooks_archive_id_idx; Type: INDEX; Schema: public; Owner: cloudquotes
+--
+
+CREATE INDEX webhooks_archive_id_idx ON public.webhooks USING btree (archive_id);
+
+
+--
+-- Name: author_counts _RETURN; Type: RULE; Schema: public; Owner: cloudquotes
+--
+
+CREATE OR REPLACE VIEW public.author_counts AS
+ SELECT a.id,
+    a.name,
+    a.created_at,
+    a.updated_at,
+    count(a.id) AS count
+   FROM (public.authors a
+     JOIN public.quotes q ON ((a.id = q.author_id)))
+  GROUP BY a.id;
+
+
+--
+-- Name: annotations annotations_archive_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: cloudquotes
+--
+
+ALTER TABLE ONLY public.annotations
+    ADD CONSTRAINT annotations_archive_id_fkey FOREIGN KEY (archive_id) REFERENCES public.archives(id) ON DELETE CASCADE;
+
+
+--
+-- Name: api_tokens api_tokens_user_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: cloudquotes
+--
+
+ALTER TABLE ONLY public.api_tokens
+    ADD CONSTRAINT api_tokens_user_id_fkey FOREIGN KEY (user_id) REFERENCES public.users(id) ON DELETE CASCADE;
+
+
+--
+-- Name: attachments attachments_conversation_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: cloudquotes
+--
+
+ALTER TABLE ONLY public.attachments
+    ADD CONSTRAINT attachments_conversation_id_fkey FOREIGN KEY (conversation_id) REFERENCES public.conversations(id) ON DELETE CASCADE;
+
+
+--
+-- Name: attachments attachments_user_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: cloudquotes
+--
+
+ALTER TABLE ONLY public.attachments
+    ADD CONSTRAINT attachments_user_id_fkey FOREIGN KEY (user_id) REFERENCES public.users(id) ON DELETE SET NULL;
+
+
+--
+-- Name: author_aliases author_aliases_author_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: cloudquotes
+--
+
+ALTER TABLE ONLY public.author_aliases
+    ADD CONSTRAINT author_aliases_author_id_fkey FOREIGN KEY (author_id) REFERENCES public.authors(id) ON DELETE CASCADE;
+
+
+--
+-- Name: authors authors_archive_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: cloudquotes
+--
+
+ALTER TABLE ONLY public.authors
+    ADD CONSTRAINT authors_archive_id_fkey FOREIGN KEY (archive_id) REFERENCES public.archives(id) ON DELETE CASCADE;
+
+
+--
+-- Name: authors authors_user_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: cloudquotes
+--
+
+ALTER TABLE ONLY public.authors
+    ADD CONSTRAINT authors_user_id_fkey FOREIGN KEY (user_id) REFERENCES public.users(id) ON DELETE SET NULL;
+
+
+--
+-- Name: collection_entries collection_entries_collection_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: cloudquotes
+--
+
+ALTER TABLE ONLY public.collection_entries
+    ADD CONSTRAINT collection_entries_collection_id_fkey FOREIGN KEY (collection_id) REFERENCES public.collections(id) ON DELETE CASCADE;
+
+
+--
+-- Name: collection_entries collection_entries_conversation_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: cloudquotes
+--
+
+ALTER TABLE ONLY public.collection_entries
+    ADD CONSTRAINT collection_entries_conversation_id_fkey FOREIGN KEY (conversation_id) REFERENCES public.conversations(id) ON DELETE CASCADE;
+
+
+--
+-- Name: collections collections_archive_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: cloudquotes
+--
+
+ALTER TABLE ONLY public.collections
+    ADD CONSTRAINT collections_archive_id_fkey FOREIGN KEY (archive_id) REFERENCES public.archives(id) ON DELETE CASCADE;
+
+
+--
+-- Name: collections collections_user_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: cloudquotes
+--
+
+ALTER TABLE ONLY public.collections
+    ADD CONSTRAINT collections_user_id_fkey FOREIGN KEY (user_id) REFERENCES public.users(id) ON DELETE CASCADE;
+
+
+--
+-- Name: comments comments_conversation_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: cloudquotes
+--
+
+ALTER TABLE ONLY public.comments
+    ADD CONSTRAINT comments_conversation_id_fkey FOREIGN KEY (conversation_id) REFERENCES public.conversations(id) ON DELETE CASCADE;
+
+
+--
+-- Name: comments comments_user_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: cloudquotes
+--
+
+ALTER TABLE ONLY public.comments
+    ADD CONSTRAINT comments_user_id_fkey FOREIGN KEY (user_id) REFERENCES public.users(id) ON DELETE SET NULL;
+
+
+--
+-- Name: conversations conversations_archive_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: cloudquotes
+--
+
+ALTER TABLE ONLY public.conversations
+    ADD CONSTRAINT conversations_archive_id_fkey FOREIGN KEY (archive_id) REFERENCES public.archives(id) ON DELETE CASCADE;
+
+
+--
+-- Name: conversations_tags conversations_tags_conversation_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: cloudquotes
+--
+
+ALTER TABLE ONLY public.conversations_tags
+    ADD CONSTRAINT conversations_tags_conversation_id_fkey FOREIGN KEY (conversation_id) REFERENCES public.conversations(id) ON DELETE CASCADE;
+
+
+--
+-- Name: conversations_tags conversations_tags_tag_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: cloudquotes
+--
+
+ALTER TABLE ONLY public.conversations_tags
+    ADD CONSTRAINT conversations_tags_tag_id_fkey FOREIGN KEY (tag_id) REFERENCES public.tags(id) ON DELETE RESTRICT DEFERRABLE INITIALLY DEFERRED;
+
+
+--
+-- Name: decks decks_archive_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: cloudquotes
+--
+
+ALTER TABLE ONLY public.decks
+    ADD CONSTRAINT decks_archive_id_fkey FOREIGN KEY (archive_id) REFERENCES public.archives(id) ON DELETE CASCADE;
+
+
+--
+-- Name: memberships memberships_archive_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: cloudquotes
+--
+
+ALTER TABLE ONLY public.memberships
+    ADD CONSTRAINT memberships_archive_id_fkey FOREIGN KEY (archive_id) REFERENCES public.archives(id) ON DELETE CASCADE;
+
+
+--
+-- Name: memberships memberships_user_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: cloudquotes
+--
+
+ALTER TABLE ONLY public.memberships
+    ADD CONSTRAINT memberships_user_id_fkey FOREIGN KEY (user_id) REFERENCES public.users(id) ON DELETE CASCADE;
+
+
+--
+-- Name: permissions permissions_user_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: cloudquotes
+--
+
+ALTER TABLE ONLY public.permissions
+    ADD CONSTRAINT permissions_user_id_fkey FOREIGN KEY (user_id) REFERENCES public.users(id) ON DELETE RESTRICT DEFERRABLE INITIALLY DEFERRED;
+
+
+--
+-- Name: quote_authors quote_authors_author_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: cloudquotes
+--
+
+ALTER TABLE ONLY public.quote_authors
+    ADD CONSTRAINT quote_authors_author_id_fkey FOREIGN KEY (author_id) REFERENCES public.authors(id) ON DELETE CASCADE;
+
+
+--
+-- Name: quote_authors quote_authors_quote_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: cloudquotes
+--
+
+ALTER TABLE ONLY public.quote_authors
+    ADD CONSTRAINT quote_authors_quote_id_fkey FOREIGN KEY (quote_id) REFERENCES public.quotes(id) ON DELETE CASCADE;
+
+
+--
+-- Name: quotes quotes_annotation_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: cloudquotes
+--
+
+ALTER TABLE ONLY public.quotes
+    ADD CONSTRAINT quotes_annotation_id_fkey FOREIGN KEY (annotation_id) REFERENCES public.annotations(id);
+
+
+--
+-- Name: quotes quotes_author_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: cloudquotes
+--
+
+ALTER TABLE ONLY public.quotes
+    ADD CONSTRAINT quotes_author_id_fkey FOREIGN KEY (author_id) REFERENCES public.authors(id) ON DELETE RESTRICT DEFERRABLE INITIALLY DEFERRED;
+
+
+--
+-- Name: quotes quotes_conversation_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: cloudquotes
+--
+
+ALTER TABLE ONLY public.quotes
+    ADD CONSTRAINT quotes_conversation_id_fkey FOREIGN KEY (conversation_id) REFERENCES public.conversations(id) ON DELETE RESTRICT DEFERRABLE INITIALLY DEFERRED;
+
+
+--
+-- Name: reviews reviews_conversation_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: cloudquotes
+--
+
+ALTER TABLE ONLY public.reviews
+    ADD CONSTRAINT reviews_conversation_id_fkey FOREIGN KEY (conversation_id) REFERENCES public.conversations(id) ON DELETE CASCADE;
+
+
+--
+-- Name: reviews reviews_user_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: cloudquotes
+--
+
+ALTER TABLE ONLY public.reviews
+    ADD CONSTRAINT reviews_user_id_fkey FOREIGN KEY (user_id) REFERENCES public.users(id) ON DELETE SET NULL;
+
+
+--
+-- Name: revisions revisions_user_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: cloudquotes
+--
+
+ALTER TABLE ONLY public.revisions
+    ADD CONSTRAINT revisions_user_id_fkey FOREIGN KEY (user_id) REFERENCES public.users(id) ON DELETE SET NULL;
+
+
+--
+-- Name: shuffled_conversations shuffled_conversations_archive_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: cloudquotes
+--
+
+ALTER TABLE ONLY public.shuffled_conversations
+    ADD CONSTRAINT shuffled_conversations_archive_id_fkey FOREIGN KEY (archive_id) REFERENCES public.archives(id) ON DELETE CASCADE;
+
+
+--
+-- Name: shuffled_conversations shuffled_conversations_conversation_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: cloudquotes
+--
+
+ALTER TABLE ONLY public.shuffled_conversations
+    ADD CONSTRAINT shuffled_conversations_conversation_id_fkey FOREIGN KEY (conversation_id) REFERENCES public.conversations(id) ON DELETE RESTRICT DEFERRABLE INITIALLY DEFERRED;
+
+
+--
+-- Name: tags tags_archive_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: cloudquotes
+--
+
+ALTER TABLE ONLY public.tags
+    ADD CONSTRAINT tags_archive_id_fkey FOREIGN KEY (archive_id) REFERENCES public.archives(id) ON DELETE CASCADE;
+
+
+--
+-- Name: votes votes_conversation_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: cloudquotes
+--
+
+ALTER TABLE ONLY public.votes
+    ADD CONSTRAINT votes_conversation_id_fkey FOREIGN KEY (conversation_id) REFERENCES public.conversations(id) ON DELETE CASCADE;
+
+
+--
+-- Name: votes votes_user_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: cloudquotes
+--
+
+ALTER TABLE ONLY public.votes
+    ADD CONSTRAINT votes_user_id_fkey FOREIGN KEY (user_id) REFERENCES public.users(id) ON DELETE CASCADE;
+
+
+--
+-- Name: webhook_deliveries webhook_deliveries_webhook_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: cloudquotes
+--
+
+ALTER TABLE ONLY public.webhook_deliveries
+    ADD CONSTRAINT webhook_deliveries_webhook_id_fkey FOREIGN KEY (webhook_id) REFERENCES public.webhooks(id) ON DELETE CASCADE;
+
+
+--
+-- Name: webhooks webhooks_archive_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: cloudquotes
+--
+
+ALTER TABLE ONLY public.webhooks
+    ADD CONSTRAINT webhooks_archive_id_fkey FOREIGN KEY (archive_id) REFERENCES public.archives(id) ON DELETE CASCADE;
 
 
 --
@@ -17,8 +17,19 @@ type Annotation struct {
 	CreatedAt time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
 	Note      string    `json:"note" db:"note" form:"Annotation"`
+	ArchiveID uuid.UUID `json:"archive_id" db:"archive_id" form:"-"`
 }
 
+// AnnotationCredit lets me show how many quotes share each annotation
+type AnnotationCredit struct {
+	ID    uuid.UUID `json:"id" db:"id"`
+	Note  string    `json:"note" db:"note"`
+	Count int       `json:"count" db:"count"`
+}
+
+// AnnotationCredits holds all the annotations
+type AnnotationCredits []AnnotationCredit
+
 // String is not required by pop and may be deleted
 func (a Annotation) String() string {
 	ja, _ := json.Marshal(a)
@@ -39,7 +50,7 @@ func (a Annotations) String() string {
 func (a *Annotation) Validate(tx *pop.Connection) (*validate.Errors, error) {
 	return validate.Validate(
 		&validators.StringIsPresent{Field: a.Note, Name: "Note"},
-		&validators.StringLengthInRange{Field: a.Note, Name: "Note", Min: 0, Max: 255, Message: "length must be <255"},
+		&validators.StringLengthInRange{Field: a.Note, Name: "Note", Min: 0, Max: MaxQuoteLength, Message: fmt.Sprintf("length must be <%d", MaxQuoteLength)},
 	), nil
 }
 
@@ -56,14 +67,14 @@ func (a *Annotation) ValidateUpdate(tx *pop.Connection) (*validate.Errors, error
 }
 
 // FindByNote tries to find the note already saved in the
-// annotations table.
+// annotations table for the annotation's archive.
 // If it does, it returns the full annotation record.
 // If it does not, it returns an annotation object that
 // holds the note, but has the ID set to uuid.NULL
 func (a *Annotation) FindByNote() error {
 
 	annoRecs := []Annotation{}
-	query := DB.Where(fmt.Sprintf("note = '%s'", a.Note))
+	query := DB.Where("note = ? AND archive_id = ?", a.Note, a.ArchiveID)
 	err := query.All(&annoRecs)
 
 	if err != nil {
@@ -102,3 +113,146 @@ func (a *Annotation) CheckID(db *pop.Connection) (*validate.Errors, error) {
 	// he created
 	return &ve, nil
 }
+
+// Quotes returns every quote that shares this annotation, leaving out
+// the ones in the trash
+func (a *Annotation) Quotes(tx *pop.Connection) (Quotes, error) {
+	quotes := Quotes{}
+
+	err := tx.Eager("Author", "CoAuthors").Where("annotation_id = ? AND deleted_at IS NULL", a.ID).Order("saidon ASC, sequence ASC").All(&quotes)
+
+	return quotes, err
+}
+
+// Rewrite changes the note for every quote sharing the annotation.  Notes
+// aren't repeated, so if the new text is already somebody else's note the
+// quotes get pointed over there and this annotation goes away.  The
+// annotation left holding the quotes is returned.
+func (a *Annotation) Rewrite(tx *pop.Connection, note string, editor uuid.UUID) (*Annotation, *validate.Errors, error) {
+	other := []Annotation{}
+
+	err := tx.Where("note = ? AND archive_id = ? AND id != ?", note, a.ArchiveID, a.ID).All(&other)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(other) > 0 {
+		quotes, err := a.Quotes(tx)
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		err = tx.RawQuery("UPDATE quotes SET annotation_id = ? WHERE annotation_id = ?", other[0].ID, a.ID).Exec()
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for i := range quotes {
+			quotes[i].AnnotationID = &other[0].ID
+			quotes[i].Annotation = &other[0]
+
+			if err = recordQuote(tx, &quotes[i], RevisionUpdate, editor); err != nil {
+				return nil, nil, err
+			}
+		}
+
+		if err = a.Remove(tx, editor); err != nil {
+			return nil, nil, err
+		}
+
+		return &other[0], validate.NewErrors(), nil
+	}
+
+	a.Note = note
+	verrs, err := tx.ValidateAndUpdate(a)
+
+	if err != nil || verrs.HasAny() {
+		return a, verrs, err
+	}
+
+	return a, verrs, recordAnnotation(tx, a, RevisionUpdate, editor)
+}
+
+// Fork gives one quote its own copy of the annotation with the new note,
+// leaving every other quote that shared it alone.  If the note is already
+// in the table, the quote just gets pointed at that one.
+func (a *Annotation) Fork(tx *pop.Connection, quoteID uuid.UUID, note string, editor uuid.UUID) (*Annotation, *validate.Errors, error) {
+	quote := &Quote{}
+
+	err := tx.Where("id = ? AND annotation_id = ?", quoteID, a.ID).First(quote)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	fork := &Annotation{ArchiveID: a.ArchiveID}
+	existing := []Annotation{}
+
+	if err = tx.Where("note = ? AND archive_id = ?", note, a.ArchiveID).All(&existing); err != nil {
+		return nil, nil, err
+	}
+
+	if len(existing) > 0 {
+		*fork = existing[0]
+	} else {
+		fork.Note = note
+		verrs, err := tx.ValidateAndCreate(fork)
+
+		if err != nil || verrs.HasAny() {
+			return nil, verrs, err
+		}
+
+		if err = recordAnnotation(tx, fork, RevisionCreate, editor); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	err = tx.RawQuery("UPDATE quotes SET annotation_id = ? WHERE id = ?", fork.ID, quote.ID).Exec()
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	quote.AnnotationID = &fork.ID
+	quote.Annotation = fork
+
+	return fork, validate.NewErrors(), recordQuote(tx, quote, RevisionUpdate, editor)
+}
+
+// Detach takes the annotation off every quote that uses it
+func (a *Annotation) Detach(tx *pop.Connection, editor uuid.UUID) error {
+	quotes, err := a.Quotes(tx)
+
+	if err != nil {
+		return err
+	}
+
+	err = tx.RawQuery("UPDATE quotes SET annotation_id = NULL WHERE annotation_id = ?", a.ID).Exec()
+
+	if err != nil {
+		return err
+	}
+
+	for i := range quotes {
+		quotes[i].AnnotationID = nil
+
+		if err = recordQuote(tx, &quotes[i], RevisionUpdate, editor); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Remove destroys the annotation, keeping its last note in the
+// revision history.
+func (a *Annotation) Remove(tx *pop.Connection, editor uuid.UUID) error {
+	if err := recordAnnotation(tx, a, RevisionDelete, editor); err != nil {
+		return err
+	}
+
+	return tx.Destroy(a)
+}
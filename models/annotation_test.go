@@ -52,10 +52,11 @@ const validAnnotation = "First Timer!"
 const invalidAnnotation = "*^*&^*&^*^*^&"
 
 func (ms *ModelSuite) Test_Annotation_FindByNote() {
-	ms.LoadFixture("test annotations")
+	_, annotations, _ := loadFixtureData(ms) // re-use from quote_test.go
 
 	ano := models.Annotation{
-		Note: validAnnotation,
+		Note:      validAnnotation,
+		ArchiveID: annotations[0].ArchiveID,
 	}
 
 	err := ano.FindByNote()
@@ -70,10 +71,11 @@ func (ms *ModelSuite) Test_Annotation_FindByNote() {
 }
 
 func (ms *ModelSuite) Test_Annotation_FindByNote_NoFind() {
-	ms.LoadFixture("test annotations")
+	_, annotations, _ := loadFixtureData(ms) // re-use from quote_test.go
 
 	ano := models.Annotation{
-		Note: invalidAnnotation,
+		Note:      invalidAnnotation,
+		ArchiveID: annotations[0].ArchiveID,
 	}
 
 	err := ano.FindByNote()
@@ -86,3 +88,30 @@ func (ms *ModelSuite) Test_Annotation_FindByNote_NoFind() {
 		ms.Fail("annotation FindByNote found invalid", ano.Note)
 	}
 }
+
+// rewriting a note into one that already exists moves the quotes over
+// and says so in each quote's history
+func (ms *ModelSuite) Test_Annotation_Rewrite_Merge() {
+	cv := savedConversation(ms, "rewrite-merge", "Who moved my stapler?")
+	quote := savedQuotes(ms, cv)[0]
+
+	old := &models.Annotation{Note: "Said at lunch", ArchiveID: cv.ArchiveID}
+	ms.NoError(ms.DB.Create(old))
+
+	kept := &models.Annotation{Note: "Said over lunch", ArchiveID: cv.ArchiveID}
+	ms.NoError(ms.DB.Create(kept))
+
+	ms.NoError(ms.DB.RawQuery("UPDATE quotes SET annotation_id = ? WHERE id = ?", old.ID, quote.ID).Exec())
+
+	result, verrs, err := old.Rewrite(ms.DB, kept.Note, uuid.Nil)
+	ms.NoError(err)
+	ms.False(verrs.HasAny())
+	ms.Equal(kept.ID, result.ID)
+
+	ms.NoError(ms.DB.Find(&quote, quote.ID))
+	ms.Equal(kept.ID, *quote.AnnotationID)
+
+	revision := &models.Revision{}
+	ms.NoError(ms.DB.Where("item_id = ? AND action = ?", quote.ID, models.RevisionUpdate).Order("created_at DESC").First(revision))
+	ms.Equal(kept.Note, revision.Fields()["annotation"])
+}
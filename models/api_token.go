@@ -0,0 +1,234 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/gobuffalo/pop/v5"
+	"github.com/gobuffalo/validate/v3"
+	"github.com/gobuffalo/validate/v3/validators"
+	"github.com/gofrs/uuid"
+)
+
+// APITokenPrefix starts every API token, so one pasted somewhere it
+// shouldn't be is easy to spot
+const APITokenPrefix = "cq_"
+
+// ScopeRead lets a token look at anything its user can see
+const ScopeRead = "read"
+
+// ScopeWrite lets a token add conversations as its user
+const ScopeWrite = "write"
+
+// Scopes are what a token can be allowed to do
+var Scopes = []string{ScopeRead, ScopeWrite}
+
+// ErrBadAPIToken is returned for a token that doesn't belong to anyone,
+// or has expired or been revoked
+var ErrBadAPIToken = errors.New("invalid API token")
+
+// APIToken lets a script or bot call the API as the user it belongs
+// to.  Only a hash of the token is kept, the token itself is shown
+// once, when it is issued, and never again.  Scopes is a comma
+// separated list of what the token is allowed to do.  A token without
+// an ExpiresAt lasts until it is revoked.
+type APIToken struct {
+	ID         uuid.UUID  `json:"id" db:"id"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at" db:"updated_at"`
+	UserID     uuid.UUID  `json:"user_id" db:"user_id"`
+	Name       string     `json:"name" db:"name"`
+	TokenHash  string     `json:"-" db:"token_hash"`
+	Scopes     string     `json:"scopes" db:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at" db:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at" db:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at" db:"revoked_at"`
+}
+
+// String is not required by pop and may be deleted
+func (t APIToken) String() string {
+	jt, _ := json.Marshal(t)
+	return string(jt)
+}
+
+// APITokens is not required by pop and may be deleted
+type APITokens []APIToken
+
+// String is not required by pop and may be deleted
+func (t APITokens) String() string {
+	jt, _ := json.Marshal(t)
+	return string(jt)
+}
+
+// Validate gets run every time you call a "pop.Validate*" (pop.ValidateAndSave, pop.ValidateAndCreate, pop.ValidateAndUpdate) method.
+// This method is not required and may be deleted.
+func (t *APIToken) Validate(tx *pop.Connection) (*validate.Errors, error) {
+	return validate.Validate(
+		&validators.StringIsPresent{Field: t.Name, Name: "Name"},
+		&validators.StringLengthInRange{Field: t.Name, Name: "Name", Max: 255, Message: "length must be <255"},
+		&validators.StringIsPresent{Field: t.TokenHash, Name: "TokenHash"},
+		&validators.UUIDIsPresent{Field: t.UserID, Name: "UserID"},
+		&validators.FuncValidator{
+			Field:   t.Scopes,
+			Name:    "Scopes",
+			Message: "%s has to be some of read and write",
+			Fn: func() bool {
+				scopes := t.ScopeList()
+				if len(scopes) == 0 {
+					return false
+				}
+				for _, s := range scopes {
+					if !knownScope(s) {
+						return false
+					}
+				}
+				return true
+			},
+		},
+	), nil
+}
+
+// ValidateCreate gets run every time you call "pop.ValidateAndCreate" method.
+// This method is not required and may be deleted.
+func (t *APIToken) ValidateCreate(tx *pop.Connection) (*validate.Errors, error) {
+	return validate.NewErrors(), nil
+}
+
+// ValidateUpdate gets run every time you call "pop.ValidateAndUpdate" method.
+// This method is not required and may be deleted.
+func (t *APIToken) ValidateUpdate(tx *pop.Connection) (*validate.Errors, error) {
+	return validate.NewErrors(), nil
+}
+
+// ScopeList splits the token's scopes apart
+func (t APIToken) ScopeList() []string {
+	scopes := []string{}
+
+	for _, s := range strings.Split(t.Scopes, ",") {
+		if s = strings.TrimSpace(s); len(s) > 0 {
+			scopes = append(scopes, s)
+		}
+	}
+
+	return scopes
+}
+
+// HasScope reports if the token is allowed to do what scope covers
+func (t APIToken) HasScope(scope string) bool {
+	for _, s := range t.ScopeList() {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Active reports if the token can still be used at the time passed,
+// that is it hasn't been revoked or run out
+func (t APIToken) Active(now time.Time) bool {
+	if t.RevokedAt != nil {
+		return false
+	}
+
+	return t.ExpiresAt == nil || now.Before(*t.ExpiresAt)
+}
+
+// Revoke stops the token from working, it is kept so its user can still
+// see what it was and when it was last used
+func (t *APIToken) Revoke(tx *pop.Connection) error {
+	if t.RevokedAt != nil {
+		return nil
+	}
+
+	now := time.Now()
+	t.RevokedAt = &now
+
+	return tx.Update(t)
+}
+
+// UserAPITokens lists the tokens a user has issued, newest first
+func UserAPITokens(tx *pop.Connection, userID uuid.UUID) (APITokens, error) {
+	tokens := APITokens{}
+
+	err := tx.Where("user_id = ?", userID).Order("created_at DESC").All(&tokens)
+
+	return tokens, err
+}
+
+// knownScope checks the scope is one of Scopes
+func knownScope(scope string) bool {
+	for _, s := range Scopes {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+// HashAPIToken is what gets stored in place of the token.  The tokens
+// are long and random, so a plain SHA-256 is enough and lets the token
+// be looked up by its hash.
+func HashAPIToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Issue makes up a new token for t.UserID and saves its hash.  The
+// token comes back so it can be handed over, it can't be had again.
+func (t *APIToken) Issue(tx *pop.Connection) (string, *validate.Errors, error) {
+	b := make([]byte, 32)
+
+	if _, err := rand.Read(b); err != nil {
+		return "", nil, err
+	}
+
+	if len(t.Scopes) == 0 {
+		t.Scopes = ScopeRead
+	}
+
+	token := APITokenPrefix + hex.EncodeToString(b)
+	t.TokenHash = HashAPIToken(token)
+
+	verrs, err := tx.ValidateAndCreate(t)
+
+	if err != nil || verrs.HasAny() {
+		return "", verrs, err
+	}
+
+	return token, verrs, nil
+}
+
+// FindAPIToken looks up the token a request was made with, and notes
+// that it was just used.  Expired and revoked tokens are turned away.
+func FindAPIToken(tx *pop.Connection, token string) (*APIToken, error) {
+	if !strings.HasPrefix(token, APITokenPrefix) {
+		return nil, ErrBadAPIToken
+	}
+
+	tokens := APITokens{}
+
+	if err := tx.Where("token_hash = ?", HashAPIToken(token)).All(&tokens); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+
+	if len(tokens) == 0 || !tokens[0].Active(now) {
+		return nil, ErrBadAPIToken
+	}
+
+	t := &tokens[0]
+	t.LastUsedAt = &now
+
+	// only the one column, so using a token doesn't look like an edit
+	err := tx.RawQuery("UPDATE api_tokens SET last_used_at = ? WHERE id = ?", now, t.ID).Exec()
+
+	return t, err
+}
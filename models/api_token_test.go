@@ -0,0 +1,57 @@
+package models_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/navionguy/cloudquotes/models"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_HashAPIToken(t *testing.T) {
+	rq := require.New(t)
+
+	h := models.HashAPIToken(models.APITokenPrefix + "abc")
+
+	rq.Len(h, 64)
+	rq.Equal(h, models.HashAPIToken(models.APITokenPrefix+"abc"))
+	rq.NotEqual(h, models.HashAPIToken(models.APITokenPrefix+"abd"))
+	rq.False(strings.Contains(h, "abc"))
+}
+
+func Test_APIToken_Scopes(t *testing.T) {
+	rq := require.New(t)
+
+	tk := models.APIToken{Scopes: "read, write"}
+	rq.Equal([]string{models.ScopeRead, models.ScopeWrite}, tk.ScopeList())
+	rq.True(tk.HasScope(models.ScopeWrite))
+
+	tk.Scopes = models.ScopeRead
+	rq.True(tk.HasScope(models.ScopeRead))
+	rq.False(tk.HasScope(models.ScopeWrite))
+
+	tk.Scopes = ""
+	rq.Empty(tk.ScopeList())
+}
+
+func Test_APIToken_Active(t *testing.T) {
+	rq := require.New(t)
+
+	now := time.Now()
+	later := now.Add(time.Hour)
+	earlier := now.Add(-time.Hour)
+
+	tk := models.APIToken{}
+	rq.True(tk.Active(now))
+
+	tk.ExpiresAt = &later
+	rq.True(tk.Active(now))
+
+	tk.ExpiresAt = &earlier
+	rq.False(tk.Active(now))
+
+	tk.ExpiresAt = nil
+	tk.RevokedAt = &earlier
+	rq.False(tk.Active(now))
+}
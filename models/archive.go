@@ -0,0 +1,287 @@
+package models
+
+import (
+	"encoding/json"
+	"regexp"
+	"time"
+
+	"github.com/gobuffalo/envy"
+	"github.com/gobuffalo/pop/v5"
+	"github.com/gobuffalo/validate/v3"
+	"github.com/gobuffalo/validate/v3/validators"
+	"github.com/gofrs/uuid"
+)
+
+// RoleMember can read and add to an archive
+const RoleMember = "member"
+
+// RoleAdmin can do anything in an archive, including deciding who
+// else belongs to it
+const RoleAdmin = "admin"
+
+// Roles are what a user can be in an archive.  An editor or moderator
+// there has the same powers PermEditor and PermModerator give across
+// the whole site, only limited to that archive.
+var Roles = []string{RoleMember, PermEditor, PermModerator, RoleAdmin}
+
+// DefaultArchiveSlug names the archive visitors who aren't a member of
+// anything get to see.  Everything from before there were archives was
+// moved into it.
+var DefaultArchiveSlug = envy.Get("DEFAULT_ARCHIVE", "default")
+
+var slugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// Archive is one team's collection of quotes.  Conversations, authors,
+// tags and annotations all belong to exactly one archive, and nobody
+// outside it gets to see them.
+type Archive struct {
+	ID        uuid.UUID `json:"id" db:"id"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+	Name      string    `json:"name" db:"name" form:"name"`
+	Slug      string    `json:"slug" db:"slug" form:"slug"`
+}
+
+// String is not required by pop and may be deleted
+func (a Archive) String() string {
+	ja, _ := json.Marshal(a)
+	return string(ja)
+}
+
+// Archives is not required by pop and may be deleted
+type Archives []Archive
+
+// String is not required by pop and may be deleted
+func (a Archives) String() string {
+	ja, _ := json.Marshal(a)
+	return string(ja)
+}
+
+// Validate gets run every time you call a "pop.Validate*" (pop.ValidateAndSave, pop.ValidateAndCreate, pop.ValidateAndUpdate) method.
+// This method is not required and may be deleted.
+func (a *Archive) Validate(tx *pop.Connection) (*validate.Errors, error) {
+	var err error
+	return validate.Validate(
+		&validators.StringIsPresent{Field: a.Name, Name: "Name"},
+		&validators.StringLengthInRange{Field: a.Name, Name: "Name", Max: 255, Message: "length must be <255"},
+		&validators.RegexMatch{Field: a.Slug, Name: "Slug", Expr: slugPattern.String(), Message: "slug can only be lower case letters, numbers and dashes"},
+		&validators.StringLengthInRange{Field: a.Slug, Name: "Slug", Max: 64, Message: "length must be <64"},
+		// the slug is how an archive gets picked out, so no repeats
+		&validators.FuncValidator{
+			Field:   a.Slug,
+			Name:    "Slug",
+			Message: "%s is already taken",
+			Fn: func() bool {
+				var b bool
+				q := tx.Where("slug = ?", a.Slug)
+				if a.ID != uuid.Nil {
+					q = q.Where("id != ?", a.ID)
+				}
+				b, err = q.Exists(&Archive{})
+				if err != nil {
+					return false
+				}
+				return !b
+			},
+		},
+	), err
+}
+
+// ValidateCreate gets run every time you call "pop.ValidateAndCreate" method.
+// This method is not required and may be deleted.
+func (a *Archive) ValidateCreate(tx *pop.Connection) (*validate.Errors, error) {
+	return validate.NewErrors(), nil
+}
+
+// ValidateUpdate gets run every time you call "pop.ValidateAndUpdate" method.
+// This method is not required and may be deleted.
+func (a *Archive) ValidateUpdate(tx *pop.Connection) (*validate.Errors, error) {
+	return validate.NewErrors(), nil
+}
+
+// Membership says a user belongs to an archive, and what they can do
+// there.
+type Membership struct {
+	ID        uuid.UUID `json:"id" db:"id"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+	ArchiveID uuid.UUID `json:"archive_id" db:"archive_id"`
+	UserID    uuid.UUID `json:"user_id" db:"user_id"`
+	Role      string    `json:"role" db:"role"`
+}
+
+// String is not required by pop and may be deleted
+func (m Membership) String() string {
+	jm, _ := json.Marshal(m)
+	return string(jm)
+}
+
+// Memberships is not required by pop and may be deleted
+type Memberships []Membership
+
+// String is not required by pop and may be deleted
+func (m Memberships) String() string {
+	jm, _ := json.Marshal(m)
+	return string(jm)
+}
+
+// Validate gets run every time you call a "pop.Validate*" (pop.ValidateAndSave, pop.ValidateAndCreate, pop.ValidateAndUpdate) method.
+// This method is not required and may be deleted.
+func (m *Membership) Validate(tx *pop.Connection) (*validate.Errors, error) {
+	return validate.Validate(
+		&validators.StringInclusion{Field: m.Role, Name: "Role", List: Roles},
+	), nil
+}
+
+// ValidateCreate gets run every time you call "pop.ValidateAndCreate" method.
+// This method is not required and may be deleted.
+func (m *Membership) ValidateCreate(tx *pop.Connection) (*validate.Errors, error) {
+	return validate.NewErrors(), nil
+}
+
+// ValidateUpdate gets run every time you call "pop.ValidateAndUpdate" method.
+// This method is not required and may be deleted.
+func (m *Membership) ValidateUpdate(tx *pop.Connection) (*validate.Errors, error) {
+	return validate.NewErrors(), nil
+}
+
+// Member is one person on an archive's member list
+type Member struct {
+	UserID uuid.UUID `json:"user_id" db:"user_id"`
+	Email  string    `json:"email" db:"email"`
+	Role   string    `json:"role" db:"role"`
+}
+
+// Members is everybody in an archive
+type Members []Member
+
+// DefaultArchive finds the archive named by DefaultArchiveSlug, creating
+// it if this is a brand new database.
+func DefaultArchive(tx *pop.Connection) (*Archive, error) {
+	archives := Archives{}
+
+	if err := tx.Where("slug = ?", DefaultArchiveSlug).All(&archives); err != nil {
+		return nil, err
+	}
+
+	if len(archives) > 0 {
+		return &archives[0], nil
+	}
+
+	archive := &Archive{Name: "Cloud Quotes", Slug: DefaultArchiveSlug}
+
+	return archive, tx.Create(archive)
+}
+
+// FindArchive looks up an archive by its slug
+func FindArchive(tx *pop.Connection, slug string) (*Archive, error) {
+	archive := &Archive{}
+
+	return archive, tx.Where("slug = ?", slug).First(archive)
+}
+
+// UserArchives lists the archives the user belongs to, by name
+func UserArchives(tx *pop.Connection, user uuid.UUID) (Archives, error) {
+	archives := Archives{}
+
+	if user == uuid.Nil {
+		return archives, nil
+	}
+
+	err := tx.Where("id IN (SELECT archive_id FROM memberships WHERE user_id = ?)", user).Order("name").All(&archives)
+
+	return archives, err
+}
+
+// Create saves a new archive and makes the owner its admin
+func (a *Archive) Create(tx *pop.Connection, owner uuid.UUID) (*validate.Errors, error) {
+	verrs, err := tx.ValidateAndCreate(a)
+
+	if err != nil || verrs.HasAny() {
+		return verrs, err
+	}
+
+	return a.SetMember(tx, owner, RoleAdmin)
+}
+
+// Role returns what the user is in the archive, or an empty string if
+// they don't belong to it.
+func (a Archive) Role(tx *pop.Connection, user uuid.UUID) (string, error) {
+	memberships := Memberships{}
+
+	if user == uuid.Nil {
+		return "", nil
+	}
+
+	if err := tx.Where("archive_id = ? AND user_id = ?", a.ID, user).All(&memberships); err != nil {
+		return "", err
+	}
+
+	if len(memberships) == 0 {
+		return "", nil
+	}
+
+	return memberships[0].Role, nil
+}
+
+// Allows reports if the user has the named permission in the archive.
+// Admins have every permission, and a permission granted across the
+// whole site counts in every archive.
+func (a Archive) Allows(tx *pop.Connection, user uuid.UUID, perm string) (bool, error) {
+	role, err := a.Role(tx, user)
+
+	if err != nil {
+		return false, err
+	}
+
+	if role == RoleAdmin || (role != "" && role == perm) {
+		return true, nil
+	}
+
+	return HasPermission(tx, user, perm)
+}
+
+// SetMember adds the user to the archive, or changes their role if
+// they already belong to it.
+func (a Archive) SetMember(tx *pop.Connection, user uuid.UUID, role string) (*validate.Errors, error) {
+	memberships := Memberships{}
+
+	if err := tx.Where("archive_id = ? AND user_id = ?", a.ID, user).All(&memberships); err != nil {
+		return nil, err
+	}
+
+	if len(memberships) > 0 {
+		memberships[0].Role = role
+		return tx.ValidateAndUpdate(&memberships[0])
+	}
+
+	return tx.ValidateAndCreate(&Membership{ArchiveID: a.ID, UserID: user, Role: role})
+}
+
+// RemoveMember takes the user out of the archive
+func (a Archive) RemoveMember(tx *pop.Connection, user uuid.UUID) error {
+	return tx.RawQuery("DELETE FROM memberships WHERE archive_id = ? AND user_id = ?", a.ID, user).Exec()
+}
+
+// conversationArchive looks up which archive the conversation belongs to
+func conversationArchive(tx *pop.Connection, id uuid.UUID) (uuid.UUID, error) {
+	row := struct {
+		ArchiveID uuid.UUID `db:"archive_id"`
+	}{}
+
+	err := tx.RawQuery("SELECT archive_id FROM conversations WHERE id = ?", id).First(&row)
+
+	return row.ArchiveID, err
+}
+
+// Members lists everyone in the archive, by email
+func (a Archive) Members(tx *pop.Connection) (Members, error) {
+	members := Members{}
+
+	err := tx.RawQuery(`SELECT memberships.user_id, users.email, memberships.role FROM memberships
+		JOIN users ON users.id = memberships.user_id
+		WHERE memberships.archive_id = ?
+		ORDER BY users.email`, a.ID).All(&members)
+
+	return members, err
+}
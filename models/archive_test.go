@@ -0,0 +1,75 @@
+package models_test
+
+import (
+	"testing"
+
+	"github.com/navionguy/cloudquotes/models"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Archive(t *testing.T) {
+
+	var fields = []struct {
+		fn  string
+		msg string
+	}{
+		{"id", "archive id field not found"},
+		{"name", "name field not found"},
+		{"slug", "slug field not found"},
+		{"created_at", "created_at field not found"},
+		{"updated_at", "updated_at field not found"},
+	}
+
+	a := models.Archive{}
+
+	js := a.String()
+
+	rq := require.New(t)
+
+	for _, fld := range fields {
+		rq.Containsf(js, fld.fn, fld.msg)
+	}
+}
+
+func Test_Membership(t *testing.T) {
+
+	var fields = []struct {
+		fn  string
+		msg string
+	}{
+		{"id", "membership id field not found"},
+		{"archive_id", "archive_id field not found"},
+		{"user_id", "user_id field not found"},
+		{"role", "role field not found"},
+		{"created_at", "created_at field not found"},
+		{"updated_at", "updated_at field not found"},
+	}
+
+	m := models.Membership{}
+
+	js := m.String()
+
+	rq := require.New(t)
+
+	for _, fld := range fields {
+		rq.Containsf(js, fld.fn, fld.msg)
+	}
+}
+
+func Test_Membership_Validate(t *testing.T) {
+	rq := require.New(t)
+
+	for _, role := range models.Roles {
+		m := models.Membership{Role: role}
+
+		verrs, err := m.Validate(nil)
+		rq.NoError(err)
+		rq.Falsef(verrs.HasAny(), "%s should be a valid role", role)
+	}
+
+	m := models.Membership{Role: "owner"}
+
+	verrs, err := m.Validate(nil)
+	rq.NoError(err)
+	rq.True(verrs.HasAny())
+}
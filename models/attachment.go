@@ -0,0 +1,237 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gobuffalo/pop/v5"
+	"github.com/gobuffalo/validate/v3"
+	"github.com/gobuffalo/validate/v3/validators"
+	"github.com/gofrs/uuid"
+	"github.com/navionguy/cloudquotes/storage"
+)
+
+// MaxAttachmentSize is the biggest file that can be attached, 10MB is
+// plenty for a photo or a short audio clip.
+const MaxAttachmentSize = 10 << 20
+
+// AttachmentTypes are the kinds of file that can be attached
+var AttachmentTypes = []string{
+	"image/gif",
+	"image/jpeg",
+	"image/png",
+	"image/webp",
+	"audio/mpeg",
+	"audio/mp4",
+	"audio/ogg",
+	"audio/wave",
+	"audio/webm",
+}
+
+// Attachment is an audio clip, screenshot or photo that goes along with
+// a conversation.  The file itself lives in the storage.Store under the
+// attachment's ID, the row just says what it is.
+type Attachment struct {
+	ID             uuid.UUID  `json:"id" db:"id"`
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at" db:"updated_at"`
+	ConversationID uuid.UUID  `json:"conversation_id" db:"conversation_id"`
+	UserID         *uuid.UUID `json:"user_id" db:"user_id"`
+	Filename       string     `json:"filename" db:"filename"`
+	ContentType    string     `json:"content_type" db:"content_type"`
+	Size           int        `json:"size" db:"size"`
+}
+
+// String is not required by pop and may be deleted
+func (a Attachment) String() string {
+	ja, _ := json.Marshal(a)
+	return string(ja)
+}
+
+// Attachments is not required by pop and may be deleted
+type Attachments []Attachment
+
+// String is not required by pop and may be deleted
+func (a Attachments) String() string {
+	ja, _ := json.Marshal(a)
+	return string(ja)
+}
+
+// Validate gets run every time you call a "pop.Validate*" (pop.ValidateAndSave, pop.ValidateAndCreate, pop.ValidateAndUpdate) method.
+// This method is not required and may be deleted.
+func (a *Attachment) Validate(tx *pop.Connection) (*validate.Errors, error) {
+	return validate.Validate(
+		&validators.StringIsPresent{Field: a.Filename, Name: "Filename"},
+		&validators.StringLengthInRange{Field: a.Filename, Name: "Filename", Max: 255, Message: "length must be <255"},
+		&validators.StringInclusion{Field: a.ContentType, Name: "ContentType", List: AttachmentTypes, Message: a.Filename + " isn't a picture or audio clip that can be attached"},
+		&validators.IntIsGreaterThan{Field: a.Size, Name: "Size", Compared: 0, Message: a.Filename + " is empty"},
+		&validators.IntIsLessThan{Field: a.Size, Name: "Size", Compared: MaxAttachmentSize + 1, Message: a.Filename + " is bigger than 10MB"},
+	), nil
+}
+
+// ValidateCreate gets run every time you call "pop.ValidateAndCreate" method.
+// This method is not required and may be deleted.
+func (a *Attachment) ValidateCreate(tx *pop.Connection) (*validate.Errors, error) {
+	return validate.NewErrors(), nil
+}
+
+// ValidateUpdate gets run every time you call "pop.ValidateAndUpdate" method.
+// This method is not required and may be deleted.
+func (a *Attachment) ValidateUpdate(tx *pop.Connection) (*validate.Errors, error) {
+	return validate.NewErrors(), nil
+}
+
+// audioContainers are the audio files sniffing can't tell apart from a
+// video, so the extension settles it.
+var audioContainers = map[string]string{
+	".m4a":  "audio/mp4",
+	".oga":  "audio/ogg",
+	".ogg":  "audio/ogg",
+	".opus": "audio/ogg",
+	".weba": "audio/webm",
+}
+
+// NewAttachment sizes up an uploaded file.  The content type comes from
+// sniffing the data rather than trusting whatever the browser said.
+func NewAttachment(filename string, data []byte) *Attachment {
+	ct := http.DetectContentType(data)
+
+	// drop any "; charset=" or the like
+	if i := strings.Index(ct, ";"); i >= 0 {
+		ct = ct[:i]
+	}
+
+	switch ct {
+	case "application/ogg", "video/mp4", "video/webm":
+		if audio, ok := audioContainers[strings.ToLower(filepath.Ext(filename))]; ok {
+			ct = audio
+		}
+	}
+
+	return &Attachment{
+		Filename:    filename,
+		ContentType: ct,
+		Size:        len(data),
+	}
+}
+
+// IsImage reports if the attachment can be shown with an img tag
+func (a Attachment) IsImage() bool {
+	return strings.HasPrefix(a.ContentType, "image/")
+}
+
+// IsAudio reports if the attachment can be played with an audio tag
+func (a Attachment) IsAudio() bool {
+	return strings.HasPrefix(a.ContentType, "audio/")
+}
+
+// Save adds the attachment to the conversation and puts the file in the
+// store.
+func (a *Attachment) Save(tx *pop.Connection, st storage.Store, conversationID uuid.UUID, data []byte, user uuid.UUID) (*validate.Errors, error) {
+	a.ConversationID = conversationID
+
+	if user != uuid.Nil {
+		a.UserID = &user
+	}
+
+	verrs, err := tx.ValidateAndCreate(a)
+
+	if err != nil || verrs.HasAny() {
+		return verrs, err
+	}
+
+	return verrs, st.Put(a.ID.String(), bytes.NewReader(data))
+}
+
+// Remove deletes the attachment.  Its file is queued for removal, to go
+// once tx commits.
+func (a *Attachment) Remove(tx *pop.Connection) error {
+	if err := tx.Destroy(a); err != nil {
+		return err
+	}
+
+	return tx.Create(&FileRemoval{Key: a.ID.String()})
+}
+
+// ConversationAttachments lists the files attached to the conversation,
+// in the order they were added.
+func ConversationAttachments(tx *pop.Connection, id uuid.UUID) (Attachments, error) {
+	attachments := Attachments{}
+
+	err := tx.Where("conversation_id = ?", id).Order("created_at").All(&attachments)
+
+	return attachments, err
+}
+
+// removeAttachments deletes every file attached to the conversation
+func removeAttachments(tx *pop.Connection, id uuid.UUID) error {
+	attachments, err := ConversationAttachments(tx, id)
+
+	if err != nil {
+		return err
+	}
+
+	for i := range attachments {
+		if err = attachments[i].Remove(tx); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// fileRemovalBatch is how many files get removed each time RemoveFiles
+// is run
+const fileRemovalBatch = 100
+
+// FileRemoval is a file waiting to be taken out of the store.  A file
+// can't be deleted alongside its row, a rolled back transaction would
+// bring the row back without it.  So the removal is queued in the same
+// transaction instead and only shows up once that commits.
+type FileRemoval struct {
+	ID        uuid.UUID `json:"id" db:"id"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+	Key       string    `json:"key" db:"key"`
+}
+
+// FileRemovals is not required by pop and may be deleted
+type FileRemovals []FileRemoval
+
+// RemoveFiles deletes the queued files from the store, returning how
+// many were removed.  A file the store won't delete stays queued to be
+// tried again next time, the first error is returned once the rest of
+// the batch has been gone through.
+func RemoveFiles(db *pop.Connection, st storage.Store) (int, error) {
+	queued := FileRemovals{}
+
+	if err := db.Order("created_at ASC").Limit(fileRemovalBatch).All(&queued); err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	var failed error
+
+	for i := range queued {
+		err := st.Delete(queued[i].Key)
+
+		if err == nil {
+			err = db.Destroy(&queued[i])
+		}
+
+		if err != nil {
+			if failed == nil {
+				failed = err
+			}
+			continue
+		}
+
+		removed++
+	}
+
+	return removed, failed
+}
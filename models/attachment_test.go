@@ -0,0 +1,132 @@
+package models_test
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/gobuffalo/pop/v5"
+	"github.com/gofrs/uuid"
+	"github.com/navionguy/cloudquotes/models"
+	"github.com/navionguy/cloudquotes/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Attachment(t *testing.T) {
+
+	var fields = []struct {
+		fn  string
+		msg string
+	}{
+		{"id", "attachment id field not found"},
+		{"conversation_id", "conversation_id field not found"},
+		{"user_id", "user_id field not found"},
+		{"filename", "filename field not found"},
+		{"content_type", "content_type field not found"},
+		{"size", "size field not found"},
+		{"created_at", "created_at field not found"},
+		{"updated_at", "updated_at field not found"},
+	}
+
+	a := models.Attachment{}
+
+	js := a.String()
+
+	rq := require.New(t)
+
+	for _, fld := range fields {
+		rq.Containsf(js, fld.fn, fld.msg)
+	}
+}
+
+func Test_NewAttachment(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+	m4a := []byte("\x00\x00\x00\x18ftypM4A \x00\x00\x00\x00mp42isom")
+
+	var tests = []struct {
+		name  string
+		data  []byte
+		ct    string
+		image bool
+		audio bool
+		valid bool
+	}{
+		{"whiteboard.png", png, "image/png", true, false, true},
+		{"lying.txt", png, "image/png", true, false, true},
+		{"standup.m4a", m4a, "audio/mp4", false, true, true},
+		{"standup.mp4", m4a, "video/mp4", false, false, false},
+		{"notes.txt", []byte("just some text"), "text/plain", false, false, false},
+		{"empty.png", []byte{}, "text/plain", false, false, false},
+	}
+
+	rq := require.New(t)
+
+	for _, tt := range tests {
+		a := models.NewAttachment(tt.name, tt.data)
+
+		rq.Equal(tt.ct, a.ContentType, tt.name)
+		rq.Equal(len(tt.data), a.Size, tt.name)
+		rq.Equal(tt.image, a.IsImage(), tt.name)
+		rq.Equal(tt.audio, a.IsAudio(), tt.name)
+
+		verrs, err := a.Validate(nil)
+		rq.NoError(err)
+		rq.Equal(tt.valid, !verrs.HasAny(), tt.name)
+	}
+}
+
+func Test_Attachment_TooBig(t *testing.T) {
+	data := bytes.Repeat([]byte{0}, models.MaxAttachmentSize+1)
+	copy(data, "\x89PNG\r\n\x1a\n")
+
+	a := models.NewAttachment("huge.png", data)
+
+	verrs, err := a.Validate(nil)
+
+	rq := require.New(t)
+	rq.NoError(err)
+	rq.True(verrs.HasAny())
+}
+
+// a purge that gets rolled back leaves the files alone, one that commits
+// has them removed afterwards
+func (ms *ModelSuite) Test_Conversation_Purge_Files() {
+	dir, err := ioutil.TempDir("", "attachments")
+	ms.NoError(err)
+	defer os.RemoveAll(dir)
+
+	st := storage.NewDiskStore(dir)
+	cv := savedConversation(ms, "purge-files", "Look at this whiteboard.")
+
+	data := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+	a := models.NewAttachment("whiteboard.png", data)
+	verrs, err := a.Save(ms.DB, st, cv.ID, data, uuid.Nil)
+	ms.NoError(err)
+	ms.False(verrs.HasAny())
+
+	rollback := errors.New("rollback")
+	err = ms.DB.Transaction(func(tx *pop.Connection) error {
+		ms.NoError(cv.Purge(tx))
+		return rollback
+	})
+	ms.Equal(rollback, err)
+
+	n, err := models.RemoveFiles(ms.DB, st)
+	ms.NoError(err)
+	ms.Equal(0, n)
+
+	f, err := st.Get(a.ID.String())
+	ms.NoError(err)
+	f.Close()
+
+	ms.NoError(cv.Purge(ms.DB))
+
+	n, err = models.RemoveFiles(ms.DB, st)
+	ms.NoError(err)
+	ms.Equal(1, n)
+
+	_, err = st.Get(a.ID.String())
+	ms.Equal(storage.ErrNotFound, err)
+}
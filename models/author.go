@@ -9,27 +9,58 @@ import (
 
 	"github.com/gobuffalo/pop/v5"
 	"github.com/gobuffalo/validate/v3"
+	"github.com/gobuffalo/validate/v3/validators"
 	"github.com/gofrs/uuid"
 )
 
+// ErrAuthorNotFound is returned when a name doesn't match any author
+var ErrAuthorNotFound = errors.New("author name not found in db")
+
 // Author holds the name of somebody who authored a quote
 type Author struct {
-	ID        uuid.UUID `json:"id" db:"id"`
-	CreatedAt time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+	ID        uuid.UUID `json:"id" db:"id" form:"-"`
+	CreatedAt time.Time `json:"created_at" db:"created_at" form:"-"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at" form:"-"`
 	Name      string    `json:"name" db:"name" form:"name"`
+	Bio       string    `json:"bio" db:"bio" form:"bio"`
+	Team      string    `json:"team" db:"team" form:"team"`
+	ArchiveID uuid.UUID `json:"archive_id" db:"archive_id" form:"-"`
+
+	// Visibility is how the author is named on anything the public
+	// sees, UserID is the account of the person they are, who gets to
+	// opt out of being published.
+	Visibility string     `json:"visibility" db:"visibility" form:"visibility"`
+	UserID     *uuid.UUID `json:"user_id" db:"user_id" form:"-"`
+	OptedOutAt *time.Time `json:"opted_out_at" db:"opted_out_at" form:"-"`
+
+	// Masked is set once the real name has been swapped out for
+	// whatever the author agreed to show
+	Masked bool `json:"-" db:"-" form:"-"`
+
+	// Relationships
+	Aliases AuthorAliases `json:"aliases" has_many:"author_aliases" db:"-" form:"-"`
 }
 
 // AuthorCredit allows me to find out how many quotes each author has
 type AuthorCredit struct {
-	ID    uuid.UUID `json:"id" db:"id"`
-	Name  string    `json:"name" db:"name"`
-	Count int       `json:"count" db:"count"`
+	ID         uuid.UUID `json:"id" db:"id"`
+	Name       string    `json:"name" db:"name"`
+	Count      int       `json:"count" db:"count"`
+	Visibility string    `json:"-" db:"visibility"`
 }
 
 // AuthorCredits holds all the authors
 type AuthorCredits []AuthorCredit
 
+// AuthorProfile is everything the author page shows about a speaker
+type AuthorProfile struct {
+	Author      Author        `json:"author"`
+	Quotes      Quotes        `json:"quotes"`
+	FirstQuoted time.Time     `json:"first_quoted"`
+	LastQuoted  time.Time     `json:"last_quoted"`
+	CoSpeakers  AuthorCredits `json:"co_speakers"`
+}
+
 // String is not required by pop and may be deleted
 func (a Author) String() string {
 	ja, _ := json.Marshal(a)
@@ -48,9 +79,16 @@ func (a Authors) String() string {
 // Validate gets run every time you call a "pop.Validate*" (pop.ValidateAndSave, pop.ValidateAndCreate, pop.ValidateAndUpdate) method.
 // This method is not required and may be deleted.
 func (a *Author) Validate(tx *pop.Connection) (*validate.Errors, error) {
+	// authors added without saying are shown in full, same as the
+	// column default
+	if len(a.Visibility) == 0 {
+		a.Visibility = VisibilityFull
+	}
+
 	return validate.Validate(
-	//&validators.StringIsPresent{Field: a.Name, Name: "Name"},
-	//&validators.StringLengthInRange{Field: a.Name, Name: "Name", Min: 1, Max: 255, Message: "length must be 1-255"},
+		//&validators.StringIsPresent{Field: a.Name, Name: "Name"},
+		//&validators.StringLengthInRange{Field: a.Name, Name: "Name", Min: 1, Max: 255, Message: "length must be 1-255"},
+		&validators.StringInclusion{Field: a.Visibility, Name: "Visibility", List: Visibilities},
 	), nil
 }
 
@@ -96,18 +134,23 @@ func (a *Author) FindByID() error {
 	return nil
 }
 
-// FindByName check for an author by name
+// FindByName check for an author by name in the author's archive
 func (a *Author) FindByName() error {
 	// name can't be empty
 	if len(a.Name) == 0 {
 		return errors.New("author name can't be blank")
 	}
 
+	// an exact match on the name, or any alias, beats a guess
+	if a.FindByExactName(DB) == nil {
+		return nil
+	}
+
 	// Break the passed name down into pieces
 	parts := strings.Split(a.Name, " ")
 
 	authRecs := []Author{}
-	query := DB.Where(fmt.Sprintf("name ILIKE '%%%s%%' AND name ILIKE '%%%s%%'", parts[0], parts[len(parts)-1]))
+	query := DB.Where("archive_id = ? AND name ILIKE ? AND name ILIKE ?", a.ArchiveID, "%"+parts[0]+"%", "%"+parts[len(parts)-1]+"%")
 	err := query.All(&authRecs)
 
 	if err != nil {
@@ -123,6 +166,41 @@ func (a *Author) FindByName() error {
 	return nil
 }
 
+// FindByExactName looks for the author in the author's archive whose
+// name, or one of whose aliases, matches the passed name.  Case doesn't
+// count, so "Bob Mcgowan" finds "Bob McGowan".
+func (a *Author) FindByExactName(tx *pop.Connection) error {
+	name := strings.Join(strings.Fields(a.Name), " ")
+
+	// name can't be empty
+	if len(name) == 0 {
+		return errors.New("author name can't be blank")
+	}
+
+	authRecs := []Author{}
+	err := tx.Where("archive_id = ? AND LOWER(name) = LOWER(?)", a.ArchiveID, name).All(&authRecs)
+
+	if err != nil {
+		return err
+	}
+
+	if len(authRecs) == 0 {
+		err = tx.Where("archive_id = ? AND id IN (SELECT author_id FROM author_aliases WHERE LOWER(name) = LOWER(?))", a.ArchiveID, name).All(&authRecs)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(authRecs) == 0 {
+		return ErrAuthorNotFound
+	}
+
+	*a = authRecs[0]
+
+	return nil
+}
+
 // Create adds a new speaker to the authors table
 func (a *Author) Create() (*validate.Errors, error) {
 
@@ -135,3 +213,50 @@ func (a *Author) Create() (*validate.Errors, error) {
 func (a *Author) Update() (*validate.Errors, error) {
 	return nil, nil
 }
+
+// Profile pulls together the author's quotes, oldest first, including
+// the ones they said along with others, and the people they most often
+// turn up in conversations with.  Unless all is set, only conversations
+// that have been published count.
+func (a *Author) Profile(tx *pop.Connection, all bool) (*AuthorProfile, error) {
+	p := &AuthorProfile{Author: *a}
+
+	err := tx.Where("(author_id = ? OR id IN (SELECT quote_id FROM quote_authors WHERE author_id = ?)) AND deleted_at IS NULL", a.ID, a.ID).
+		Where("(? OR conversation_id IN (SELECT id FROM conversations WHERE status = ?))", all, StatusPublished).
+		Order("saidon ASC, sequence ASC").All(&p.Quotes)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(p.Quotes) > 0 {
+		p.FirstQuoted = p.Quotes[0].SaidOn
+		p.LastQuoted = p.Quotes[len(p.Quotes)-1].SaidOn
+	}
+
+	// count the conversations each other speaker shares with this one,
+	// a co-author speaks the line as much as its author does
+	err = tx.RawQuery(`WITH speakers AS (
+			SELECT conversation_id, author_id FROM quotes
+			WHERE author_id IS NOT NULL AND deleted_at IS NULL
+			UNION
+			SELECT quotes.conversation_id, quote_authors.author_id FROM quote_authors
+			JOIN quotes ON quotes.id = quote_authors.quote_id
+			WHERE quotes.deleted_at IS NULL
+		)
+		SELECT authors.id, authors.name, authors.visibility, COUNT(DISTINCT theirs.conversation_id) AS count
+		FROM speakers mine
+		JOIN speakers theirs ON theirs.conversation_id = mine.conversation_id AND theirs.author_id != mine.author_id
+		JOIN authors ON authors.id = theirs.author_id
+		WHERE mine.author_id = ?
+		AND (? OR mine.conversation_id IN (SELECT id FROM conversations WHERE status = ?))
+		GROUP BY authors.id
+		ORDER BY count DESC, authors.name
+		LIMIT 5`, a.ID, all, StatusPublished).All(&p.CoSpeakers)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
@@ -0,0 +1,281 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/gobuffalo/pop/v5"
+	"github.com/gobuffalo/validate/v3"
+	"github.com/gobuffalo/validate/v3/validators"
+	"github.com/gofrs/uuid"
+)
+
+// ErrLinkedElsewhere is returned when a merge would fold together
+// authors linked to two different accounts, which means they are two
+// different people
+var ErrLinkedElsewhere = errors.New("the authors are linked to different accounts")
+
+// AuthorAlias is another spelling of an author's name.  "Bob Mcgowan"
+// and "Robert McGowan" both end up pointing at Bob McGowan.
+type AuthorAlias struct {
+	ID        uuid.UUID `json:"id" db:"id"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+	Name      string    `json:"name" db:"name"`
+
+	// Foreign keys
+	AuthorID uuid.UUID `json:"author_id" db:"author_id"`
+}
+
+// String is not required by pop and may be deleted
+func (a AuthorAlias) String() string {
+	ja, _ := json.Marshal(a)
+	return string(ja)
+}
+
+// AuthorAliases is not required by pop and may be deleted
+type AuthorAliases []AuthorAlias
+
+// String is not required by pop and may be deleted
+func (a AuthorAliases) String() string {
+	ja, _ := json.Marshal(a)
+	return string(ja)
+}
+
+// Validate gets run every time you call a "pop.Validate*" (pop.ValidateAndSave, pop.ValidateAndCreate, pop.ValidateAndUpdate) method.
+// This method is not required and may be deleted.
+func (a *AuthorAlias) Validate(tx *pop.Connection) (*validate.Errors, error) {
+	var err error
+	return validate.Validate(
+		&validators.StringIsPresent{Field: a.Name, Name: "Name"},
+		&validators.FuncValidator{
+			Field:   a.AuthorID.String(),
+			Name:    "AuthorID",
+			Message: "alias.AuthorID %s is NIL",
+			Fn: func() bool {
+				return !(a.AuthorID == uuid.Nil)
+			},
+		},
+		// an alias can only point at one author in the archive
+		&validators.FuncValidator{
+			Field:   a.Name,
+			Name:    "Name",
+			Message: "%s is already an alias",
+			Fn: func() bool {
+				var b bool
+				q := tx.Where("LOWER(name) = LOWER(?)", a.Name).
+					Where("author_id IN (SELECT id FROM authors WHERE archive_id = (SELECT archive_id FROM authors WHERE id = ?))", a.AuthorID)
+				if a.ID != uuid.Nil {
+					q = q.Where("id != ?", a.ID)
+				}
+				b, err = q.Exists(&AuthorAlias{})
+				if err != nil {
+					return false
+				}
+				return !b
+			},
+		},
+	), err
+}
+
+// ValidateCreate gets run every time you call "pop.ValidateAndCreate" method.
+// This method is not required and may be deleted.
+func (a *AuthorAlias) ValidateCreate(tx *pop.Connection) (*validate.Errors, error) {
+	return validate.NewErrors(), nil
+}
+
+// ValidateUpdate gets run every time you call "pop.ValidateAndUpdate" method.
+// This method is not required and may be deleted.
+func (a *AuthorAlias) ValidateUpdate(tx *pop.Connection) (*validate.Errors, error) {
+	return validate.NewErrors(), nil
+}
+
+// AliasList returns the author's aliases as a comma separated list
+func (a Author) AliasList() string {
+	names := []string{}
+	for _, alias := range a.Aliases {
+		names = append(names, alias.Name)
+	}
+	return strings.Join(names, ", ")
+}
+
+// SetAliases replaces the author's aliases with the names in the passed
+// comma separated list.  Blank names, and the author's own name, are
+// skipped.
+func (a *Author) SetAliases(tx *pop.Connection, list string) (*validate.Errors, error) {
+	err := tx.RawQuery("DELETE FROM author_aliases WHERE author_id = ?", a.ID).Exec()
+
+	if err != nil {
+		return nil, err
+	}
+
+	a.Aliases = AuthorAliases{}
+
+	for _, name := range strings.Split(list, ",") {
+		name = strings.Join(strings.Fields(name), " ")
+
+		if len(name) == 0 || strings.EqualFold(name, a.Name) {
+			continue
+		}
+
+		alias := AuthorAlias{Name: name, AuthorID: a.ID}
+		verrs, err := tx.ValidateAndCreate(&alias)
+
+		if err != nil || verrs.HasAny() {
+			return verrs, err
+		}
+
+		a.Aliases = append(a.Aliases, alias)
+	}
+
+	return validate.NewErrors(), nil
+}
+
+// MergeConsent takes on whatever the duplicate agreed to about being
+// published, so nobody ends up shown more than they asked for.  The
+// more restrictive visibility of the two is kept, an opt out by either
+// sticks, and the link to the person's account carries over.
+func (a *Author) MergeConsent(dup Author) error {
+	if dup.UserID != nil {
+		if a.UserID != nil && *a.UserID != *dup.UserID {
+			return ErrLinkedElsewhere
+		}
+
+		id := *dup.UserID
+		a.UserID = &id
+	}
+
+	if visibilityRank(dup.Visibility) > visibilityRank(a.Visibility) {
+		a.Visibility = dup.Visibility
+	}
+
+	if dup.OptedOutAt != nil && (a.OptedOutAt == nil || dup.OptedOutAt.Before(*a.OptedOutAt)) {
+		out := *dup.OptedOutAt
+		a.OptedOutAt = &out
+	}
+
+	return nil
+}
+
+// visibilityRank is how far down Visibilities the visibility is, the
+// higher the less gets shown
+func visibilityRank(visibility string) int {
+	for i, v := range Visibilities {
+		if v == visibility {
+			return i
+		}
+	}
+
+	return 0
+}
+
+// Merge folds the duplicate authors into this one.  Every quote the
+// duplicates were credited with, alone or along with others, moves
+// over, their names are kept as aliases so the seed loader and the
+// author filter still find them, and then the duplicates are removed.
+// It all happens within tx, so a failure part way leaves
+// nothing half merged.  The change of credit goes into each quote's
+// revision history.  The merged author takes on the consent of every
+// duplicate, see MergeConsent.
+func (a *Author) Merge(tx *pop.Connection, dups Authors, editor uuid.UUID) error {
+	// turn down a merge of two different people before anything changes
+	check := *a
+	for _, dup := range dups {
+		if dup.ID != a.ID && dup.ArchiveID == a.ArchiveID {
+			if err := check.MergeConsent(dup); err != nil {
+				return err
+			}
+		}
+	}
+
+	return inTransaction(tx, func(db *pop.Connection) error {
+		merged := []uuid.UUID{}
+
+		for i := range dups {
+			dup := &dups[i]
+
+			// authors from another archive are never duplicates
+			if dup.ID == a.ID || dup.ArchiveID != a.ArchiveID {
+				continue
+			}
+
+			if err := a.MergeConsent(*dup); err != nil {
+				return err
+			}
+
+			moved := Quotes{}
+			err := db.Where("author_id = ? OR id IN (SELECT quote_id FROM quote_authors WHERE author_id = ?)", dup.ID, dup.ID).All(&moved)
+			if err != nil {
+				return err
+			}
+
+			err = db.RawQuery("UPDATE quotes SET author_id = ? WHERE author_id = ?", a.ID, dup.ID).Exec()
+			if err != nil {
+				return err
+			}
+
+			// lines the two said together are only credited to the merged author once
+			err = db.RawQuery(`DELETE FROM quote_authors WHERE author_id IN (?, ?)
+				AND (quote_id IN (SELECT id FROM quotes WHERE author_id = ?)
+				OR (author_id = ? AND quote_id IN (SELECT quote_id FROM quote_authors WHERE author_id = ?)))`, a.ID, dup.ID, a.ID, dup.ID, a.ID).Exec()
+			if err != nil {
+				return err
+			}
+
+			err = db.RawQuery("UPDATE quote_authors SET author_id = ? WHERE author_id = ?", a.ID, dup.ID).Exec()
+			if err != nil {
+				return err
+			}
+
+			for j := range moved {
+				if moved[j].AuthorID != nil && *moved[j].AuthorID == dup.ID {
+					moved[j].AuthorID = &a.ID
+					moved[j].Author = *a
+				}
+
+				if err = recordQuote(db, &moved[j], RevisionUpdate, editor); err != nil {
+					return err
+				}
+			}
+
+			err = db.RawQuery("UPDATE author_aliases SET author_id = ? WHERE author_id = ?", a.ID, dup.ID).Exec()
+			if err != nil {
+				return err
+			}
+
+			// keep the duplicate's spelling around, unless it only differed by case
+			if !strings.EqualFold(dup.Name, a.Name) {
+				found, err := db.Where("LOWER(name) = LOWER(?)", dup.Name).Where("author_id IN (SELECT id FROM authors WHERE archive_id = ?)", a.ArchiveID).Exists(&AuthorAlias{})
+				if err != nil {
+					return err
+				}
+
+				if !found {
+					err = db.Create(&AuthorAlias{Name: dup.Name, AuthorID: a.ID})
+					if err != nil {
+						return err
+					}
+				}
+			}
+
+			if err = db.Destroy(dup); err != nil {
+				return err
+			}
+
+			merged = append(merged, dup.ID)
+		}
+
+		if len(merged) == 0 {
+			return nil
+		}
+
+		err := db.RawQuery("UPDATE authors SET visibility = ?, user_id = ?, opted_out_at = ?, updated_at = ? WHERE id = ?", a.Visibility, a.UserID, a.OptedOutAt, time.Now(), a.ID).Exec()
+		if err != nil {
+			return err
+		}
+
+		return queueWebhooks(db, a.ArchiveID, EventAuthorMerged, authorMergedEvent{AuthorID: a.ID, Merged: merged})
+	})
+}
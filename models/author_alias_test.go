@@ -0,0 +1,73 @@
+package models_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/navionguy/cloudquotes/models"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_AuthorAlias(t *testing.T) {
+
+	var fields = []struct {
+		fn  string
+		msg string
+	}{
+		{"id", "alias id field not found"},
+		{"name", "alias name field not found"},
+		{"author_id", "alias author_id field not found"},
+		{"created_at", "created_at field not found"},
+		{"updated_at", "updated_at field not found"},
+	}
+
+	a := models.AuthorAlias{
+		Name: "Robert McGowan",
+	}
+
+	js := a.String()
+
+	if len(js) == 0 {
+		t.Error("unable to marshal an alias")
+		t.FailNow()
+	}
+
+	rq := require.New(t)
+
+	for _, fld := range fields {
+		rq.Containsf(js, fld.fn, fld.msg)
+	}
+
+	auth := models.Author{
+		Name:    "Bob McGowan",
+		Aliases: models.AuthorAliases{a, {Name: "Bob Mcgowan"}},
+	}
+
+	rq.Equal("Robert McGowan, Bob Mcgowan", auth.AliasList())
+	rq.Contains(auth.String(), "aliases")
+}
+
+func Test_Author_MergeConsent(t *testing.T) {
+	rq := require.New(t)
+
+	user := uuid.Must(uuid.NewV4())
+	early := time.Date(2021, time.January, 1, 0, 0, 0, 0, time.UTC)
+	late := early.AddDate(0, 1, 0)
+
+	keep := models.Author{Name: "Bob McGowan", Visibility: models.VisibilityFull, OptedOutAt: &late}
+	dup := models.Author{Name: "Bob", Visibility: models.VisibilityHidden, UserID: &user, OptedOutAt: &early}
+
+	rq.NoError(keep.MergeConsent(dup))
+	rq.Equal(models.VisibilityHidden, keep.Visibility)
+	rq.Equal(user, *keep.UserID)
+	rq.True(early.Equal(*keep.OptedOutAt))
+
+	// a less restrictive duplicate doesn't loosen anything
+	rq.NoError(keep.MergeConsent(models.Author{Visibility: models.VisibilityInitials}))
+	rq.Equal(models.VisibilityHidden, keep.Visibility)
+	rq.True(keep.OptedOut())
+
+	other := uuid.Must(uuid.NewV4())
+	rq.Equal(models.ErrLinkedElsewhere, keep.MergeConsent(models.Author{Visibility: models.VisibilityFull, UserID: &other}))
+}
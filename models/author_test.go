@@ -3,6 +3,7 @@ package models_test
 import (
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/gofrs/uuid"
 	"github.com/navionguy/cloudquotes/models"
@@ -17,6 +18,8 @@ func Test_Author(t *testing.T) {
 	}{
 		{"id", "author id field not found"},
 		{"name", "author name field not found"},
+		{"bio", "author bio field not found"},
+		{"team", "author team field not found"},
 		{"created_at", "created_at field not found"},
 		{"updated_at", "updated_at field not found"},
 	}
@@ -57,48 +60,46 @@ func Test_Author(t *testing.T) {
 }
 
 const invalidUUID = "563cd207-ab16-4a46-b44e-7317b96c6ba9"
-const validUUID = "b39300f0-6760-4feb-bc32-4b8682b0175d" // matches entry in testauthors.toml
 const validName = "George P. Burdell"
 
-// Test for finding an existing author
-func (ms *ModelSuite) Test_Author_FindByID() {
-	ms.LoadFixture("test authors")
+// newAuthor adds an author to the default archive
+func newAuthor(ms *ModelSuite, name string) *models.Author {
+	archive, err := models.DefaultArchive(ms.DB)
+	ms.NoError(err)
 
-	id, err := uuid.FromString(validUUID)
+	author := &models.Author{Name: name, ArchiveID: archive.ID}
+	ms.NoError(ms.DB.Create(author))
 
-	if err != nil {
-		ms.Fail("uuid.FromString failed", err.Error())
-	}
+	return author
+}
+
+// Test for finding an existing author
+func (ms *ModelSuite) Test_Author_FindByID() {
+	saved := newAuthor(ms, validName)
 
 	auth := models.Author{
-		ID: id,
+		ID: saved.ID,
 	}
 
-	pauth, err := auth.FindByID()
-
-	if err != nil {
+	if err := auth.FindByID(); err != nil {
 		ms.Fail("FindByID failed", err.Error())
 	}
 
-	if pauth == nil {
-		ms.Fail("FindByID failed", "validUUID was not found in database")
-	}
-
-	if strings.Compare(pauth.Name, validName) != 0 {
-		ms.Fail("FindByID didn't find expected author", pauth.Name)
+	if strings.Compare(auth.Name, validName) != 0 {
+		ms.Fail("FindByID didn't find expected author", auth.Name)
 	}
 
 	// as long as I have a valid author, check some other functions
 
-	if strings.Compare(pauth.SelectLabel(), validName) != 0 {
-		ms.Fail("unexpected SelectLabel", pauth.SelectLabel())
+	if strings.Compare(auth.SelectLabel(), validName) != 0 {
+		ms.Fail("unexpected SelectLabel", auth.SelectLabel())
 	}
 
-	v := pauth.SelectValue()
+	v := auth.SelectValue()
 	s, ok := v.(string)
 
 	if ok {
-		if strings.Compare(s, validUUID) != 0 {
+		if strings.Compare(s, saved.ID.String()) != 0 {
 			ms.Fail("unexpected SelectValue", s)
 		}
 	} else {
@@ -108,7 +109,7 @@ func (ms *ModelSuite) Test_Author_FindByID() {
 
 // test that FindByID correctly handles NOT finding the author
 func (ms *ModelSuite) Test_Author_FindByID_BadID() {
-	ms.LoadFixture("test authors")
+	newAuthor(ms, validName)
 
 	id, err := uuid.FromString(invalidUUID)
 
@@ -120,22 +121,18 @@ func (ms *ModelSuite) Test_Author_FindByID_BadID() {
 		ID: id,
 	}
 
-	pauth, err := auth.FindByID()
-
-	if err != nil {
-		ms.Fail("FindByID failed", err.Error())
-	}
-
-	if pauth != nil {
-		ms.Fail("FindByID succeeded with an invalid UUID", pauth.Name)
+	if err = auth.FindByID(); err == nil {
+		ms.Fail("FindByID succeeded with an invalid UUID", auth.Name)
 	}
 }
 
 func (ms *ModelSuite) Test_Author_Create() {
-	ms.LoadFixture("test authors")
+	archive, err := models.DefaultArchive(ms.DB)
+	ms.NoError(err)
 
 	auth := models.Author{
-		Name: "Brand New Author",
+		Name:      "Brand New Author",
+		ArchiveID: archive.ID,
 	}
 
 	verrs, err := ms.DB.ValidateAndCreate(&auth)
@@ -150,9 +147,7 @@ func (ms *ModelSuite) Test_Author_Create() {
 }
 
 func (ms *ModelSuite) Test_Author_CreateInvalid() {
-	ms.LoadFixture("test authors")
-
-	auth := models.Author{}
+	auth := models.Author{Name: "Brand New Author", Visibility: "sideways"}
 
 	verrs, err := ms.DB.ValidateAndCreate(&auth)
 
@@ -161,6 +156,35 @@ func (ms *ModelSuite) Test_Author_CreateInvalid() {
 	}
 
 	if !verrs.HasAny() {
-		ms.Fail("invalid author validated", "no name supplied")
+		ms.Fail("invalid author validated", "unknown visibility")
 	}
 }
+
+// speaking a line as a co-author counts the same as being its author
+func (ms *ModelSuite) Test_Author_Profile_CoSpeakers() {
+	bob := newAuthor(ms, "Bob")
+	beth := newAuthor(ms, "Beth")
+	carl := newAuthor(ms, "Carl")
+
+	cv := &models.Conversation{ArchiveID: bob.ArchiveID, OccurredOn: time.Now()}
+	cv.Quotes = models.Quotes{
+		{Phrase: "Not it!", SaidOn: time.Now(), AuthorID: &bob.ID, CoAuthors: models.Authors{*beth}},
+		{Phrase: "Fine, I'll do it.", SaidOn: time.Now(), AuthorID: &carl.ID},
+	}
+
+	verrs, err := cv.Create(ms.DB, uuid.Nil)
+	ms.NoError(err)
+	ms.False(verrs.HasAny(), verrs.String())
+
+	p, err := beth.Profile(ms.DB, true)
+	ms.NoError(err)
+	ms.Len(p.Quotes, 1)
+	ms.Len(p.CoSpeakers, 2)
+	ms.Equal("Bob", p.CoSpeakers[0].Name)
+	ms.Equal("Carl", p.CoSpeakers[1].Name)
+
+	p, err = bob.Profile(ms.DB, true)
+	ms.NoError(err)
+	ms.Len(p.CoSpeakers, 2)
+	ms.Equal("Beth", p.CoSpeakers[0].Name)
+}
@@ -0,0 +1,314 @@
+package models
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/gobuffalo/pop/v5"
+	"github.com/gobuffalo/validate/v3"
+	"github.com/gobuffalo/validate/v3/validators"
+	"github.com/gofrs/uuid"
+)
+
+// FavoritesName is the collection a conversation gets starred into when
+// the user doesn't pick one
+const FavoritesName = "Favorites"
+
+// Collection is a user's hand picked set of conversations, kept in the
+// order they were picked.  A public collection can be passed around by
+// its share URL, even to people who aren't signed in or belong to some
+// other archive.
+type Collection struct {
+	ID        uuid.UUID `json:"id" db:"id"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+	ArchiveID uuid.UUID `json:"archive_id" db:"archive_id" form:"-"`
+	UserID    uuid.UUID `json:"user_id" db:"user_id" form:"-"`
+	Name      string    `json:"name" db:"name" form:"name"`
+	Public    bool      `json:"public" db:"public" form:"public"`
+	Token     string    `json:"token" db:"token" form:"-"`
+}
+
+// String is not required by pop and may be deleted
+func (c Collection) String() string {
+	jc, _ := json.Marshal(c)
+	return string(jc)
+}
+
+// Collections is not required by pop and may be deleted
+type Collections []Collection
+
+// String is not required by pop and may be deleted
+func (c Collections) String() string {
+	jc, _ := json.Marshal(c)
+	return string(jc)
+}
+
+// Validate gets run every time you call a "pop.Validate*" (pop.ValidateAndSave, pop.ValidateAndCreate, pop.ValidateAndUpdate) method.
+// This method is not required and may be deleted.
+func (c *Collection) Validate(tx *pop.Connection) (*validate.Errors, error) {
+	return validate.Validate(
+		&validators.StringIsPresent{Field: c.Name, Name: "Name"},
+		&validators.StringLengthInRange{Field: c.Name, Name: "Name", Max: 255, Message: "length must be <255"},
+		&validators.StringIsPresent{Field: c.Token, Name: "Token"},
+		&validators.UUIDIsPresent{Field: c.ArchiveID, Name: "ArchiveID"},
+		&validators.UUIDIsPresent{Field: c.UserID, Name: "UserID"},
+	), nil
+}
+
+// ValidateCreate gets run every time you call "pop.ValidateAndCreate" method.
+// This method is not required and may be deleted.
+func (c *Collection) ValidateCreate(tx *pop.Connection) (*validate.Errors, error) {
+	return validate.NewErrors(), nil
+}
+
+// ValidateUpdate gets run every time you call "pop.ValidateAndUpdate" method.
+// This method is not required and may be deleted.
+func (c *Collection) ValidateUpdate(tx *pop.Connection) (*validate.Errors, error) {
+	return validate.NewErrors(), nil
+}
+
+// CollectionEntry puts one conversation in a collection.  Position says
+// where it goes, lowest first.
+type CollectionEntry struct {
+	ID             uuid.UUID `json:"id" db:"id"`
+	CreatedAt      time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at" db:"updated_at"`
+	CollectionID   uuid.UUID `json:"collection_id" db:"collection_id"`
+	ConversationID uuid.UUID `json:"conversation_id" db:"conversation_id"`
+	Position       int       `json:"position" db:"position"`
+}
+
+// String is not required by pop and may be deleted
+func (e CollectionEntry) String() string {
+	je, _ := json.Marshal(e)
+	return string(je)
+}
+
+// CollectionEntries is not required by pop and may be deleted
+type CollectionEntries []CollectionEntry
+
+// String is not required by pop and may be deleted
+func (e CollectionEntries) String() string {
+	je, _ := json.Marshal(e)
+	return string(je)
+}
+
+// newShareToken makes the hard to guess part of a share URL
+func newShareToken() (string, error) {
+	b := make([]byte, 16)
+
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+// Create saves a new collection along with the token for its share URL
+func (c *Collection) Create(tx *pop.Connection) (*validate.Errors, error) {
+	token, err := newShareToken()
+
+	if err != nil {
+		return nil, err
+	}
+
+	c.Token = token
+
+	return tx.ValidateAndCreate(c)
+}
+
+// UserCollections lists the collections the user has in the archive, by
+// name
+func UserCollections(tx *pop.Connection, archive uuid.UUID, user uuid.UUID) (Collections, error) {
+	collections := Collections{}
+
+	if user == uuid.Nil {
+		return collections, nil
+	}
+
+	err := tx.Where("archive_id = ? AND user_id = ?", archive, user).Order("name").All(&collections)
+
+	return collections, err
+}
+
+// Favorites finds the user's favorites collection in the archive,
+// starting one if this is the first thing they have starred.
+func Favorites(tx *pop.Connection, archive uuid.UUID, user uuid.UUID) (*Collection, error) {
+	collections := Collections{}
+
+	if err := tx.Where("archive_id = ? AND user_id = ? AND name = ?", archive, user, FavoritesName).All(&collections); err != nil {
+		return nil, err
+	}
+
+	if len(collections) > 0 {
+		return &collections[0], nil
+	}
+
+	c := &Collection{ArchiveID: archive, UserID: user, Name: FavoritesName}
+
+	if _, err := c.Create(tx); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// FindSharedCollection looks up a collection by the token in its share
+// URL
+func FindSharedCollection(tx *pop.Connection, token string) (*Collection, error) {
+	c := &Collection{}
+
+	return c, tx.Where("token = ?", token).First(c)
+}
+
+// VisibleTo reports if the user may look at the collection.  Private
+// collections are only for whoever made them.
+func (c Collection) VisibleTo(user uuid.UUID) bool {
+	return c.Public || (user != uuid.Nil && c.UserID == user)
+}
+
+// Add puts the conversation on the end of the collection.  Adding it
+// twice doesn't move it.
+func (c Collection) Add(tx *pop.Connection, conversationID uuid.UUID) error {
+	in, err := tx.Where("collection_id = ? AND conversation_id = ?", c.ID, conversationID).Exists(&CollectionEntry{})
+
+	if err != nil || in {
+		return err
+	}
+
+	last := struct {
+		Position int `db:"position"`
+	}{}
+
+	err = tx.RawQuery("SELECT COALESCE(MAX(position), 0) AS position FROM collection_entries WHERE collection_id = ?", c.ID).First(&last)
+
+	if err != nil {
+		return err
+	}
+
+	return tx.Create(&CollectionEntry{CollectionID: c.ID, ConversationID: conversationID, Position: last.Position + 1})
+}
+
+// Remove takes the conversation out of the collection
+func (c Collection) Remove(tx *pop.Connection, conversationID uuid.UUID) error {
+	return tx.RawQuery("DELETE FROM collection_entries WHERE collection_id = ? AND conversation_id = ?", c.ID, conversationID).Exec()
+}
+
+// Move shifts the conversation up (negative) or down (positive) the
+// collection by swapping it with its neighbour.  Moving off either end
+// does nothing.
+func (c Collection) Move(tx *pop.Connection, conversationID uuid.UUID, by int) error {
+	entries := CollectionEntries{}
+
+	if err := tx.Where("collection_id = ?", c.ID).Order("position").All(&entries); err != nil {
+		return err
+	}
+
+	for i := range entries {
+		if entries[i].ConversationID != conversationID {
+			continue
+		}
+
+		j := i + by
+
+		if j < 0 || j >= len(entries) {
+			return nil
+		}
+
+		entries[i].Position, entries[j].Position = entries[j].Position, entries[i].Position
+
+		if err := tx.Update(&entries[i]); err != nil {
+			return err
+		}
+
+		return tx.Update(&entries[j])
+	}
+
+	return nil
+}
+
+// Conversations loads what is in the collection, in order.  Anything
+// in the trash is left out.  With published set, so is anything that
+// hasn't made it through review, along with any lines that weren't
+// cleared for publishing.
+func (c Collection) Conversations(tx *pop.Connection, published bool) (Conversations, error) {
+	entries := CollectionEntries{}
+
+	if err := tx.Where("collection_id = ?", c.ID).Order("position").All(&entries); err != nil {
+		return nil, err
+	}
+
+	cs := Conversations{}
+
+	if len(entries) == 0 {
+		return cs, nil
+	}
+
+	ids := []interface{}{}
+	for _, e := range entries {
+		ids = append(ids, e.ConversationID)
+	}
+
+	q := tx.Eager("Quotes.Conversation").Eager("Quotes").Eager("Quotes.Author").Eager("Quotes.CoAuthors").Eager("Quotes.Annotation").Eager("Tags").Where("id IN (?)", ids...).Where("deleted_at IS NULL")
+
+	if published {
+		q = q.Where("status = ?", StatusPublished)
+	}
+
+	if err := q.All(&cs); err != nil {
+		return nil, err
+	}
+
+	byID := map[uuid.UUID]Conversation{}
+	for _, cv := range cs {
+		if published {
+			cv.Quotes = cv.PublishedQuotes()
+		} else {
+			cv.Quotes = cv.LiveQuotes()
+		}
+		byID[cv.ID] = cv
+	}
+
+	ordered := Conversations{}
+	for _, e := range entries {
+		if cv, ok := byID[e.ConversationID]; ok {
+			ordered = append(ordered, cv)
+		}
+	}
+
+	return ordered, nil
+}
+
+// QuoteOfTheDay works out which of the collection's published
+// conversations is up today.  The collection is played in order, one
+// conversation a day, starting over once it reaches the end.  Like the
+// archive's quote of the day, the database decides what day it is, and
+// conversations with nothing left to show once hidden authors are
+// taken out are skipped.
+func (c Collection) QuoteOfTheDay(tx *pop.Connection) (uuid.UUID, error) {
+	where := `FROM collection_entries
+		JOIN conversations ON conversations.id = collection_entries.conversation_id
+		WHERE collection_entries.collection_id = ? AND conversations.status = ? AND conversations.deleted_at IS NULL AND ` + hasPublicLine("conversations")
+
+	count := struct {
+		N int `db:"n"`
+	}{}
+
+	if err := tx.RawQuery("SELECT COUNT(*) AS n "+where, c.ID, StatusPublished).First(&count); err != nil {
+		return uuid.Nil, err
+	}
+
+	if count.N == 0 {
+		return uuid.Nil, ErrEmptyDeck
+	}
+
+	card := deckCard{}
+
+	err := tx.RawQuery("SELECT collection_entries.conversation_id "+where+
+		" ORDER BY collection_entries.position OFFSET (CURRENT_DATE - DATE '2000-01-01') % ? LIMIT 1", c.ID, StatusPublished, count.N).First(&card)
+
+	return card.ConversationID, err
+}
@@ -0,0 +1,79 @@
+package models_test
+
+import (
+	"testing"
+
+	"github.com/gofrs/uuid"
+	"github.com/navionguy/cloudquotes/models"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Collection(t *testing.T) {
+
+	var fields = []struct {
+		fn  string
+		msg string
+	}{
+		{"id", "collection id field not found"},
+		{"archive_id", "archive_id field not found"},
+		{"user_id", "user_id field not found"},
+		{"name", "name field not found"},
+		{"public", "public field not found"},
+		{"token", "token field not found"},
+		{"created_at", "created_at field not found"},
+		{"updated_at", "updated_at field not found"},
+	}
+
+	c := models.Collection{}
+
+	js := c.String()
+
+	rq := require.New(t)
+
+	for _, fld := range fields {
+		rq.Containsf(js, fld.fn, fld.msg)
+	}
+}
+
+func Test_CollectionEntry(t *testing.T) {
+
+	var fields = []struct {
+		fn  string
+		msg string
+	}{
+		{"id", "entry id field not found"},
+		{"collection_id", "collection_id field not found"},
+		{"conversation_id", "conversation_id field not found"},
+		{"position", "position field not found"},
+		{"created_at", "created_at field not found"},
+		{"updated_at", "updated_at field not found"},
+	}
+
+	e := models.CollectionEntry{}
+
+	js := e.String()
+
+	rq := require.New(t)
+
+	for _, fld := range fields {
+		rq.Containsf(js, fld.fn, fld.msg)
+	}
+}
+
+func Test_Collection_VisibleTo(t *testing.T) {
+	rq := require.New(t)
+
+	owner := uuid.Must(uuid.NewV4())
+	other := uuid.Must(uuid.NewV4())
+
+	c := models.Collection{UserID: owner}
+
+	rq.True(c.VisibleTo(owner))
+	rq.False(c.VisibleTo(other))
+	rq.False(c.VisibleTo(uuid.Nil))
+
+	c.Public = true
+
+	rq.True(c.VisibleTo(other))
+	rq.True(c.VisibleTo(uuid.Nil))
+}
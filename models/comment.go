@@ -0,0 +1,170 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/gobuffalo/pop/v5"
+	"github.com/gobuffalo/validate/v3"
+	"github.com/gobuffalo/validate/v3/validators"
+	"github.com/gofrs/uuid"
+)
+
+// PermModerator is the permission a user needs to remove other people's
+// comments
+const PermModerator = "moderator"
+
+// Comment is one post in the discussion thread under a conversation.
+// It's where the back-story goes, the stuff that won't fit in an
+// annotation.
+type Comment struct {
+	ID             uuid.UUID  `json:"id" db:"id"`
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at" db:"updated_at"`
+	ConversationID uuid.UUID  `json:"conversation_id" db:"conversation_id"`
+	UserID         *uuid.UUID `json:"user_id" db:"user_id"`
+	Body           string     `json:"body" db:"body" form:"body"`
+	RemovedAt      *time.Time `json:"removed_at" db:"removed_at"`
+
+	// Commenter is the display name of whoever wrote it, filled in when
+	// the thread is loaded
+	Commenter string `json:"commenter" db:"-"`
+}
+
+// String is not required by pop and may be deleted
+func (c Comment) String() string {
+	jc, _ := json.Marshal(c)
+	return string(jc)
+}
+
+// Comments is not required by pop and may be deleted
+type Comments []Comment
+
+// String is not required by pop and may be deleted
+func (c Comments) String() string {
+	jc, _ := json.Marshal(c)
+	return string(jc)
+}
+
+// Validate gets run every time you call a "pop.Validate*" (pop.ValidateAndSave, pop.ValidateAndCreate, pop.ValidateAndUpdate) method.
+// This method is not required and may be deleted.
+func (c *Comment) Validate(tx *pop.Connection) (*validate.Errors, error) {
+	return validate.Validate(
+		&validators.StringIsPresent{Field: c.Body, Name: "Body"},
+		&validators.StringLengthInRange{Field: c.Body, Name: "Body", Min: 1, Max: 4000, Message: "length must be <4000"},
+	), nil
+}
+
+// ValidateCreate gets run every time you call "pop.ValidateAndCreate" method.
+// This method is not required and may be deleted.
+func (c *Comment) ValidateCreate(tx *pop.Connection) (*validate.Errors, error) {
+	return validate.NewErrors(), nil
+}
+
+// ValidateUpdate gets run every time you call "pop.ValidateAndUpdate" method.
+// This method is not required and may be deleted.
+func (c *Comment) ValidateUpdate(tx *pop.Connection) (*validate.Errors, error) {
+	return validate.NewErrors(), nil
+}
+
+// OwnedBy reports if the user wrote the comment
+func (c Comment) OwnedBy(user uuid.UUID) bool {
+	return user != uuid.Nil && c.UserID != nil && *c.UserID == user
+}
+
+// Edited reports if the comment was changed after it was posted
+func (c Comment) Edited() bool {
+	return c.UpdatedAt.Sub(c.CreatedAt) > time.Second
+}
+
+// Remove takes the comment out of the thread.  The row stays behind so
+// the thread still shows something was there, but nobody sees what it
+// said.
+func (c *Comment) Remove(tx *pop.Connection) error {
+	now := time.Now()
+	c.RemovedAt = &now
+
+	return tx.RawQuery("UPDATE comments SET removed_at = ? WHERE id = ?", now, c.ID).Exec()
+}
+
+// ConversationComments loads the thread under the conversation, oldest
+// first.  Removed comments are left in place with their body blanked.
+func ConversationComments(tx *pop.Connection, id uuid.UUID) (Comments, error) {
+	comments := Comments{}
+
+	if err := tx.Where("conversation_id = ?", id).Order("created_at").All(&comments); err != nil {
+		return nil, err
+	}
+
+	ids := []interface{}{}
+
+	for _, c := range comments {
+		if c.UserID != nil {
+			ids = append(ids, *c.UserID)
+		}
+	}
+
+	names, err := commenterNames(tx, ids)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range comments {
+		comments[i].hide()
+
+		if comments[i].UserID != nil {
+			comments[i].Commenter = names[*comments[i].UserID]
+		}
+	}
+
+	return comments, nil
+}
+
+// FindComment loads one comment from the conversation's thread the same
+// way ConversationComments would show it.
+func FindComment(tx *pop.Connection, conversationID uuid.UUID, id string) (*Comment, error) {
+	comment := &Comment{}
+
+	if err := tx.Where("conversation_id = ?", conversationID).Find(comment, id); err != nil {
+		return nil, err
+	}
+
+	if comment.UserID != nil {
+		names, err := commenterNames(tx, []interface{}{*comment.UserID})
+
+		if err != nil {
+			return nil, err
+		}
+
+		comment.Commenter = names[*comment.UserID]
+	}
+
+	comment.hide()
+
+	return comment, nil
+}
+
+// commenterNames finds the display names of the users, keyed by ID
+func commenterNames(tx *pop.Connection, ids []interface{}) (map[uuid.UUID]string, error) {
+	emails, err := userEmails(tx, ids)
+
+	if err != nil {
+		return nil, err
+	}
+
+	names := map[uuid.UUID]string{}
+
+	for id, email := range emails {
+		names[id] = User{Email: email}.DisplayName()
+	}
+
+	return names, nil
+}
+
+// hide blanks out the body of a removed comment so it never gets shown
+func (c *Comment) hide() {
+	if c.RemovedAt != nil {
+		c.Body = ""
+	}
+}
@@ -0,0 +1,59 @@
+package models_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/navionguy/cloudquotes/models"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Comment(t *testing.T) {
+
+	var fields = []struct {
+		fn  string
+		msg string
+	}{
+		{"id", "comment id field not found"},
+		{"conversation_id", "conversation_id field not found"},
+		{"user_id", "user_id field not found"},
+		{"body", "body field not found"},
+		{"removed_at", "removed_at field not found"},
+		{"commenter", "commenter field not found"},
+		{"created_at", "created_at field not found"},
+		{"updated_at", "updated_at field not found"},
+	}
+
+	c := models.Comment{}
+
+	js := c.String()
+
+	rq := require.New(t)
+
+	for _, fld := range fields {
+		rq.Containsf(js, fld.fn, fld.msg)
+	}
+}
+
+func Test_Comment_OwnedBy(t *testing.T) {
+	rq := require.New(t)
+
+	owner, _ := uuid.NewV4()
+	other, _ := uuid.NewV4()
+
+	c := models.Comment{UserID: &owner}
+
+	rq.True(c.OwnedBy(owner))
+	rq.False(c.OwnedBy(other))
+	rq.False(c.OwnedBy(uuid.Nil))
+	rq.False(models.Comment{}.OwnedBy(owner))
+
+	now := time.Now()
+	c.CreatedAt = now
+	c.UpdatedAt = now
+	rq.False(c.Edited())
+
+	c.UpdatedAt = now.Add(time.Minute)
+	rq.True(c.Edited())
+}
@@ -0,0 +1,270 @@
+package models
+
+import (
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gobuffalo/pop/v5"
+	"github.com/gobuffalo/validate/v3"
+	"github.com/gofrs/uuid"
+)
+
+// VisibilityFull shows the author's name as it is
+const VisibilityFull = "full"
+
+// VisibilityInitials shows just the author's initials
+const VisibilityInitials = "initials"
+
+// VisibilityAnonymous credits the author's lines to AnonymousName
+const VisibilityAnonymous = "anonymous"
+
+// VisibilityHidden keeps the author's lines off everything the public
+// gets to see
+const VisibilityHidden = "hidden"
+
+// Visibilities are the ways an author can be shown, from the most to
+// the least revealing
+var Visibilities = []string{VisibilityFull, VisibilityInitials, VisibilityAnonymous, VisibilityHidden}
+
+// AnonymousName stands in for an author who doesn't want to be named
+var AnonymousName = "a colleague"
+
+// Initials cuts a name down to the first letter of each part of it, so
+// "Bob McGowan" comes out as "B.M."
+func Initials(name string) string {
+	initials := ""
+
+	for _, part := range strings.Fields(name) {
+		for _, r := range part {
+			if unicode.IsLetter(r) {
+				initials += string(unicode.ToUpper(r)) + "."
+				break
+			}
+		}
+	}
+
+	return initials
+}
+
+// PublicName is what the author gets called where the public can see
+// it.  Hidden authors have nothing public to be called.
+func (a Author) PublicName() string {
+	switch a.Visibility {
+	case VisibilityInitials:
+		return Initials(a.Name)
+	case VisibilityAnonymous:
+		return AnonymousName
+	case VisibilityHidden:
+		return ""
+	}
+
+	return a.Name
+}
+
+// Hidden reports if the author's lines have to be left off public pages
+// and feeds
+func (a Author) Hidden() bool {
+	return a.Visibility == VisibilityHidden
+}
+
+// OptedOut reports if the person the author is has asked for nothing
+// more of theirs to be published
+func (a Author) OptedOut() bool {
+	return a.OptedOutAt != nil
+}
+
+// LinkedTo reports if the user is the person the author is
+func (a Author) LinkedTo(user uuid.UUID) bool {
+	return user != uuid.Nil && a.UserID != nil && *a.UserID == user
+}
+
+// masked is the stand in for the author on public pages.  Anybody not
+// shown in full loses everything that could lead back to them, their
+// ID included.
+func (a Author) masked() Author {
+	if a.Visibility == VisibilityFull || len(a.Visibility) == 0 {
+		return a
+	}
+
+	return Author{Name: a.PublicName(), Visibility: a.Visibility, Masked: true}
+}
+
+// MaskAuthors returns the line the way the public gets to see it, and
+// false if it has to be left out.  Hidden authors are taken off it, the
+// line only goes if nobody else said it, and everybody else is named
+// the way they agreed to be.
+func (q Quote) MaskAuthors() (Quote, bool) {
+	if q.Narration() {
+		return q, true
+	}
+
+	coAuthors := Authors{}
+	for _, a := range q.CoAuthors {
+		if !a.Hidden() {
+			coAuthors = append(coAuthors, a.masked())
+		}
+	}
+
+	// a hidden author hands the line to whoever said it with them
+	if q.Author.Hidden() {
+		if len(coAuthors) == 0 {
+			return q, false
+		}
+
+		q.Author = coAuthors[0]
+		id := coAuthors[0].ID
+		q.AuthorID = &id
+		coAuthors = coAuthors[1:]
+	}
+
+	if m := q.Author.masked(); m.Masked {
+		q.Author = m
+		id := uuid.Nil
+		q.AuthorID = &id
+	}
+
+	q.CoAuthors = coAuthors
+
+	return q, true
+}
+
+// MaskAuthors returns the conversation the way the public gets to see
+// it, each line masked as Quote.MaskAuthors does.  If that leaves
+// nothing but narrator lines, nothing is left at all.
+func (c Conversation) MaskAuthors() Conversation {
+	quotes := Quotes{}
+	spoken := false
+	dropped := false
+
+	for _, q := range c.Quotes {
+		m, ok := q.MaskAuthors()
+
+		if !ok {
+			dropped = true
+			continue
+		}
+
+		spoken = spoken || !m.Narration()
+		quotes = append(quotes, m)
+	}
+
+	if dropped && !spoken {
+		quotes = Quotes{}
+	}
+
+	c.Quotes = quotes
+
+	return c
+}
+
+// MaskAuthors masks every one of the conversations, leaving out the
+// ones with nothing left to show
+func (cs Conversations) MaskAuthors() Conversations {
+	masked := Conversations{}
+
+	for _, c := range cs {
+		if m := c.MaskAuthors(); len(m.Quotes) > 0 {
+			masked = append(masked, m)
+		}
+	}
+
+	return masked
+}
+
+// Public leaves out the credits for anybody not shown in full, for
+// lists of authors anyone can see
+func (ac AuthorCredits) Public() AuthorCredits {
+	public := AuthorCredits{}
+
+	for _, a := range ac {
+		if a.Visibility == VisibilityFull || len(a.Visibility) == 0 {
+			public = append(public, a)
+		}
+	}
+
+	return public
+}
+
+// LinkUser makes the user with the passed email the person the author
+// is.  A blank email unlinks whoever it was.
+func (a *Author) LinkUser(tx *pop.Connection, email string) (*validate.Errors, error) {
+	verrs := validate.NewErrors()
+	email = strings.ToLower(strings.TrimSpace(email))
+
+	if len(email) == 0 {
+		a.UserID = nil
+		return verrs, nil
+	}
+
+	users := Users{}
+
+	if err := tx.Where("email = ?", email).All(&users); err != nil {
+		return nil, err
+	}
+
+	if len(users) == 0 {
+		verrs.Add("user_email", "there is no account for "+email)
+		return verrs, nil
+	}
+
+	a.UserID = &users[0].ID
+
+	return verrs, nil
+}
+
+// OptOut records that the author wants nothing more of theirs
+// published.  Lines already out there stay out there, anything new, or
+// said after today, is held back.
+func (a *Author) OptOut(tx *pop.Connection) error {
+	now := time.Now()
+	a.OptedOutAt = &now
+
+	return tx.Update(a)
+}
+
+// OptIn lets the author's lines be published again
+func (a *Author) OptIn(tx *pop.Connection) error {
+	a.OptedOutAt = nil
+
+	return tx.Update(a)
+}
+
+// withholdOptedOut stops the quote being published if anybody who said
+// it has opted out.  New lines are always held back, lines already saved
+// only if they were said after they opted out.
+func (q *Quote) withholdOptedOut(db *pop.Connection, isNew bool) error {
+	ids := []interface{}{}
+	for _, id := range q.speakerIDs() {
+		ids = append(ids, id)
+	}
+
+	if !q.Publish || len(ids) == 0 {
+		return nil
+	}
+
+	authors := Authors{}
+
+	if err := db.Where("id IN (?)", ids...).Where("opted_out_at IS NOT NULL").All(&authors); err != nil {
+		return err
+	}
+
+	for _, a := range authors {
+		if isNew || !q.SaidOn.Before(*a.OptedOutAt) {
+			q.Publish = false
+		}
+	}
+
+	return nil
+}
+
+// hasPublicLine is a SQL condition that holds when the conversation,
+// named by the passed table or alias, has a spoken line cleared for
+// publishing by somebody who isn't hidden, whether they said it alone
+// or along with others.  The quote of the day skips the rest, there
+// would be nothing of them to put on the wall.
+func hasPublicLine(conversation string) string {
+	return `EXISTS (SELECT 1 FROM quotes pq JOIN authors pa ON pa.id = pq.author_id
+		WHERE pq.conversation_id = ` + conversation + `.id AND pq.publish AND pq.deleted_at IS NULL AND (pa.visibility != '` + VisibilityHidden + `'
+		OR EXISTS (SELECT 1 FROM quote_authors pqa JOIN authors pca ON pca.id = pqa.author_id WHERE pqa.quote_id = pq.id AND pca.visibility != '` + VisibilityHidden + `')))`
+}
@@ -0,0 +1,129 @@
+package models_test
+
+import (
+	"testing"
+
+	"github.com/gofrs/uuid"
+	"github.com/navionguy/cloudquotes/models"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Initials(t *testing.T) {
+	rq := require.New(t)
+
+	rq.Equal("B.M.", models.Initials("Bob McGowan"))
+	rq.Equal("J.R.R.T.", models.Initials("  john ronald reuel tolkien "))
+	rq.Equal("O.B.", models.Initials("O'Brien, 'Bud'"))
+	rq.Equal("", models.Initials(""))
+}
+
+func Test_Author_PublicName(t *testing.T) {
+	rq := require.New(t)
+
+	a := models.Author{Name: "Bob McGowan"}
+	rq.Equal("Bob McGowan", a.PublicName())
+
+	a.Visibility = models.VisibilityInitials
+	rq.Equal("B.M.", a.PublicName())
+
+	a.Visibility = models.VisibilityAnonymous
+	rq.Equal(models.AnonymousName, a.PublicName())
+
+	a.Visibility = models.VisibilityHidden
+	rq.Equal("", a.PublicName())
+}
+
+func Test_Conversation_MaskAuthors(t *testing.T) {
+	rq := require.New(t)
+
+	full := models.Author{ID: uuid.Must(uuid.NewV4()), Name: "Bob McGowan", Visibility: models.VisibilityFull}
+	initials := models.Author{ID: uuid.Must(uuid.NewV4()), Name: "Jane Smith", Bio: "Left in 2019", Visibility: models.VisibilityInitials}
+	hidden := models.Author{ID: uuid.Must(uuid.NewV4()), Name: "Sam Jones", Visibility: models.VisibilityHidden}
+
+	c := models.Conversation{Quotes: models.Quotes{
+		{Phrase: "one", Author: full, AuthorID: &full.ID},
+		{Phrase: "two", Author: initials, AuthorID: &initials.ID},
+		{Phrase: "three", Author: hidden, AuthorID: &hidden.ID},
+	}}
+
+	m := c.MaskAuthors()
+	rq.Len(m.Quotes, 2)
+
+	rq.Equal("Bob McGowan", m.Quotes[0].Author.Name)
+	rq.Equal(full.ID, *m.Quotes[0].AuthorID)
+	rq.False(m.Quotes[0].Author.Masked)
+
+	// nothing is left that leads back to who it was
+	rq.Equal("J.S.", m.Quotes[1].Author.Name)
+	rq.Equal(uuid.Nil, *m.Quotes[1].AuthorID)
+	rq.Equal(uuid.Nil, m.Quotes[1].Author.ID)
+	rq.Equal("", m.Quotes[1].Author.Bio)
+	rq.True(m.Quotes[1].Author.Masked)
+
+	// the original is left alone for the editors
+	rq.Len(c.Quotes, 3)
+	rq.Equal("Jane Smith", c.Quotes[1].Author.Name)
+
+	cs := models.Conversations{c, {Quotes: models.Quotes{{Phrase: "four", Author: hidden, AuthorID: &hidden.ID}}}}
+	rq.Len(cs.MaskAuthors(), 1)
+}
+
+func Test_Conversation_MaskAuthors_CoAuthors(t *testing.T) {
+	rq := require.New(t)
+
+	full := models.Author{ID: uuid.Must(uuid.NewV4()), Name: "Bob McGowan", Visibility: models.VisibilityFull}
+	anonymous := models.Author{ID: uuid.Must(uuid.NewV4()), Name: "Jane Smith", Visibility: models.VisibilityAnonymous}
+	hidden := models.Author{ID: uuid.Must(uuid.NewV4()), Name: "Sam Jones", Visibility: models.VisibilityHidden}
+
+	c := models.Conversation{Quotes: models.Quotes{
+		{Phrase: "(phone rings)"},
+		{Phrase: "Not it!", Author: hidden, AuthorID: &hidden.ID, CoAuthors: models.Authors{full, anonymous}},
+		{Phrase: "Me neither", Author: full, AuthorID: &full.ID, CoAuthors: models.Authors{hidden}},
+	}}
+
+	m := c.MaskAuthors()
+	rq.Len(m.Quotes, 3)
+	rq.True(m.Quotes[0].Narration())
+
+	// the hidden author hands the line over to whoever said it with them
+	rq.Equal("Bob McGowan & "+models.AnonymousName, m.Quotes[1].Credit())
+	rq.Equal(uuid.Nil, m.Quotes[1].CoAuthors[0].ID)
+
+	rq.Equal("Bob McGowan", m.Quotes[2].Credit())
+
+	// narration is all that would be left, so nothing is
+	c = models.Conversation{Quotes: models.Quotes{
+		{Phrase: "*long pause*"},
+		{Phrase: "Sorry", Author: hidden, AuthorID: &hidden.ID},
+	}}
+
+	rq.Len(c.MaskAuthors().Quotes, 0)
+}
+
+func Test_AuthorCredits_Public(t *testing.T) {
+	rq := require.New(t)
+
+	ac := models.AuthorCredits{
+		{Name: "Bob McGowan", Visibility: models.VisibilityFull},
+		{Name: "Jane Smith", Visibility: models.VisibilityInitials},
+		{Name: "Sam Jones", Visibility: models.VisibilityHidden},
+	}
+
+	public := ac.Public()
+	rq.Len(public, 1)
+	rq.Equal("Bob McGowan", public[0].Name)
+}
+
+func Test_Author_LinkedTo(t *testing.T) {
+	rq := require.New(t)
+
+	user := uuid.Must(uuid.NewV4())
+	a := models.Author{}
+
+	rq.False(a.LinkedTo(user))
+	rq.False(a.LinkedTo(uuid.Nil))
+
+	a.UserID = &user
+	rq.True(a.LinkedTo(user))
+	rq.False(a.LinkedTo(uuid.Must(uuid.NewV4())))
+}
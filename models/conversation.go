@@ -16,14 +16,28 @@ import (
 
 // Conversation Common element of one or more quotes
 type Conversation struct {
-	ID         uuid.UUID `json:"id" db:"id"`
-	CreatedAt  time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt  time.Time `json:"updated_at" db:"updated_at"`
-	OccurredOn time.Time `json:"occurredon" db:"occurredon"`
-	Publish    bool      `json:"publish" db:"publish"`
+	ID         uuid.UUID  `json:"id" db:"id"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at" db:"updated_at"`
+	OccurredOn time.Time  `json:"occurredon" db:"occurredon"`
+	Publish    bool       `json:"publish" db:"publish"`
+	Status     string     `json:"status" db:"status"`
+	DeletedAt  *time.Time `json:"deleted_at" db:"deleted_at"`
+	ArchiveID  uuid.UUID  `json:"archive_id" db:"archive_id"`
+
+	// OccurredPrecision says how much of OccurredOn is really known
+	OccurredPrecision string `json:"occurred_precision" db:"occurred_precision"`
+
+	// Context for where and how it was said
+	Meeting  string `json:"meeting" db:"meeting"`
+	Location string `json:"location" db:"location"`
+	Channel  string `json:"channel" db:"channel"`
+	Setup    string `json:"setup" db:"setup"`
+	Source   string `json:"source" db:"source"`
 
 	// Relationships
 	Quotes Quotes `has_many:"quotes" orderby:"sequence" db:"-"`
+	Tags   Tags   `json:"tags" many_to_many:"conversations_tags" order_by:"name asc" db:"-"`
 }
 
 // String is not required by pop and may be deleted
@@ -32,6 +46,27 @@ func (c Conversation) String() string {
 	return string(jc)
 }
 
+// TagList returns the conversation's tags as a comma separated list
+func (c Conversation) TagList() string {
+	names := []string{}
+	for _, t := range c.Tags {
+		names = append(names, t.Name)
+	}
+	return strings.Join(names, ", ")
+}
+
+// Context sums up where the conversation happened, the meeting,
+// location and channel that were filled in, in that order.
+func (c Conversation) Context() string {
+	parts := []string{}
+	for _, p := range []string{c.Meeting, c.Location, c.Channel} {
+		if len(p) > 0 {
+			parts = append(parts, p)
+		}
+	}
+	return strings.Join(parts, " · ")
+}
+
 // Conversations is not required by pop and may be deleted
 type Conversations []Conversation
 
@@ -44,9 +79,23 @@ func (c Conversations) String() string {
 // Validate gets run every time you call a "pop.Validate*" (pop.ValidateAndSave, pop.ValidateAndCreate, pop.ValidateAndUpdate) method.
 // This method is not required and may be deleted.
 func (c *Conversation) Validate(tx *pop.Connection) (*validate.Errors, error) {
+	// dates that don't say otherwise are known to the day
+	if len(c.OccurredPrecision) == 0 {
+		c.OccurredPrecision = PrecisionDay
+	}
+
+	c.OccurredOn = TruncateDate(c.OccurredOn, c.OccurredPrecision)
+
 	return validate.Validate(
 		&validators.TimeIsPresent{Field: c.OccurredOn, Name: "SaidOn"},
+		&validators.StringInclusion{Field: c.OccurredPrecision, Name: "OccurredPrecision", List: Precisions},
+		&validators.UUIDIsPresent{Field: c.ArchiveID, Name: "ArchiveID"},
 		&validators.TimeIsBeforeTime{FirstTime: c.OccurredOn, SecondTime: time.Now().AddDate(0, 0, 1), FirstName: "Said on", SecondName: "Tomorrow"},
+		&validators.StringInclusion{Field: c.Status, Name: "Status", List: Statuses},
+		&validators.StringLengthInRange{Field: c.Meeting, Name: "Meeting", Max: 255, Message: "length must be <255"},
+		&validators.StringLengthInRange{Field: c.Location, Name: "Location", Max: 255, Message: "length must be <255"},
+		&validators.StringLengthInRange{Field: c.Channel, Name: "Channel", Max: 255, Message: "length must be <255"},
+		&validators.StringLengthInRange{Field: c.Source, Name: "Source", Max: 255, Message: "length must be <255"},
 	), nil
 }
 
@@ -64,12 +113,50 @@ func (c *Conversation) ValidateUpdate(tx *pop.Connection) (*validate.Errors, err
 
 const tempError string = "NoErr"
 
-// Create creates a new conversation.
-func (c *Conversation) Create() (*validate.Errors, error) {
+// checkAuthors makes sure every quote is credited to somebody in the
+// conversation's own archive, co-authors included.  Narrator lines
+// aren't credited to anybody.  If not, the problem goes into verrs and
+// the transaction gets rolled back.
+func (c *Conversation) checkAuthors(tx *pop.Connection, verrs *validate.Errors) error {
+	ids := []interface{}{}
+
+	for _, q := range c.Quotes {
+		for _, id := range q.speakerIDs() {
+			ids = append(ids, id)
+		}
+	}
+
+	if len(ids) == 0 {
+		return nil
+	}
+
+	foreign, err := tx.Where("id IN (?)", ids...).Where("archive_id != ?", c.ArchiveID).Exists(&Author{})
+
+	if err != nil {
+		return err
+	}
+
+	if foreign {
+		verrs.Add("quotes", "a quote can only be credited to a speaker in the same archive")
+		return errors.New(tempError) // force rollback of the transaction
+	}
+
+	return nil
+}
+
+// Create creates a new conversation.  editor is the user doing it, and
+// gets credited in the revision history.  Every new conversation starts
+// out as a draft and has to go through review before it is published.
+// It is all saved within tx, so it stands or falls with the rest of the
+// request.
+func (c *Conversation) Create(tx *pop.Connection, editor uuid.UUID) (*validate.Errors, error) {
 	var verrs *validate.Errors
 
+	c.Status = StatusDraft
+	c.Publish = false
+
 	// start a transaction for the whole conversation
-	err := DB.Transaction(func(db *pop.Connection) error {
+	err := inTransaction(tx, func(db *pop.Connection) error {
 		var err error
 
 		// create the conversation record
@@ -83,6 +170,10 @@ func (c *Conversation) Create() (*validate.Errors, error) {
 			return errors.New(tempError) // force rollback of the transaction
 		}
 
+		if err = c.checkAuthors(db, verrs); err != nil {
+			return err
+		}
+
 		// loop through all the quotes and add them
 		for i, quote := range c.Quotes {
 			quote.Sequence = i
@@ -95,9 +186,26 @@ func (c *Conversation) Create() (*validate.Errors, error) {
 			if verrs.HasAny() {
 				return errors.New(tempError) // this is just to get pop to rollback the transaction
 			}
+
+			if err = recordQuote(db, &quote, RevisionCreate, editor); err != nil {
+				return err
+			}
 		}
 
-		return nil
+		verrs, err = SetTags(db, c)
+		if err != nil {
+			return err
+		}
+
+		if verrs.HasAny() {
+			return errors.New(tempError) // this is just to get pop to rollback the transaction
+		}
+
+		if err = queueConversationEvent(db, c, EventConversationCreated); err != nil {
+			return err
+		}
+
+		return recordConversation(db, c, RevisionCreate, editor)
 	})
 
 	if err != nil {
@@ -114,12 +222,17 @@ func (c *Conversation) Create() (*validate.Errors, error) {
 	return verrs, nil
 }
 
-// Update re-saves an already created conversation
-func (c *Conversation) Update() (*validate.Errors, error) {
+// Update re-saves an already created conversation.  The quotes passed
+// in are the whole conversation as it should be after the edit.  Quotes
+// that already belong to the conversation are updated in place, the new
+// ones get added, and any that were left out get deleted.  Sequence is
+// renumbered to match the order the quotes were passed in.  Every change
+// is saved in the revision history, credited to editor, within tx.
+func (c *Conversation) Update(tx *pop.Connection, editor uuid.UUID) (*validate.Errors, error) {
 	var verrs *validate.Errors
 
 	// start a transaction for the whole conversation
-	err := DB.Transaction(func(db *pop.Connection) error {
+	err := inTransaction(tx, func(db *pop.Connection) error {
 		var err error
 
 		// update the conversation record
@@ -133,15 +246,45 @@ func (c *Conversation) Update() (*validate.Errors, error) {
 			return errors.New(tempError) // force rollback of the transaction
 		}
 
-		// loop through all the quotes and add them
-		for i, quote := range c.Quotes {
+		if len(c.Quotes) == 0 {
+			verrs.Add("quotes", "a conversation needs at least one quote")
+			return errors.New(tempError) // force rollback of the transaction
+		}
+
+		if err = c.checkAuthors(db, verrs); err != nil {
+			return err
+		}
+
+		// find out which quotes are already part of the conversation
+		current := Quotes{}
+		if err = db.Where("conversation_id = ? AND deleted_at IS NULL", c.ID).All(&current); err != nil {
+			return err
+		}
+
+		existing := map[uuid.UUID]bool{}
+		for _, quote := range current {
+			existing[quote.ID] = true
+		}
+
+		kept := map[uuid.UUID]bool{}
+
+		// loop through all the quotes and save them in their new order
+		for i := range c.Quotes {
+			quote := &c.Quotes[i]
 			quote.Sequence = i
+			quote.DeletedAt = nil
+			action := RevisionUpdate
 
-			if c.ID.String() == "" {
-				verrs, err = quote.Create(db, c.ID)
+			if existing[quote.ID] && !kept[quote.ID] {
+				kept[quote.ID] = true
+				verrs, err = quote.Update(db, c.ID)
 			} else {
+				// either new, or an ID that isn't ours to touch
+				quote.ID = uuid.Nil
+				action = RevisionCreate
 				verrs, err = quote.Create(db, c.ID)
 			}
+
 			if err != nil {
 				return err
 			}
@@ -149,9 +292,44 @@ func (c *Conversation) Update() (*validate.Errors, error) {
 			if verrs.HasAny() {
 				return errors.New(tempError) // this is just to get pop to rollback the transaction
 			}
+
+			if err = recordQuote(db, quote, action, editor); err != nil {
+				return err
+			}
 		}
 
-		return nil
+		// anything left over was removed by the edit, it is kept around
+		// marked deleted so the history can still bring it back
+		now := time.Now()
+
+		for i := range current {
+			if kept[current[i].ID] {
+				continue
+			}
+
+			if err = recordQuote(db, &current[i], RevisionDelete, editor); err != nil {
+				return err
+			}
+
+			if err = db.RawQuery("UPDATE quotes SET deleted_at = ? WHERE id = ?", now, current[i].ID).Exec(); err != nil {
+				return err
+			}
+		}
+
+		verrs, err = SetTags(db, c)
+		if err != nil {
+			return err
+		}
+
+		if verrs.HasAny() {
+			return errors.New(tempError) // this is just to get pop to rollback the transaction
+		}
+
+		if err = queueConversationEvent(db, c, EventConversationUpdated); err != nil {
+			return err
+		}
+
+		return recordConversation(db, c, RevisionUpdate, editor)
 	})
 
 	if err != nil {
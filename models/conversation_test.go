@@ -4,6 +4,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/gofrs/uuid"
 	"github.com/navionguy/cloudquotes/models"
 	"github.com/stretchr/testify/require"
 )
@@ -17,6 +18,11 @@ func Test_Conversation(t *testing.T) {
 		{"id", "conversation id field not found"},
 		{"occurredon", "conversation occurred_on field not found"},
 		{"publish", "conversation publish field not found"},
+		{"meeting", "conversation meeting field not found"},
+		{"location", "conversation location field not found"},
+		{"channel", "conversation channel field not found"},
+		{"setup", "conversation setup field not found"},
+		{"source", "conversation source field not found"},
 		{"Quotes", "conversation Quotes field not found"},
 		{"created_at", "created_at field not found"},
 		{"updated_at", "updated_at field not found"},
@@ -55,16 +61,16 @@ func (ms *ModelSuite) Test_CreateConversation() {
 		Phrase:   "A shiny new quote.",
 		Publish:  true,
 		SaidOn:   time.Now(),
-		AuthorID: authors[0].ID,
+		AuthorID: &authors[0].ID,
 		Sequence: 0,
 	}
 	conversation := models.Conversation{
-		Publish:    true,
+		ArchiveID:  authors[0].ArchiveID,
 		OccurredOn: time.Now(),
 	}
 	conversation.Quotes = append(conversation.Quotes, q)
 
-	verrs, err := models.CreateConversation(&conversation)
+	verrs, err := conversation.Create(ms.DB, uuid.Nil)
 
 	if err != nil {
 		ms.Fail("unable to create conversation", err.Error())
@@ -84,17 +90,17 @@ func (ms *ModelSuite) Test_CreateConversationInvalidOccurredOn() {
 		Phrase:   "A shiny new quote.",
 		Publish:  true,
 		SaidOn:   time.Now(),
-		AuthorID: authors[0].ID,
+		AuthorID: &authors[0].ID,
 		Sequence: 0,
 	}
 	conversation := models.Conversation{
-		Publish:    true,
+		ArchiveID:  authors[0].ArchiveID,
 		OccurredOn: time.Now(),
 	}
 	conversation.Quotes = append(conversation.Quotes, q)
 	conversation.OccurredOn = conversation.OccurredOn.AddDate(0, 0, 2)
 
-	verrs, err := models.CreateConversation(&conversation)
+	verrs, err := conversation.Create(ms.DB, uuid.Nil)
 
 	if err != nil {
 		ms.Fail("unable to create conversation", err.Error())
@@ -118,17 +124,17 @@ func (ms *ModelSuite) Test_CreateConversationInvalidSaidOn() {
 		Phrase:   "A shiny new quote.",
 		Publish:  true,
 		SaidOn:   time.Now(),
-		AuthorID: authors[0].ID,
+		AuthorID: &authors[0].ID,
 		Sequence: 0,
 	}
 	q.SaidOn = q.SaidOn.AddDate(0, 0, 2)
 	conversation := models.Conversation{
-		Publish:    true,
+		ArchiveID:  authors[0].ArchiveID,
 		OccurredOn: time.Now(),
 	}
 	conversation.Quotes = append(conversation.Quotes, q)
 
-	verrs, err := models.CreateConversation(&conversation)
+	verrs, err := conversation.Create(ms.DB, uuid.Nil)
 
 	if err != nil {
 		ms.Fail("unable to create conversation", err.Error())
@@ -144,3 +150,145 @@ func (ms *ModelSuite) Test_CreateConversationInvalidSaidOn() {
 		}
 	}
 }
+
+func Test_ConversationContext(t *testing.T) {
+	rq := require.New(t)
+
+	c := models.Conversation{}
+	rq.Equal("", c.Context())
+
+	c.Meeting = "Sprint Review"
+	c.Channel = "#general"
+	rq.Equal("Sprint Review · #general", c.Context())
+
+	c.Location = "Room 4"
+	rq.Equal("Sprint Review · Room 4 · #general", c.Context())
+}
+
+// savedConversation creates a conversation in its own archive, with a
+// line for each phrase
+func savedConversation(ms *ModelSuite, slug string, phrases ...string) *models.Conversation {
+	archive := &models.Archive{Name: slug, Slug: slug}
+	ms.NoError(ms.DB.Create(archive))
+
+	author := &models.Author{Name: "Bob", ArchiveID: archive.ID, Visibility: models.VisibilityFull}
+	ms.NoError(ms.DB.Create(author))
+
+	cv := &models.Conversation{ArchiveID: archive.ID, OccurredOn: time.Now()}
+	for _, p := range phrases {
+		cv.Quotes = append(cv.Quotes, models.Quote{Phrase: p, SaidOn: time.Now(), AuthorID: &author.ID})
+	}
+
+	verrs, err := cv.Create(ms.DB, uuid.Nil)
+	ms.NoError(err)
+	ms.False(verrs.HasAny(), verrs.String())
+
+	return cv
+}
+
+// savedQuotes reads back the conversation's live lines in order
+func savedQuotes(ms *ModelSuite, cv *models.Conversation) models.Quotes {
+	quotes := models.Quotes{}
+	ms.NoError(ms.DB.Where("conversation_id = ? AND deleted_at IS NULL", cv.ID).Order("sequence ASC").All(&quotes))
+
+	return quotes
+}
+
+// updateConversation saves the conversation with its lines set to quotes
+func updateConversation(ms *ModelSuite, cv *models.Conversation, quotes models.Quotes) {
+	cv.Quotes = quotes
+
+	verrs, err := cv.Update(ms.DB, uuid.Nil)
+	ms.NoError(err)
+	ms.False(verrs.HasAny(), verrs.String())
+}
+
+func (ms *ModelSuite) Test_UpdateConversation_Reorder() {
+	cv := savedConversation(ms, "update-reorder", "First.", "Second.")
+	before := savedQuotes(ms, cv)
+
+	updateConversation(ms, cv, models.Quotes{before[1], before[0]})
+
+	after := savedQuotes(ms, cv)
+	ms.Len(after, 2)
+	ms.Equal(before[1].ID, after[0].ID)
+	ms.Equal("Second.", after[0].Phrase)
+	ms.Equal(before[0].ID, after[1].ID)
+	ms.Equal("First.", after[1].Phrase)
+}
+
+func (ms *ModelSuite) Test_UpdateConversation_Drop() {
+	cv := savedConversation(ms, "update-drop", "First.", "Second.")
+	before := savedQuotes(ms, cv)
+
+	updateConversation(ms, cv, models.Quotes{before[1]})
+
+	after := savedQuotes(ms, cv)
+	ms.Len(after, 1)
+	ms.Equal(before[1].ID, after[0].ID)
+	ms.Equal(0, after[0].Sequence)
+
+	// the dropped line is kept, marked deleted, for the history
+	dropped := &models.Quote{}
+	ms.NoError(ms.DB.Find(dropped, before[0].ID))
+	ms.NotNil(dropped.DeletedAt)
+}
+
+func (ms *ModelSuite) Test_UpdateConversation_Add() {
+	cv := savedConversation(ms, "update-add", "First.")
+	before := savedQuotes(ms, cv)
+
+	added := models.Quote{Phrase: "Second.", SaidOn: time.Now(), AuthorID: before[0].AuthorID}
+	updateConversation(ms, cv, models.Quotes{before[0], added})
+
+	after := savedQuotes(ms, cv)
+	ms.Len(after, 2)
+	ms.Equal(before[0].ID, after[0].ID)
+	ms.Equal("Second.", after[1].Phrase)
+	ms.NotEqual(uuid.Nil, after[1].ID)
+}
+
+// a quote ID from some other conversation gets a new line of its own,
+// the other conversation's line is left alone
+func (ms *ModelSuite) Test_UpdateConversation_ForeignQuote() {
+	cv := savedConversation(ms, "update-ours", "Ours.")
+	other := savedConversation(ms, "update-theirs", "Theirs.")
+	ours := savedQuotes(ms, cv)
+	theirs := savedQuotes(ms, other)
+
+	hijack := theirs[0]
+	hijack.Phrase = "Hijacked."
+	hijack.AuthorID = ours[0].AuthorID
+	updateConversation(ms, cv, models.Quotes{ours[0], hijack})
+
+	after := savedQuotes(ms, cv)
+	ms.Len(after, 2)
+	ms.Equal("Hijacked.", after[1].Phrase)
+	ms.NotEqual(theirs[0].ID, after[1].ID)
+
+	left := savedQuotes(ms, other)
+	ms.Len(left, 1)
+	ms.Equal(theirs[0].ID, left[0].ID)
+	ms.Equal("Theirs.", left[0].Phrase)
+}
+
+func Test_Conversation_LiveQuotes(t *testing.T) {
+	rq := require.New(t)
+
+	trashed := time.Now()
+	removed := trashed.AddDate(0, 0, -1)
+
+	c := models.Conversation{Quotes: models.Quotes{
+		{Phrase: "Live."},
+		{Phrase: "Removed.", DeletedAt: &removed},
+		{Phrase: "Trashed.", DeletedAt: &trashed},
+	}}
+
+	rq.Len(c.LiveQuotes(), 1)
+	rq.Equal("Live.", c.LiveQuotes()[0].Phrase)
+
+	// in the trash, the lines that went in with the conversation are kept
+	c.DeletedAt = &trashed
+	rq.Len(c.LiveQuotes(), 2)
+	rq.Equal("Trashed.", c.LiveQuotes()[1].Phrase)
+}
@@ -0,0 +1,141 @@
+package models
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/gobuffalo/pop/v5"
+	"github.com/gofrs/uuid"
+)
+
+// DuplicateThreshold is how alike two phrases have to be, from 0 to 1,
+// before they are called likely duplicates
+var DuplicateThreshold = 0.5
+
+// duplicateMinWords keeps short lines like "Yes." out of it, they turn
+// up everywhere without being the same quote
+const duplicateMinWords = 3
+
+// Duplicate is an existing quote that looks a lot like one being added
+type Duplicate struct {
+	Phrase         string    `json:"phrase" db:"-"`
+	Score          float64   `json:"score" db:"-"`
+	QuoteID        uuid.UUID `json:"quote_id" db:"id"`
+	ConversationID uuid.UUID `json:"conversation_id" db:"conversation_id"`
+	Existing       string    `json:"existing" db:"phrase"`
+	Author         string    `json:"author" db:"author"`
+}
+
+// Percent is the score as a whole percentage, for showing to people
+func (d Duplicate) Percent() int {
+	return int(d.Score*100 + 0.5)
+}
+
+// Duplicates are the likely duplicates, best match first
+type Duplicates []Duplicate
+
+// trigrams breaks a phrase into the set of three letter runs that make
+// up its words, the same way pg_trgm does.  Case and punctuation are
+// ignored, and each word is padded so the start of a word counts for
+// more than the middle.
+func trigrams(phrase string) map[string]bool {
+	set := map[string]bool{}
+
+	words := strings.FieldsFunc(strings.ToLower(phrase), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+
+	for _, w := range words {
+		padded := []rune("  " + w + " ")
+
+		for i := 0; i+3 <= len(padded); i++ {
+			set[string(padded[i:i+3])] = true
+		}
+	}
+
+	return set
+}
+
+// Similarity scores how alike two phrases are, from 0 for nothing in
+// common to 1 for the same words.  It is the share of trigrams the two
+// have in common.
+func Similarity(a, b string) float64 {
+	ta := trigrams(a)
+	tb := trigrams(b)
+
+	if len(ta) == 0 || len(tb) == 0 {
+		return 0
+	}
+
+	shared := 0
+	for t := range ta {
+		if tb[t] {
+			shared++
+		}
+	}
+
+	return float64(shared) / float64(len(ta)+len(tb)-shared)
+}
+
+// wordCount is how many words are in the phrase
+func wordCount(phrase string) int {
+	return len(strings.FieldsFunc(phrase, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	}))
+}
+
+// FindDuplicates compares the phrases with every quote already in the
+// archive and returns the ones that are likely the same, at most one
+// per existing conversation.  Anything in the trash is left out.
+func FindDuplicates(tx *pop.Connection, archive uuid.UUID, phrases []string) (Duplicates, error) {
+	dups := Duplicates{}
+
+	checked := []string{}
+	for _, p := range phrases {
+		if wordCount(p) >= duplicateMinWords {
+			checked = append(checked, p)
+		}
+	}
+
+	if len(checked) == 0 {
+		return dups, nil
+	}
+
+	existing := Duplicates{}
+
+	err := tx.RawQuery(`SELECT quotes.id, quotes.conversation_id, quotes.phrase, COALESCE(authors.name, '') AS author FROM quotes
+		JOIN conversations ON conversations.id = quotes.conversation_id
+		LEFT JOIN authors ON authors.id = quotes.author_id
+		WHERE conversations.archive_id = ? AND conversations.deleted_at IS NULL AND quotes.deleted_at IS NULL`, archive).All(&existing)
+
+	if err != nil {
+		return nil, err
+	}
+
+	best := map[uuid.UUID]Duplicate{}
+
+	for _, e := range existing {
+		for _, p := range checked {
+			score := Similarity(p, e.Existing)
+
+			if score < DuplicateThreshold || score <= best[e.ConversationID].Score {
+				continue
+			}
+
+			e.Phrase = p
+			e.Score = score
+			best[e.ConversationID] = e
+		}
+	}
+
+	for _, d := range best {
+		dups = append(dups, d)
+	}
+
+	sort.Slice(dups, func(i, j int) bool {
+		return dups[i].Score > dups[j].Score
+	})
+
+	return dups, nil
+}
@@ -0,0 +1,37 @@
+package models_test
+
+import (
+	"testing"
+
+	"github.com/navionguy/cloudquotes/models"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Similarity(t *testing.T) {
+	rq := require.New(t)
+
+	var tests = []struct {
+		a, b string
+		dup  bool
+	}{
+		{"I'll be back.", "I'll be back.", true},
+		{"I'll be back.", "i'll be BACK!", true},
+		{"Nobody expects the Spanish Inquisition!", "No one expects the Spanish inquisition", true},
+		{"Nobody expects the Spanish Inquisition!", "Has anyone seen my stapler?", false},
+		{"", "Has anyone seen my stapler?", false},
+	}
+
+	for _, tt := range tests {
+		score := models.Similarity(tt.a, tt.b)
+		rq.Equalf(tt.dup, score >= models.DuplicateThreshold, "%q vs %q scored %f", tt.a, tt.b, score)
+	}
+
+	rq.Equal(1.0, models.Similarity("same words", "Same, words."))
+}
+
+func Test_Duplicate_Percent(t *testing.T) {
+	rq := require.New(t)
+
+	rq.Equal(67, models.Duplicate{Score: 0.666}.Percent())
+	rq.Equal(100, models.Duplicate{Score: 1}.Percent())
+}
@@ -20,3 +20,28 @@ func init() {
 	}
 	pop.Debug = env == "development"
 }
+
+// inTransaction runs fn so that whatever it changes is undone if it
+// returns an error.  A connection that is already a transaction, like
+// the one each request runs in, gets a savepoint: a failure only rolls
+// back fn's changes, and the rest is committed, or not, along with the
+// request.  Any other connection gets a transaction of its own.
+func inTransaction(tx *pop.Connection, fn func(*pop.Connection) error) error {
+	if tx.TX == nil {
+		return tx.Transaction(fn)
+	}
+
+	if err := tx.RawQuery("SAVEPOINT in_transaction").Exec(); err != nil {
+		return err
+	}
+
+	if err := fn(tx); err != nil {
+		if rerr := tx.RawQuery("ROLLBACK TO SAVEPOINT in_transaction").Exec(); rerr != nil {
+			return rerr
+		}
+
+		return err
+	}
+
+	return tx.RawQuery("RELEASE SAVEPOINT in_transaction").Exec()
+}
@@ -22,7 +22,7 @@ func Test_Permission(t *testing.T) {
 	}
 
 	a := models.Permission{
-		Name: validName,
+		Name: permissionName,
 	}
 
 	// convert Permission to json
@@ -56,49 +56,58 @@ func Test_Permission(t *testing.T) {
 	}
 }
 
-const invalidUUID = "563cd207-ab16-4a46-b44e-7317b96c6ba9"
-const validUUID = "b39300f0-6760-4feb-bc32-4b8682b0175d" // matches entry in testPermissions.toml
-const validName = "RuleTheWorld"
+const permissionName = "RuleTheWorld"
 
-// Test for finding an existing Permission
-func (ms *ModelSuite) Test_Permission_FindByID() {
-	ms.LoadFixture("test Permissions")
+// permittedUser makes a user to grant permissions to
+func permittedUser(ms *ModelSuite) *models.User {
+	u := &models.User{
+		Email:                "ruler@example.com",
+		Password:             "password",
+		PasswordConfirmation: "password",
+	}
 
-	id, err := uuid.FromString(validUUID)
+	verrs, err := u.Create(ms.DB)
+	ms.NoError(err)
+	ms.False(verrs.HasAny(), verrs.String())
 
-	if err != nil {
-		ms.Fail("uuid.FromString failed", err.Error())
-	}
+	return u
+}
 
-	auth := models.Permission{
-		ID: id,
-	}
+// newPermission grants a freshly made user a permission
+func newPermission(ms *ModelSuite) *models.Permission {
+	perm := &models.Permission{Name: permissionName, UserID: permittedUser(ms).ID}
+	ms.NoError(ms.DB.Create(perm))
 
-	pauth, err := auth.FindByID()
+	return perm
+}
 
-	if err != nil {
-		ms.Fail("FindByID failed", err.Error())
+// Test for finding an existing Permission
+func (ms *ModelSuite) Test_Permission_FindByID() {
+	saved := newPermission(ms)
+
+	perm := models.Permission{
+		ID: saved.ID,
 	}
 
-	if pauth == nil {
-		ms.Fail("FindByID failed", "validUUID was not found in database")
+	if err := perm.FindByID(); err != nil {
+		ms.Fail("FindByID failed", err.Error())
 	}
 
-	if strings.Compare(pauth.Name, validName) != 0 {
-		ms.Fail("FindByID didn't find expected Permission", pauth.Name)
+	if strings.Compare(perm.Name, permissionName) != 0 {
+		ms.Fail("FindByID didn't find expected Permission", perm.Name)
 	}
 
 	// as long as I have a valid Permission, check some other functions
 
-	if strings.Compare(pauth.SelectLabel(), validName) != 0 {
-		ms.Fail("unexpected SelectLabel", pauth.SelectLabel())
+	if strings.Compare(perm.SelectLabel(), permissionName) != 0 {
+		ms.Fail("unexpected SelectLabel", perm.SelectLabel())
 	}
 
-	v := pauth.SelectValue()
+	v := perm.SelectValue()
 	s, ok := v.(string)
 
 	if ok {
-		if strings.Compare(s, validUUID) != 0 {
+		if strings.Compare(s, saved.ID.String()) != 0 {
 			ms.Fail("unexpected SelectValue", s)
 		}
 	} else {
@@ -108,7 +117,7 @@ func (ms *ModelSuite) Test_Permission_FindByID() {
 
 // test that FindByID correctly handles NOT finding the Permission
 func (ms *ModelSuite) Test_Permission_FindByID_BadID() {
-	ms.LoadFixture("test Permissions")
+	newPermission(ms)
 
 	id, err := uuid.FromString(invalidUUID)
 
@@ -116,29 +125,22 @@ func (ms *ModelSuite) Test_Permission_FindByID_BadID() {
 		ms.Fail("uuid.FromString failed", err.Error())
 	}
 
-	auth := models.Permission{
+	perm := models.Permission{
 		ID: id,
 	}
 
-	pauth, err := auth.FindByID()
-
-	if err != nil {
-		ms.Fail("FindByID failed", err.Error())
-	}
-
-	if pauth != nil {
-		ms.Fail("FindByID succeeded with an invalid UUID", pauth.Name)
+	if err = perm.FindByID(); err == nil {
+		ms.Fail("FindByID succeeded with an invalid UUID", perm.Name)
 	}
 }
 
 func (ms *ModelSuite) Test_Permission_Create() {
-	ms.LoadFixture("test Permissions")
-
-	auth := models.Permission{
-		Name: "Brand New Permission",
+	perm := models.Permission{
+		Name:   "Brand New Permission",
+		UserID: permittedUser(ms).ID,
 	}
 
-	verrs, err := ms.DB.ValidateAndCreate(&auth)
+	verrs, err := ms.DB.ValidateAndCreate(&perm)
 
 	if err != nil {
 		ms.Fail("failed to create Permission", err.Error())
@@ -150,11 +152,9 @@ func (ms *ModelSuite) Test_Permission_Create() {
 }
 
 func (ms *ModelSuite) Test_Permission_CreateInvalid() {
-	ms.LoadFixture("test Permissions")
-
-	auth := models.Permission{}
+	perm := models.Permission{}
 
-	verrs, err := ms.DB.ValidateAndCreate(&auth)
+	verrs, err := ms.DB.ValidateAndCreate(&perm)
 
 	if err != nil {
 		ms.Fail("failed to create Permission", err.Error())
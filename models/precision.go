@@ -0,0 +1,196 @@
+package models
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PrecisionDay is a date known down to the day
+const PrecisionDay = "day"
+
+// PrecisionMonth is a date only known down to the month
+const PrecisionMonth = "month"
+
+// PrecisionSeason is a date only known as "spring 1998"
+const PrecisionSeason = "season"
+
+// PrecisionYear is a date only known down to the year
+const PrecisionYear = "year"
+
+// PrecisionCirca is a year that is a best guess
+const PrecisionCirca = "circa"
+
+// Precisions are how well a date can be known, from best to worst
+var Precisions = []string{PrecisionDay, PrecisionMonth, PrecisionSeason, PrecisionYear, PrecisionCirca}
+
+// seasons maps the names the loader understands to the month the
+// season starts in.  Winter starts in the December before the year it
+// is named for, so "winter 1998" runs from December 1997 on.
+var seasons = map[string]time.Month{
+	"winter": time.December,
+	"spring": time.March,
+	"summer": time.June,
+	"fall":   time.September,
+	"autumn": time.September,
+}
+
+// circaPrefixes are the ways people write "about", longest first so
+// "circa" isn't taken for "c" followed by "irca"
+var circaPrefixes = []string{"circa", "ca.", "ca", "c.", "c", "~"}
+
+// dateLayouts are the other ways a date can be written, and how precise
+// each one is
+var dateLayouts = []struct {
+	layout    string
+	precision string
+}{
+	{"1/2/2006", PrecisionDay},
+	{"2006-01-02", PrecisionDay},
+	{"1/2006", PrecisionMonth},
+	{"2006-01", PrecisionMonth},
+	{"January 2006", PrecisionMonth},
+	{"Jan 2006", PrecisionMonth},
+	{"2006", PrecisionYear},
+}
+
+// ParseDate reads a date that may only be partly known, like
+// "3/14/1997", "March 1997", "spring 1997", "1997" or "circa 1997", and
+// returns it along with its precision.  Partial dates come back as the
+// start of the period they cover.
+func ParseDate(s string) (time.Time, string, error) {
+	s = strings.ToLower(strings.Join(strings.Fields(s), " "))
+
+	for _, p := range circaPrefixes {
+		if rest := strings.TrimSpace(strings.TrimPrefix(s, p)); rest != s && len(rest) == 4 {
+			if t, err := time.Parse("2006", rest); err == nil {
+				return t, PrecisionCirca, nil
+			}
+		}
+	}
+
+	if fields := strings.Fields(s); len(fields) == 2 {
+		if m, ok := seasons[fields[0]]; ok {
+			y, err := strconv.Atoi(fields[1])
+
+			if err != nil {
+				return time.Time{}, "", fmt.Errorf("can't make a date out of %q", s)
+			}
+
+			if m == time.December {
+				y--
+			}
+
+			return time.Date(y, m, 1, 0, 0, 0, 0, time.UTC), PrecisionSeason, nil
+		}
+	}
+
+	for _, dl := range dateLayouts {
+		if t, err := time.Parse(dl.layout, s); err == nil {
+			return t, dl.precision, nil
+		}
+	}
+
+	return time.Time{}, "", fmt.Errorf("can't make a date out of %q", s)
+}
+
+// seasonStart moves the date back to the first day of the season it
+// falls in
+func seasonStart(t time.Time) time.Time {
+	y, m := t.Year(), t.Month()
+
+	switch {
+	case m < time.March:
+		y, m = y-1, time.December
+	case m < time.December:
+		m -= (m - time.March) % 3
+	}
+
+	return time.Date(y, m, 1, 0, 0, 0, 0, t.Location())
+}
+
+// seasonYear is the year the season the date falls in is named for,
+// December counts toward the winter of the year after
+func seasonYear(t time.Time) int {
+	if t.Month() == time.December {
+		return t.Year() + 1
+	}
+
+	return t.Year()
+}
+
+// seasonOf names the season the month falls in
+func seasonOf(m time.Month) string {
+	switch {
+	case m >= time.March && m < time.June:
+		return "Spring"
+	case m >= time.June && m < time.September:
+		return "Summer"
+	case m >= time.September && m < time.December:
+		return "Fall"
+	}
+
+	return "Winter"
+}
+
+// FormatDate writes the date out only as far as it is known
+func FormatDate(t time.Time, precision string) string {
+	switch precision {
+	case PrecisionMonth:
+		return t.Format("January 2006")
+	case PrecisionSeason:
+		return seasonOf(t.Month()) + " " + strconv.Itoa(seasonYear(t))
+	case PrecisionYear:
+		return t.Format("2006")
+	case PrecisionCirca:
+		return "c. " + t.Format("2006")
+	}
+
+	return t.Format("Jan _2, 2006")
+}
+
+// TruncateDate drops the part of the date that isn't known, so a date
+// picked off a calendar for "sometime in 1998" sorts the same as one
+// the loader read in as "1998".  A season goes back to the month it
+// starts in.
+func TruncateDate(t time.Time, precision string) time.Time {
+	switch precision {
+	case PrecisionSeason:
+		return seasonStart(t)
+	case PrecisionMonth:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+	case PrecisionYear, PrecisionCirca:
+		return time.Date(t.Year(), time.January, 1, 0, 0, 0, 0, t.Location())
+	}
+
+	return t
+}
+
+// When is the date the conversation happened, as far as it is known
+func (c Conversation) When() string {
+	return FormatDate(c.OccurredOn, c.OccurredPrecision)
+}
+
+// When is the date the quote was said, as far as it is known
+func (q Quote) When() string {
+	return FormatDate(q.SaidOn, q.SaidPrecision)
+}
+
+// FirstWhen is when the author was first quoted, as far as it is known
+func (p AuthorProfile) FirstWhen() string {
+	if len(p.Quotes) == 0 {
+		return ""
+	}
+
+	return p.Quotes[0].When()
+}
+
+// LastWhen is when the author was last quoted, as far as it is known
+func (p AuthorProfile) LastWhen() string {
+	if len(p.Quotes) == 0 {
+		return ""
+	}
+
+	return p.Quotes[len(p.Quotes)-1].When()
+}
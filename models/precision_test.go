@@ -0,0 +1,93 @@
+package models_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/navionguy/cloudquotes/models"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ParseDate(t *testing.T) {
+	rq := require.New(t)
+
+	var tests = []struct {
+		in        string
+		on        time.Time
+		precision string
+	}{
+		{"3/14/1997", time.Date(1997, time.March, 14, 0, 0, 0, 0, time.UTC), models.PrecisionDay},
+		{"1997-03-14", time.Date(1997, time.March, 14, 0, 0, 0, 0, time.UTC), models.PrecisionDay},
+		{"3/1997", time.Date(1997, time.March, 1, 0, 0, 0, 0, time.UTC), models.PrecisionMonth},
+		{"March 1997", time.Date(1997, time.March, 1, 0, 0, 0, 0, time.UTC), models.PrecisionMonth},
+		{"mar 1997", time.Date(1997, time.March, 1, 0, 0, 0, 0, time.UTC), models.PrecisionMonth},
+		{"Spring 1998", time.Date(1998, time.March, 1, 0, 0, 0, 0, time.UTC), models.PrecisionSeason},
+		{"autumn 1998", time.Date(1998, time.September, 1, 0, 0, 0, 0, time.UTC), models.PrecisionSeason},
+		{"winter 1998", time.Date(1997, time.December, 1, 0, 0, 0, 0, time.UTC), models.PrecisionSeason},
+		{"1998", time.Date(1998, time.January, 1, 0, 0, 0, 0, time.UTC), models.PrecisionYear},
+		{"circa 2003", time.Date(2003, time.January, 1, 0, 0, 0, 0, time.UTC), models.PrecisionCirca},
+		{"c. 2003", time.Date(2003, time.January, 1, 0, 0, 0, 0, time.UTC), models.PrecisionCirca},
+		{"~2003", time.Date(2003, time.January, 1, 0, 0, 0, 0, time.UTC), models.PrecisionCirca},
+	}
+
+	for _, tt := range tests {
+		on, precision, err := models.ParseDate(tt.in)
+		rq.NoError(err, tt.in)
+		rq.Equal(tt.on, on, tt.in)
+		rq.Equal(tt.precision, precision, tt.in)
+	}
+
+	for _, bad := range []string{"", "yesterday", "spring", "13/1/1997", "circa"} {
+		_, _, err := models.ParseDate(bad)
+		rq.Error(err, bad)
+	}
+}
+
+func Test_FormatDate(t *testing.T) {
+	rq := require.New(t)
+
+	on := time.Date(1998, time.April, 9, 0, 0, 0, 0, time.UTC)
+
+	rq.Equal("Apr  9, 1998", models.FormatDate(on, models.PrecisionDay))
+	rq.Equal("Apr  9, 1998", models.FormatDate(on, ""))
+	rq.Equal("April 1998", models.FormatDate(on, models.PrecisionMonth))
+	rq.Equal("Spring 1998", models.FormatDate(on, models.PrecisionSeason))
+	rq.Equal("1998", models.FormatDate(on, models.PrecisionYear))
+	rq.Equal("c. 1998", models.FormatDate(on, models.PrecisionCirca))
+
+	rq.Equal("Winter 1998", models.FormatDate(time.Date(1998, time.January, 1, 0, 0, 0, 0, time.UTC), models.PrecisionSeason))
+	rq.Equal("Winter 1999", models.FormatDate(time.Date(1998, time.December, 1, 0, 0, 0, 0, time.UTC), models.PrecisionSeason))
+}
+
+func Test_TruncateDate(t *testing.T) {
+	rq := require.New(t)
+
+	on := time.Date(1998, time.April, 9, 15, 4, 5, 0, time.UTC)
+
+	rq.Equal(on, models.TruncateDate(on, models.PrecisionDay))
+	rq.Equal(time.Date(1998, time.April, 1, 0, 0, 0, 0, time.UTC), models.TruncateDate(on, models.PrecisionMonth))
+	rq.Equal(time.Date(1998, time.March, 1, 0, 0, 0, 0, time.UTC), models.TruncateDate(on, models.PrecisionSeason))
+	rq.Equal(time.Date(1997, time.December, 1, 0, 0, 0, 0, time.UTC), models.TruncateDate(time.Date(1998, time.February, 9, 0, 0, 0, 0, time.UTC), models.PrecisionSeason))
+	rq.Equal(time.Date(1998, time.January, 1, 0, 0, 0, 0, time.UTC), models.TruncateDate(on, models.PrecisionYear))
+	rq.Equal(time.Date(1998, time.January, 1, 0, 0, 0, 0, time.UTC), models.TruncateDate(on, models.PrecisionCirca))
+}
+
+// a season written out reads back in as the start of the same season,
+// whatever month it was picked from
+func Test_Season_RoundTrip(t *testing.T) {
+	rq := require.New(t)
+
+	for m := time.January; m <= time.December; m++ {
+		on := models.TruncateDate(time.Date(1998, m, 15, 0, 0, 0, 0, time.UTC), models.PrecisionSeason)
+		written := models.FormatDate(on, models.PrecisionSeason)
+
+		back, precision, err := models.ParseDate(written)
+		rq.NoError(err, written)
+		rq.Equal(models.PrecisionSeason, precision, written)
+		rq.Equal(on, back, written)
+		rq.Equal(written, models.FormatDate(back, models.PrecisionSeason), m.String())
+	}
+
+	rq.Equal("Winter 1998", models.FormatDate(models.TruncateDate(time.Date(1998, time.February, 1, 0, 0, 0, 0, time.UTC), models.PrecisionSeason), models.PrecisionSeason))
+	rq.Equal("Winter 1999", models.FormatDate(models.TruncateDate(time.Date(1998, time.December, 31, 0, 0, 0, 0, time.UTC), models.PrecisionSeason), models.PrecisionSeason))
+}
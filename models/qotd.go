@@ -0,0 +1,128 @@
+package models
+
+import (
+	"database/sql"
+
+	"github.com/gobuffalo/pop/v5"
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+)
+
+// ErrEmptyDeck is returned when there isn't a single published
+// conversation to deal a quote of the day from.
+var ErrEmptyDeck = errors.New("no published conversations to pick from")
+
+// deckLock is the advisory lock key held while an archive's deck is
+// being read or re-dealt.  Without it, two requests that both find the
+// deck empty could each shuffle and hand back different quotes on the
+// same day.  The archive makes up the second half of the key, so one
+// archive's deal doesn't hold up another's.
+const deckLock = 20190228
+
+// deckState tells me if shuffle_deck() has ever been run for an archive
+// and, if so, how many days ago it was run.
+type deckState struct {
+	Dealt bool `db:"dealt"`
+	Day   int  `db:"day"`
+}
+
+// deckCard is one entry pulled out of the shuffled_conversations table
+type deckCard struct {
+	ConversationID uuid.UUID `db:"conversation_id"`
+}
+
+// QuoteOfTheDay works out which conversation in the archive is up today.
+//
+// Every archive has its own deck in shuffled_conversations, and
+// shuffle_deck() notes the day it was dealt in the decks table.  Today's
+// card is the archive's Nth published conversation in its deck, where N
+// is the number of days since the deal.  Unpublished conversations are
+// skipped over so they never take up a day.  When the archive runs out
+// of cards, a fresh deck is dealt for it and we start over from the top.
+//
+// All the date math is done by the database so every client agrees on
+// what "today" is, no matter what time zone it lives in.
+func QuoteOfTheDay(tx *pop.Connection, archive uuid.UUID) (uuid.UUID, error) {
+	if err := tx.RawQuery("SELECT pg_advisory_xact_lock(?, hashtext(?))", deckLock, archive.String()).Exec(); err != nil {
+		return uuid.Nil, err
+	}
+
+	ds, err := deckDay(tx, archive)
+
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	if ds.Dealt {
+		id, err := drawCard(tx, archive, ds.Day)
+
+		if err == nil {
+			return id, nil
+		}
+
+		if errors.Cause(err) != sql.ErrNoRows {
+			return uuid.Nil, err
+		}
+	}
+
+	// either there never was a deck, or we've played every card in it
+
+	n, err := tx.Where("archive_id = ? AND status = ? AND deleted_at IS NULL AND "+hasPublicLine("conversations"), archive, StatusPublished).Count(&Conversation{})
+
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	if n == 0 {
+		return uuid.Nil, ErrEmptyDeck
+	}
+
+	if err = tx.RawQuery("SELECT shuffle_deck(?)", archive).Exec(); err != nil {
+		return uuid.Nil, err
+	}
+
+	id, err := drawCard(tx, archive, 0)
+
+	if errors.Cause(err) == sql.ErrNoRows {
+		return uuid.Nil, ErrEmptyDeck
+	}
+
+	return id, err
+}
+
+// RemoveFromDeck pulls a conversation out of its archive's deck.  The
+// deck holds a foreign key to the conversation, so this has to happen
+// before a conversation can be destroyed.
+func RemoveFromDeck(tx *pop.Connection, id uuid.UUID) error {
+	return tx.RawQuery("DELETE FROM shuffled_conversations WHERE conversation_id = ?", id).Exec()
+}
+
+// deckDay reads back the day shuffle_deck() last dealt the archive's
+// deck, if it ever has.
+func deckDay(tx *pop.Connection, archive uuid.UUID) (deckState, error) {
+	states := []deckState{}
+
+	err := tx.RawQuery("SELECT TRUE AS dealt, CURRENT_DATE - dealt_on AS day FROM decks WHERE archive_id = ?", archive).All(&states)
+
+	if err != nil || len(states) == 0 {
+		return deckState{}, err
+	}
+
+	return states[0], nil
+}
+
+// drawCard returns the conversation sitting at position day in the
+// archive's deck, counting only the published ones that aren't in the
+// trash and have something left to show once hidden authors are taken
+// out.
+func drawCard(tx *pop.Connection, archive uuid.UUID, day int) (uuid.UUID, error) {
+	card := deckCard{}
+
+	err := tx.RawQuery(`SELECT s.conversation_id FROM shuffled_conversations s
+		JOIN conversations c ON c.id = s.conversation_id
+		WHERE s.archive_id = ? AND c.status = ? AND c.deleted_at IS NULL AND `+hasPublicLine("c")+`
+		ORDER BY s.sequence
+		OFFSET ? LIMIT 1`, archive, StatusPublished, day).First(&card)
+
+	return card.ConversationID, err
+}
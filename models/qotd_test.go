@@ -0,0 +1,51 @@
+package models_test
+
+import (
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/navionguy/cloudquotes/models"
+)
+
+// publishedConversation sets up an archive with a single published
+// conversation in it
+func publishedConversation(ms *ModelSuite, slug string) (uuid.UUID, uuid.UUID) {
+	archive := &models.Archive{Name: slug, Slug: slug}
+	ms.NoError(ms.DB.Create(archive))
+
+	author := &models.Author{Name: "Bob", ArchiveID: archive.ID, Visibility: models.VisibilityFull}
+	ms.NoError(ms.DB.Create(author))
+
+	cv := &models.Conversation{ArchiveID: archive.ID, OccurredOn: time.Now(), Status: models.StatusPublished, Publish: true}
+	ms.NoError(ms.DB.Create(cv))
+
+	q := &models.Quote{ConversationID: cv.ID, AuthorID: &author.ID, SaidOn: time.Now(), Sequence: 1, Phrase: "Ship it.", Publish: true}
+	ms.NoError(ms.DB.Create(q))
+
+	return archive.ID, cv.ID
+}
+
+// every archive is dealt from its own deck
+func (ms *ModelSuite) Test_QuoteOfTheDay_PerArchive() {
+	first, firstCV := publishedConversation(ms, "first-deck")
+	second, secondCV := publishedConversation(ms, "second-deck")
+
+	id, err := models.QuoteOfTheDay(ms.DB, first)
+	ms.NoError(err)
+	ms.Equal(firstCV, id)
+
+	id, err = models.QuoteOfTheDay(ms.DB, second)
+	ms.NoError(err)
+	ms.Equal(secondCV, id)
+
+	// dealing the second archive's deck left the first one's alone
+	count := struct {
+		N int `db:"n"`
+	}{}
+	ms.NoError(ms.DB.RawQuery("SELECT COUNT(*) AS n FROM shuffled_conversations WHERE archive_id = ?", first).First(&count))
+	ms.Equal(1, count.N)
+
+	id, err = models.QuoteOfTheDay(ms.DB, first)
+	ms.NoError(err)
+	ms.Equal(firstCV, id)
+}
@@ -2,32 +2,61 @@ package models
 
 import (
 	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/gobuffalo/envy"
 	"github.com/gobuffalo/pop/v5"
 	"github.com/gobuffalo/validate/v3"
 	"github.com/gobuffalo/validate/v3/validators"
 	"github.com/gofrs/uuid"
 )
 
+// MaxQuoteLength is the most characters a phrase or an annotation can
+// hold.  The columns are text, so this is only about keeping things
+// readable, set QUOTE_MAX_LENGTH to change it.
+var MaxQuoteLength = maxQuoteLength()
+
+// maxQuoteLength reads QUOTE_MAX_LENGTH, falling back to 4000 if it
+// isn't a sensible number
+func maxQuoteLength() int {
+	n, err := strconv.Atoi(envy.Get("QUOTE_MAX_LENGTH", "4000"))
+
+	if err != nil || n < 1 {
+		return 4000
+	}
+
+	return n
+}
+
 // Quote holds what one person said
 type Quote struct {
-	ID        uuid.UUID `json:"id" db:"id"`
-	CreatedAt time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
-	SaidOn    time.Time `json:"said_on" db:"saidon" form:"SaidOn"`
-	Sequence  int       `json:"sequence" db:"sequence" form:"sequence"`
-	Phrase    string    `json:"phrase" db:"phrase" form:"Phrase"`
-	Publish   bool      `json:"publish" db:"publish" form:"MakePublic"`
+	ID        uuid.UUID  `json:"id" db:"id"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at" db:"updated_at"`
+	SaidOn    time.Time  `json:"said_on" db:"saidon" form:"SaidOn"`
+	Sequence  int        `json:"sequence" db:"sequence" form:"sequence"`
+	Phrase    string     `json:"phrase" db:"phrase" form:"Phrase"`
+	Publish   bool       `json:"publish" db:"publish" form:"MakePublic"`
+	DeletedAt *time.Time `json:"deleted_at" db:"deleted_at"`
+
+	// SaidPrecision says how much of SaidOn is really known
+	SaidPrecision string `json:"said_precision" db:"said_precision" form:"SaidPrecision"`
 
 	// Relationships
 	Conversation Conversation `json:"-" belongs_to:"conversation" db:"-"`
 	Author       Author       `belongs_to:"author" db:"-"`
 	Annotation   *Annotation  `belongs_to:"annotation" db:"-"`
 
-	// Foreign keys
+	// CoAuthors said the line along with Author, "in unison" or as a
+	// whole team
+	CoAuthors Authors `json:"co_authors" many_to_many:"quote_authors" db:"-"`
+
+	// Foreign keys, a narrator line like "(phone rings)" has no AuthorID
 	ConversationID uuid.UUID  `json:"conversation_id" db:"conversation_id"`
-	AuthorID       uuid.UUID  `json:"author_id" db:"author_id"`
+	AuthorID       *uuid.UUID `json:"author_id" db:"author_id"`
 	AnnotationID   *uuid.UUID `json:"annotation_id" db:"annotation_id"`
 }
 
@@ -49,21 +78,29 @@ func (q Quotes) String() string {
 // Validate gets run every time you call a "pop.Validate*" (pop.ValidateAndSave, pop.ValidateAndCreate, pop.ValidateAndUpdate) method.
 // This method is not required and may be deleted.
 func (q *Quote) Validate(tx *pop.Connection) (*validate.Errors, error) {
+	// dates that don't say otherwise are known to the day
+	if len(q.SaidPrecision) == 0 {
+		q.SaidPrecision = PrecisionDay
+	}
+
+	q.SaidOn = TruncateDate(q.SaidOn, q.SaidPrecision)
+
 	return validate.Validate(
 		&validators.TimeIsPresent{Field: q.SaidOn, Name: "SaidOn"},
+		&validators.StringInclusion{Field: q.SaidPrecision, Name: "SaidPrecision", List: Precisions},
 		&validators.TimeIsBeforeTime{FirstTime: q.SaidOn, SecondTime: time.Now().AddDate(0, 0, 1), FirstName: "Said On", SecondName: "Tomorrow"},
 
 		&validators.IntIsGreaterThan{Field: q.Sequence, Name: "sequence", Compared: -1, Message: "sequence must be >= 0"},
 
 		&validators.StringIsPresent{Field: q.Phrase, Name: "Phrase"},
-		&validators.StringLengthInRange{Field: q.Phrase, Name: "Phrase", Min: 1, Max: 255, Message: "length must be <255"},
+		&validators.StringLengthInRange{Field: q.Phrase, Name: "Phrase", Min: 1, Max: MaxQuoteLength, Message: fmt.Sprintf("length must be <%d", MaxQuoteLength)},
 
 		&validators.FuncValidator{
-			Field:   q.AuthorID.String(),
-			Name:    "AuthorID",
-			Message: "quote.AuthorID %s is NIL",
+			Field:   q.Phrase,
+			Name:    "CoAuthors",
+			Message: "narrator line %s can't have co-authors",
 			Fn: func() bool {
-				return !(q.AuthorID == uuid.Nil)
+				return !q.Narration() || len(q.CoAuthors) == 0
 			},
 		},
 	), nil
@@ -84,35 +121,91 @@ func (q *Quote) ValidateUpdate(tx *pop.Connection) (*validate.Errors, error) {
 // Create saves a quote pointing at the conversation
 func (q *Quote) Create(db *pop.Connection, id uuid.UUID) (*validate.Errors, error) {
 
-	verrs, err := q.Annotation.CheckID(db)
+	// save the ConversationID into the quote
+	q.ConversationID = id
+
+	verrs, err := q.linkAnnotation(db)
 
 	if err != nil || verrs.HasAny() {
 		return verrs, err
 	}
 
-	// save the ConversationID into the quote
-	q.ConversationID = id
+	if err = q.withholdOptedOut(db, true); err != nil {
+		return nil, err
+	}
 
 	// add the quote
 	verrs, err = db.ValidateAndCreate(q)
 
-	return verrs, err
+	if err != nil || verrs.HasAny() {
+		return verrs, err
+	}
+
+	return verrs, q.setCoAuthors(db)
 }
 
-// Update saves a quote pointing at the conversation
+// Update re-saves a quote that is already in the conversation
 func (q *Quote) Update(db *pop.Connection, id uuid.UUID) (*validate.Errors, error) {
 
-	verrs, err := q.Annotation.CheckID(db)
+	// save the ConversationID into the quote
+	q.ConversationID = id
+
+	verrs, err := q.linkAnnotation(db)
 
 	if err != nil || verrs.HasAny() {
 		return verrs, err
 	}
 
-	// save the ConversationID into the quote
-	q.ConversationID = id
+	if err = q.withholdOptedOut(db, false); err != nil {
+		return nil, err
+	}
 
-	// add the quote
+	// update the quote
 	verrs, err = db.ValidateAndUpdate(q)
 
-	return verrs, err
+	if err != nil || verrs.HasAny() {
+		return verrs, err
+	}
+
+	return verrs, q.setCoAuthors(db)
+}
+
+// linkAnnotation points the quote at the annotation row holding its note,
+// creating the row if nobody in the conversation's archive has used that
+// note before.  A blank note takes the annotation off the quote.
+func (q *Quote) linkAnnotation(db *pop.Connection) (*validate.Errors, error) {
+	if q.Annotation == nil || len(strings.TrimSpace(q.Annotation.Note)) == 0 {
+		q.Annotation = nil
+		q.AnnotationID = nil
+		return validate.NewErrors(), nil
+	}
+
+	archive, err := conversationArchive(db, q.ConversationID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	annoRecs := []Annotation{}
+
+	if err := db.Where("note = ? AND archive_id = ?", q.Annotation.Note, archive).All(&annoRecs); err != nil {
+		return nil, err
+	}
+
+	if len(annoRecs) > 0 {
+		*q.Annotation = annoRecs[0]
+	} else {
+		q.Annotation.ID = uuid.Nil
+		q.Annotation.ArchiveID = archive
+
+		verrs, err := db.ValidateAndCreate(q.Annotation)
+
+		if err != nil || verrs.HasAny() {
+			return verrs, err
+		}
+	}
+
+	q.AnnotationID = &q.Annotation.ID
+
+	return validate.NewErrors(), nil
 }
@@ -0,0 +1,112 @@
+package models
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/gobuffalo/pop/v5"
+	"github.com/gofrs/uuid"
+)
+
+// QuoteAuthor is one row in the join between quotes and the co-authors
+// who said them along with the quote's own author
+type QuoteAuthor struct {
+	ID        uuid.UUID `json:"id" db:"id"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+	QuoteID   uuid.UUID `json:"quote_id" db:"quote_id"`
+	AuthorID  uuid.UUID `json:"author_id" db:"author_id"`
+}
+
+// String is not required by pop and may be deleted
+func (qa QuoteAuthor) String() string {
+	jqa, _ := json.Marshal(qa)
+	return string(jqa)
+}
+
+// Narration reports if the quote is a narrator line, something like
+// "*long pause*" or "(phone rings)" that nobody said
+func (q Quote) Narration() bool {
+	return q.AuthorID == nil
+}
+
+// Speakers is everybody who said the line, its author first.  Narrator
+// lines have nobody.
+func (q Quote) Speakers() Authors {
+	speakers := Authors{}
+
+	if q.Narration() {
+		return speakers
+	}
+
+	speakers = append(speakers, q.Author)
+
+	for _, a := range q.CoAuthors {
+		if q.Author.ID == uuid.Nil || a.ID != q.Author.ID {
+			speakers = append(speakers, a)
+		}
+	}
+
+	return speakers
+}
+
+// Credit names everybody who said the line, like "Bob & Alice".  It is
+// blank for narrator lines.
+func (q Quote) Credit() string {
+	names := []string{}
+
+	for _, a := range q.Speakers() {
+		names = append(names, a.Name)
+	}
+
+	return strings.Join(names, " & ")
+}
+
+// speakerIDs lists the IDs of everybody credited with the line, leaving
+// out any that have been masked
+func (q Quote) speakerIDs() []uuid.UUID {
+	ids := []uuid.UUID{}
+
+	if q.AuthorID != nil && *q.AuthorID != uuid.Nil {
+		ids = append(ids, *q.AuthorID)
+	}
+
+	for _, a := range q.CoAuthors {
+		if a.ID != uuid.Nil {
+			ids = append(ids, a.ID)
+		}
+	}
+
+	return ids
+}
+
+// setCoAuthors replaces whoever the quote was credited to along with its
+// author with the ones in q.CoAuthors.  The author themselves, or the
+// same co-author twice, only gets joined once.
+func (q *Quote) setCoAuthors(tx *pop.Connection) error {
+	err := tx.RawQuery("DELETE FROM quote_authors WHERE quote_id = ?", q.ID).Exec()
+
+	if err != nil {
+		return err
+	}
+
+	seen := map[uuid.UUID]bool{uuid.Nil: true}
+
+	if q.AuthorID != nil {
+		seen[*q.AuthorID] = true
+	}
+
+	for _, a := range q.CoAuthors {
+		if seen[a.ID] {
+			continue
+		}
+		seen[a.ID] = true
+
+		if err = tx.Create(&QuoteAuthor{QuoteID: q.ID, AuthorID: a.ID}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,47 @@
+package models_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/navionguy/cloudquotes/models"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Quote_Speakers(t *testing.T) {
+	rq := require.New(t)
+
+	bob := models.Author{ID: uuid.Must(uuid.NewV4()), Name: "Bob McGowan"}
+	beth := models.Author{ID: uuid.Must(uuid.NewV4()), Name: "Beth Smith"}
+
+	q := models.Quote{Phrase: "(phone rings)"}
+	rq.True(q.Narration())
+	rq.Len(q.Speakers(), 0)
+	rq.Equal("", q.Credit())
+
+	q = models.Quote{Phrase: "Not it!", Author: bob, AuthorID: &bob.ID}
+	rq.False(q.Narration())
+	rq.Equal("Bob McGowan", q.Credit())
+
+	// the author listed again as a co-author only counts once
+	q.CoAuthors = models.Authors{beth, bob}
+	rq.Len(q.Speakers(), 2)
+	rq.Equal("Bob McGowan & Beth Smith", q.Credit())
+}
+
+func Test_Quote_Narration_CoAuthors(t *testing.T) {
+	rq := require.New(t)
+
+	q := models.Quote{Phrase: "*long pause*", SaidOn: time.Now()}
+
+	verrs, err := q.Validate(nil)
+	rq.NoError(err)
+	rq.False(verrs.HasAny(), verrs.String())
+
+	q.CoAuthors = models.Authors{{ID: uuid.Must(uuid.NewV4()), Name: "Bob McGowan"}}
+
+	verrs, err = q.Validate(nil)
+	rq.NoError(err)
+	rq.True(verrs.HasAny())
+}
@@ -1,6 +1,7 @@
 package models_test
 
 import (
+	"strings"
 	"testing"
 	"time"
 
@@ -16,9 +17,9 @@ func Test_Quote(t *testing.T) {
 		msg string
 	}{
 		{"id", "quote id field not found"},
-		{"occurredon", "quote occurred_on field not found"},
+		{"phrase", "quote phrase field not found"},
+		{"sequence", "quote sequence field not found"},
 		{"publish", "quote publish field not found"},
-		{"Quotes", "quote Quotes field not found"},
 		{"created_at", "created_at field not found"},
 		{"updated_at", "updated_at field not found"},
 		{"said_on", "quote said_on field not found"},
@@ -51,45 +52,54 @@ func Test_Quote(t *testing.T) {
 	}
 }
 
-// loadFixtureData loads three of the four tables created in the "test quotes" scenario
-//
-// Not much code needed to load the data, but lots of steps to check for any failures to
-// get the data I expect.  Not all the tests use the loaded data.
-func loadFixtureData(ms *ModelSuite) ([]models.Author, []models.Annotation, []models.Conversation) {
-	ms.LoadFixture("test quotes")
-
-	authors := []models.Author{}
-	annotations := []models.Annotation{}
-	conversations := []models.Conversation{}
+func Test_Quote_Length(t *testing.T) {
+	rq := require.New(t)
 
-	err := ms.DB.All(&authors)
+	author := uuid.Must(uuid.NewV4())
 
-	if err != nil {
-		ms.FailNow("error getting authors", err.Error())
+	q := models.Quote{
+		SaidOn:   time.Now(),
+		AuthorID: &author,
 	}
 
-	if len(authors) == 0 {
-		ms.FailNow("no authors found", "no test authors")
-	}
+	// rants run well past the old 255 character limit
+	q.Phrase = strings.Repeat("blah ", 200)
+
+	verrs, err := q.Validate(nil)
+	rq.NoError(err)
+	rq.False(verrs.HasAny(), verrs.String())
 
-	err = ms.DB.All(&annotations)
+	q.Phrase = strings.Repeat("x", models.MaxQuoteLength+1)
+
+	verrs, err = q.Validate(nil)
+	rq.NoError(err)
+	rq.True(verrs.HasAny())
+}
+
+// loadFixtureData sets up an archive with an author, an annotation and
+// a conversation in it for the quote tests to use.  Not all the tests
+// use the loaded data.
+func loadFixtureData(ms *ModelSuite) ([]models.Author, []models.Annotation, []models.Conversation) {
+	archive, err := models.DefaultArchive(ms.DB)
 
 	if err != nil {
-		ms.FailNow("error getting annotations", err.Error())
+		ms.FailNow("error getting archive", err.Error())
 	}
 
-	if len(annotations) == 0 {
-		ms.FailNow("no annotations found", "no test annotations")
-	}
+	authors := []models.Author{{Name: validName, ArchiveID: archive.ID}}
+	annotations := []models.Annotation{{Note: validAnnotation, ArchiveID: archive.ID}}
+	conversations := []models.Conversation{{ArchiveID: archive.ID, OccurredOn: time.Now(), Status: models.StatusDraft}}
 
-	err = ms.DB.All(&conversations)
+	if err = ms.DB.Create(&authors[0]); err != nil {
+		ms.FailNow("error creating author", err.Error())
+	}
 
-	if err != nil {
-		ms.FailNow("error getting conversations", err.Error())
+	if err = ms.DB.Create(&annotations[0]); err != nil {
+		ms.FailNow("error creating annotation", err.Error())
 	}
 
-	if len(conversations) == 0 {
-		ms.FailNow("no conversations found", "no test conversations")
+	if err = ms.DB.Create(&conversations[0]); err != nil {
+		ms.FailNow("error creating conversation", err.Error())
 	}
 
 	return authors, annotations, conversations
@@ -105,7 +115,7 @@ func (ms *ModelSuite) Test_CreateQuote() {
 		Sequence: 0,
 		Phrase:   "A test quote.",
 		Publish:  true,
-		AuthorID: authors[0].ID,
+		AuthorID: &authors[0].ID,
 	}
 
 	verrs, err := quote.Create(ms.DB, conversations[0].ID)
@@ -129,7 +139,7 @@ func (ms *ModelSuite) Test_CreateQuoteWithAnnotation() {
 		Phrase:     "A test quote.",
 		Publish:    true,
 		Annotation: &annotations[0],
-		AuthorID:   authors[0].ID,
+		AuthorID:   &authors[0].ID,
 	}
 
 	verrs, err := quote.Create(ms.DB, conversations[0].ID)
@@ -155,7 +165,7 @@ func (ms *ModelSuite) Test_CreateQuoteWithNewAnnotation() {
 		Phrase:     "A test quote.",
 		Publish:    true,
 		Annotation: &annotations[0],
-		AuthorID:   authors[0].ID,
+		AuthorID:   &authors[0].ID,
 	}
 
 	verrs, err := quote.Create(ms.DB, conversations[0].ID)
@@ -181,7 +191,7 @@ func (ms *ModelSuite) Test_CreateQuoteWithInvalidAnnotation() {
 		Phrase:     "A test quote.",
 		Publish:    true,
 		Annotation: &annotations[0],
-		AuthorID:   authors[0].ID,
+		AuthorID:   &authors[0].ID,
 	}
 
 	verrs, err := quote.Create(ms.DB, conversations[0].ID)
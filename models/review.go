@@ -0,0 +1,225 @@
+package models
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/gobuffalo/pop/v5"
+	"github.com/gobuffalo/validate/v3"
+	"github.com/gofrs/uuid"
+)
+
+// The states a conversation moves through on its way to the wall
+const (
+	StatusDraft     = "draft"
+	StatusSubmitted = "submitted"
+	StatusApproved  = "approved"
+	StatusRejected  = "rejected"
+	StatusPublished = "published"
+)
+
+// Statuses lists every state a conversation can be in
+var Statuses = []string{StatusDraft, StatusSubmitted, StatusApproved, StatusRejected, StatusPublished}
+
+// PermEditor is the permission a user needs to review conversations
+const PermEditor = "editor"
+
+// transition is one move allowed by the workflow
+type transition struct {
+	From   string
+	To     string
+	Editor bool // only editors may make this move
+}
+
+// workflow holds every move a conversation can make.  Anyone signed in
+// can send a draft off for review, or pull it back.  Only an editor can
+// decide what happens to it after that.
+var workflow = []transition{
+	{From: StatusDraft, To: StatusSubmitted},
+	{From: StatusSubmitted, To: StatusDraft},
+	{From: StatusRejected, To: StatusDraft},
+	{From: StatusSubmitted, To: StatusApproved, Editor: true},
+	{From: StatusSubmitted, To: StatusRejected, Editor: true},
+	{From: StatusApproved, To: StatusPublished, Editor: true},
+	{From: StatusApproved, To: StatusRejected, Editor: true},
+	{From: StatusPublished, To: StatusApproved, Editor: true},
+}
+
+// Review records one move through the workflow, who made it, and what
+// the reviewer had to say about it.
+type Review struct {
+	ID             uuid.UUID  `json:"id" db:"id"`
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at" db:"updated_at"`
+	ConversationID uuid.UUID  `json:"conversation_id" db:"conversation_id"`
+	UserID         *uuid.UUID `json:"user_id" db:"user_id"`
+	FromStatus     string     `json:"from_status" db:"from_status"`
+	ToStatus       string     `json:"to_status" db:"to_status"`
+	Comment        string     `json:"comment" db:"comment"`
+}
+
+// String is not required by pop and may be deleted
+func (r Review) String() string {
+	jr, _ := json.Marshal(r)
+	return string(jr)
+}
+
+// Reviews is not required by pop and may be deleted
+type Reviews []Review
+
+// String is not required by pop and may be deleted
+func (r Reviews) String() string {
+	jr, _ := json.Marshal(r)
+	return string(jr)
+}
+
+// CanTransition reports if the conversation can move to the status to.
+// editor says if the user asking holds the editor permission.
+func (c Conversation) CanTransition(to string, editor bool) bool {
+	for _, t := range workflow {
+		if t.From == c.Status && t.To == to {
+			return editor || !t.Editor
+		}
+	}
+
+	return false
+}
+
+// NextStatuses lists every status the conversation can move to from
+// where it is now.
+func (c Conversation) NextStatuses(editor bool) []string {
+	next := []string{}
+
+	for _, t := range workflow {
+		if t.From == c.Status && (editor || !t.Editor) {
+			next = append(next, t.To)
+		}
+	}
+
+	return next
+}
+
+// Transition moves the conversation to the status to, recording who did
+// it in a Review.  A rejection has to say why, so comment can't be blank
+// when to is StatusRejected.
+func (c *Conversation) Transition(tx *pop.Connection, to string, user uuid.UUID, editor bool, comment string) (*validate.Errors, error) {
+	verrs := validate.NewErrors()
+	comment = strings.TrimSpace(comment)
+
+	if !c.CanTransition(to, editor) {
+		verrs.Add("status", "a "+c.Status+" conversation can't be moved to "+to)
+		return verrs, nil
+	}
+
+	if to == StatusRejected && len(comment) == 0 {
+		verrs.Add("comment", "a rejection needs a comment saying why")
+		return verrs, nil
+	}
+
+	review := &Review{
+		ConversationID: c.ID,
+		FromStatus:     c.Status,
+		ToStatus:       to,
+		Comment:        comment,
+	}
+
+	if user != uuid.Nil {
+		review.UserID = &user
+	}
+
+	// publish is kept in step so the wall only ever has to look at one flag
+	c.Status = to
+	c.Publish = to == StatusPublished
+
+	err := tx.RawQuery("UPDATE conversations SET status = ?, publish = ?, updated_at = ? WHERE id = ?", c.Status, c.Publish, time.Now(), c.ID).Exec()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Create(review); err != nil {
+		return nil, err
+	}
+
+	event := EventConversationUpdated
+	if c.Publish {
+		event = EventConversationPublished
+	}
+
+	if err = queueConversationEvent(tx, c, event); err != nil {
+		return nil, err
+	}
+
+	return verrs, recordConversation(tx, c, RevisionUpdate, user)
+}
+
+// Reopen sends a conversation that has already been through review back
+// to the review queue, for when someone other than an editor changes it.
+// What the editors signed off on isn't what is there any more, so it
+// comes off the wall until they look again.  Conversations still on
+// their way through review are left where they are.
+func (c *Conversation) Reopen(tx *pop.Connection, user uuid.UUID) error {
+	if c.Status != StatusApproved && c.Status != StatusPublished {
+		return nil
+	}
+
+	review := &Review{
+		ConversationID: c.ID,
+		FromStatus:     c.Status,
+		ToStatus:       StatusSubmitted,
+		Comment:        "changed after it was " + c.Status,
+	}
+
+	if user != uuid.Nil {
+		review.UserID = &user
+	}
+
+	c.Status = StatusSubmitted
+	c.Publish = false
+
+	err := tx.RawQuery("UPDATE conversations SET status = ?, publish = ?, updated_at = ? WHERE id = ?", c.Status, c.Publish, time.Now(), c.ID).Exec()
+
+	if err != nil {
+		return err
+	}
+
+	return tx.Create(review)
+}
+
+// LatestReview returns the most recent move the conversation made into
+// the status to, or nil if it never has.
+func (c Conversation) LatestReview(tx *pop.Connection, to string) (*Review, error) {
+	reviews := Reviews{}
+
+	err := tx.Where("conversation_id = ? AND to_status = ?", c.ID, to).Order("created_at DESC").Limit(1).All(&reviews)
+
+	if err != nil || len(reviews) == 0 {
+		return nil, err
+	}
+
+	return &reviews[0], nil
+}
+
+// HasPermission reports if the user has been granted the named permission
+func HasPermission(tx *pop.Connection, user uuid.UUID, name string) (bool, error) {
+	if user == uuid.Nil {
+		return false, nil
+	}
+
+	return tx.Where("user_id = ? AND name = ?", user, name).Exists(&Permission{})
+}
+
+// PublishedQuotes returns only the quotes in the conversation that are
+// marked for publishing.
+func (c Conversation) PublishedQuotes() Quotes {
+	quotes := Quotes{}
+
+	for _, q := range c.LiveQuotes() {
+		if q.Publish {
+			quotes = append(quotes, q)
+		}
+	}
+
+	return quotes
+}
@@ -0,0 +1,94 @@
+package models_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/navionguy/cloudquotes/models"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Review(t *testing.T) {
+
+	var fields = []struct {
+		fn  string
+		msg string
+	}{
+		{"id", "review id field not found"},
+		{"conversation_id", "conversation_id field not found"},
+		{"user_id", "user_id field not found"},
+		{"from_status", "from_status field not found"},
+		{"to_status", "to_status field not found"},
+		{"comment", "comment field not found"},
+		{"created_at", "created_at field not found"},
+		{"updated_at", "updated_at field not found"},
+	}
+
+	r := models.Review{}
+
+	js := r.String()
+
+	rq := require.New(t)
+
+	for _, fld := range fields {
+		rq.Containsf(js, fld.fn, fld.msg)
+	}
+}
+
+func Test_CanTransition(t *testing.T) {
+	rq := require.New(t)
+
+	var moves = []struct {
+		from   string
+		to     string
+		editor bool
+		ok     bool
+	}{
+		{models.StatusDraft, models.StatusSubmitted, false, true},
+		{models.StatusDraft, models.StatusPublished, true, false},
+		{models.StatusSubmitted, models.StatusApproved, false, false},
+		{models.StatusSubmitted, models.StatusApproved, true, true},
+		{models.StatusSubmitted, models.StatusRejected, true, true},
+		{models.StatusRejected, models.StatusDraft, false, true},
+		{models.StatusApproved, models.StatusPublished, false, false},
+		{models.StatusApproved, models.StatusPublished, true, true},
+		{models.StatusPublished, models.StatusApproved, true, true},
+	}
+
+	for _, m := range moves {
+		c := models.Conversation{Status: m.from}
+		rq.Equalf(m.ok, c.CanTransition(m.to, m.editor), "%s to %s, editor %v", m.from, m.to, m.editor)
+	}
+
+	c := models.Conversation{Status: models.StatusSubmitted}
+	rq.Equal([]string{models.StatusDraft}, c.NextStatuses(false))
+	rq.Equal([]string{models.StatusDraft, models.StatusApproved, models.StatusRejected}, c.NextStatuses(true))
+}
+
+// a change to a published conversation takes it off the wall until an
+// editor has looked at it again
+func (ms *ModelSuite) Test_Conversation_Reopen() {
+	archive, err := models.DefaultArchive(ms.DB)
+	ms.NoError(err)
+
+	cv := &models.Conversation{ArchiveID: archive.ID, OccurredOn: time.Now(), Status: models.StatusPublished, Publish: true}
+	ms.NoError(ms.DB.Create(cv))
+
+	ms.NoError(cv.Reopen(ms.DB, uuid.Nil))
+
+	saved := &models.Conversation{}
+	ms.NoError(ms.DB.Find(saved, cv.ID))
+	ms.Equal(models.StatusSubmitted, saved.Status)
+	ms.False(saved.Publish)
+
+	review, err := saved.LatestReview(ms.DB, models.StatusSubmitted)
+	ms.NoError(err)
+	ms.Equal(models.StatusPublished, review.FromStatus)
+
+	// a draft is still being worked on, so it stays put
+	draft := &models.Conversation{ArchiveID: archive.ID, OccurredOn: time.Now(), Status: models.StatusDraft}
+	ms.NoError(ms.DB.Create(draft))
+	ms.NoError(draft.Reopen(ms.DB, uuid.Nil))
+	ms.Equal(models.StatusDraft, draft.Status)
+}
@@ -0,0 +1,612 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"html/template"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gobuffalo/pop/v5"
+	"github.com/gobuffalo/validate/v3"
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+)
+
+// The kinds of things that keep a revision history
+const (
+	RevisionConversation = "conversation"
+	RevisionQuote        = "quote"
+	RevisionAnnotation   = "annotation"
+)
+
+// What happened to the item in a revision
+const (
+	RevisionCreate = "create"
+	RevisionUpdate = "update"
+	RevisionDelete = "delete"
+	RevisionRevert = "revert"
+)
+
+// Revision is a copy of a conversation, quote, or annotation as it
+// looked right after somebody changed it.  For a delete it is how the
+// item looked just before it went away.
+type Revision struct {
+	ID             uuid.UUID  `json:"id" db:"id"`
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at" db:"updated_at"`
+	ConversationID *uuid.UUID `json:"conversation_id" db:"conversation_id"`
+	ItemType       string     `json:"item_type" db:"item_type"`
+	ItemID         uuid.UUID  `json:"item_id" db:"item_id"`
+	Action         string     `json:"action" db:"action"`
+	UserID         *uuid.UUID `json:"user_id" db:"user_id"`
+	Snapshot       string     `json:"snapshot" db:"snapshot"`
+}
+
+// String is not required by pop and may be deleted
+func (r Revision) String() string {
+	jr, _ := json.Marshal(r)
+	return string(jr)
+}
+
+// Revisions is not required by pop and may be deleted
+type Revisions []Revision
+
+// String is not required by pop and may be deleted
+func (r Revisions) String() string {
+	jr, _ := json.Marshal(r)
+	return string(jr)
+}
+
+// Snapshot holds the fields of an item that people care about, already
+// formatted for reading.
+type Snapshot map[string]string
+
+// snapshotFields is the order fields are shown in on the history page
+var snapshotFields = []string{"occurred_on", "occurred_precision", "status", "meeting", "location", "channel", "setup", "source", "publish", "tags", "said_on", "said_precision", "author", "phrase", "annotation", "note"}
+
+// Fields returns the snapshot decoded from the revision
+func (r Revision) Fields() Snapshot {
+	snap := Snapshot{}
+	_ = json.Unmarshal([]byte(r.Snapshot), &snap)
+	return snap
+}
+
+// FieldChange is one field that differs between two revisions of an item
+type FieldChange struct {
+	Field string
+	Old   string
+	New   string
+	Diff  template.HTML
+}
+
+// HistoryEntry is a revision along with what it changed, ready for the
+// history page.
+type HistoryEntry struct {
+	Revision
+	Who     string
+	Changes []FieldChange
+}
+
+// HistoryEntries is the full history of a conversation, newest first
+type HistoryEntries []HistoryEntry
+
+// RecordRevision saves a revision of an item.  An update that didn't
+// actually change anything since the last revision isn't worth keeping,
+// so it gets skipped.
+func RecordRevision(db *pop.Connection, conversationID *uuid.UUID, itemType string, itemID uuid.UUID, action string, snap Snapshot, editor uuid.UUID) error {
+	js, err := json.Marshal(snap)
+
+	if err != nil {
+		return err
+	}
+
+	if action == RevisionUpdate {
+		last := &Revision{}
+		err = db.Where("item_type = ? AND item_id = ?", itemType, itemID).Order("created_at DESC").First(last)
+
+		if err == nil && last.Snapshot == string(js) {
+			return nil
+		}
+
+		if err != nil && errors.Cause(err) != sql.ErrNoRows {
+			return err
+		}
+	}
+
+	rev := &Revision{
+		ConversationID: conversationID,
+		ItemType:       itemType,
+		ItemID:         itemID,
+		Action:         action,
+		Snapshot:       string(js),
+	}
+
+	if editor != uuid.Nil {
+		rev.UserID = &editor
+	}
+
+	return db.Create(rev)
+}
+
+// conversationSnapshot captures the conversation level fields
+func conversationSnapshot(c *Conversation) Snapshot {
+	return Snapshot{
+		"occurred_on":        c.OccurredOn.Format("2006-01-02"),
+		"occurred_precision": c.OccurredPrecision,
+		"status":             c.Status,
+		"meeting":            c.Meeting,
+		"location":           c.Location,
+		"channel":            c.Channel,
+		"setup":              c.Setup,
+		"source":             c.Source,
+		"tags":               c.TagList(),
+	}
+}
+
+// quoteSnapshot captures a quote, looking up the speakers' names and
+// the annotation if they weren't loaded with it.  Narrator lines have
+// no speakers to look up.
+func quoteSnapshot(db *pop.Connection, q *Quote) (Snapshot, error) {
+	said := Quote{AuthorID: q.AuthorID, Author: q.Author}
+	authorID := ""
+
+	if q.AuthorID != nil {
+		authorID = q.AuthorID.String()
+
+		if said.Author.ID != *q.AuthorID {
+			if err := db.Find(&said.Author, *q.AuthorID); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// the quote is always saved by now, so whoever it is credited to along
+	// with its author can be read back
+	err := db.Where("id IN (SELECT author_id FROM quote_authors WHERE quote_id = ?)", q.ID).Order("name").All(&said.CoAuthors)
+
+	if err != nil {
+		return nil, err
+	}
+
+	coAuthorIDs := []string{}
+
+	for _, a := range said.CoAuthors {
+		coAuthorIDs = append(coAuthorIDs, a.ID.String())
+	}
+
+	note := ""
+
+	if q.AnnotationID != nil {
+		if q.Annotation != nil && q.Annotation.ID == *q.AnnotationID {
+			note = q.Annotation.Note
+		} else {
+			anno := &Annotation{}
+			if err := db.Find(anno, *q.AnnotationID); err != nil {
+				return nil, err
+			}
+			note = anno.Note
+		}
+	}
+
+	return Snapshot{
+		"phrase":         q.Phrase,
+		"author":         said.Credit(),
+		"author_id":      authorID,
+		"co_author_ids":  strings.Join(coAuthorIDs, ","),
+		"said_on":        q.SaidOn.Format("2006-01-02"),
+		"said_precision": q.SaidPrecision,
+		"sequence":       strconv.Itoa(q.Sequence),
+		"publish":        strconv.FormatBool(q.Publish),
+		"annotation":     note,
+	}, nil
+}
+
+// annotationSnapshot captures the annotation's note
+func annotationSnapshot(a *Annotation) Snapshot {
+	return Snapshot{"note": a.Note}
+}
+
+// recordConversation saves a revision of the conversation fields
+func recordConversation(db *pop.Connection, c *Conversation, action string, editor uuid.UUID) error {
+	return RecordRevision(db, &c.ID, RevisionConversation, c.ID, action, conversationSnapshot(c), editor)
+}
+
+// recordQuote saves a revision of a quote
+func recordQuote(db *pop.Connection, q *Quote, action string, editor uuid.UUID) error {
+	snap, err := quoteSnapshot(db, q)
+
+	if err != nil {
+		return err
+	}
+
+	return RecordRevision(db, &q.ConversationID, RevisionQuote, q.ID, action, snap, editor)
+}
+
+// recordAnnotation saves a revision of an annotation.  Annotations are
+// shared, so they don't belong to any one conversation.
+func recordAnnotation(db *pop.Connection, a *Annotation, action string, editor uuid.UUID) error {
+	return RecordRevision(db, nil, RevisionAnnotation, a.ID, action, annotationSnapshot(a), editor)
+}
+
+// RecordDestroy saves the last look at a conversation and its quotes
+// before they get destroyed.
+func (c *Conversation) RecordDestroy(db *pop.Connection, editor uuid.UUID) error {
+	for i := range c.Quotes {
+		if err := recordQuote(db, &c.Quotes[i], RevisionDelete, editor); err != nil {
+			return err
+		}
+	}
+
+	return recordConversation(db, c, RevisionDelete, editor)
+}
+
+// ConversationHistory pulls every revision that touched the conversation,
+// including the annotations its quotes use, newest first.  Each entry
+// carries what changed since the revision before it.
+func ConversationHistory(tx *pop.Connection, id uuid.UUID) (HistoryEntries, error) {
+	revs := Revisions{}
+
+	err := tx.Where(`conversation_id = ?
+		OR (item_type = ? AND item_id IN (SELECT annotation_id FROM quotes WHERE conversation_id = ? AND annotation_id IS NOT NULL))`,
+		id, RevisionAnnotation, id).Order("created_at ASC").All(&revs)
+
+	if err != nil {
+		return nil, err
+	}
+
+	who, err := editorNames(tx, revs)
+
+	if err != nil {
+		return nil, err
+	}
+
+	entries := HistoryEntries{}
+	prev := map[uuid.UUID]Snapshot{}
+
+	for _, rev := range revs {
+		snap := rev.Fields()
+		entry := HistoryEntry{Revision: rev, Changes: diffSnapshots(prev[rev.ItemID], snap)}
+
+		if rev.UserID != nil {
+			entry.Who = who[*rev.UserID]
+		}
+
+		prev[rev.ItemID] = snap
+		entries = append(HistoryEntries{entry}, entries...)
+	}
+
+	return entries, nil
+}
+
+// editorNames maps the user IDs in the revisions to their email address
+func editorNames(tx *pop.Connection, revs Revisions) (map[uuid.UUID]string, error) {
+	ids := []interface{}{}
+
+	for _, rev := range revs {
+		if rev.UserID != nil {
+			ids = append(ids, *rev.UserID)
+		}
+	}
+
+	return userEmails(tx, ids)
+}
+
+// userEmails maps each of the user IDs to that user's email address
+func userEmails(tx *pop.Connection, ids []interface{}) (map[uuid.UUID]string, error) {
+	names := map[uuid.UUID]string{}
+
+	if len(ids) == 0 {
+		return names, nil
+	}
+
+	users := []User{}
+
+	if err := tx.Where("id in (?)", ids...).All(&users); err != nil {
+		return nil, err
+	}
+
+	for _, u := range users {
+		names[u.ID] = u.Email
+	}
+
+	return names, nil
+}
+
+// diffSnapshots lists the fields that differ between two snapshots
+func diffSnapshots(old, new Snapshot) []FieldChange {
+	changes := []FieldChange{}
+
+	for _, field := range snapshotFields {
+		o, inOld := old[field]
+		n, inNew := new[field]
+
+		if !inOld && !inNew {
+			continue
+		}
+
+		if inOld && o == n {
+			continue
+		}
+
+		changes = append(changes, FieldChange{Field: field, Old: o, New: n, Diff: DiffWords(o, n)})
+	}
+
+	return changes
+}
+
+// DiffWords marks up the word by word difference between two strings.
+// Words that were taken out are wrapped in <del> and words that were
+// put in are wrapped in <ins>.
+func DiffWords(old, new string) template.HTML {
+	a := strings.Fields(old)
+	b := strings.Fields(new)
+
+	// lcs[i][j] is the longest common run of words in a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	out := []string{}
+	i, j := 0, 0
+
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			out = append(out, template.HTMLEscapeString(a[i]))
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			out = append(out, "<del>"+template.HTMLEscapeString(a[i])+"</del>")
+			i++
+		default:
+			out = append(out, "<ins>"+template.HTMLEscapeString(b[j])+"</ins>")
+			j++
+		}
+	}
+
+	return template.HTML(strings.Join(out, " "))
+}
+
+// Revert puts the item back the way it was in this revision.  Reverting
+// the revision where a quote was deleted brings the quote back.  The
+// revert is itself recorded as a new revision.
+func (r *Revision) Revert(tx *pop.Connection, editor uuid.UUID) (*validate.Errors, error) {
+	snap := r.Fields()
+
+	switch r.ItemType {
+	case RevisionConversation:
+		return r.revertConversation(tx, snap, editor)
+	case RevisionQuote:
+		return r.revertQuote(tx, snap, editor)
+	case RevisionAnnotation:
+		return r.revertAnnotation(tx, snap, editor)
+	}
+
+	return nil, errors.Errorf("unknown revision item type %s", r.ItemType)
+}
+
+// revertConversation restores the date, context and tags.  The status is left
+// alone, it only moves through the review workflow.
+func (r *Revision) revertConversation(tx *pop.Connection, snap Snapshot, editor uuid.UUID) (*validate.Errors, error) {
+	c := &Conversation{}
+
+	if err := tx.Find(c, r.ItemID); err != nil {
+		return nil, err
+	}
+
+	on, err := time.Parse("2006-01-02", snap["occurred_on"])
+
+	if err != nil {
+		return nil, err
+	}
+
+	c.OccurredOn = on
+	c.OccurredPrecision = snap["occurred_precision"]
+	c.Meeting = snap["meeting"]
+	c.Location = snap["location"]
+	c.Channel = snap["channel"]
+	c.Setup = snap["setup"]
+	c.Source = snap["source"]
+	c.Tags = ParseTags(snap["tags"])
+
+	verrs, err := tx.ValidateAndUpdate(c)
+
+	if err != nil || verrs.HasAny() {
+		return verrs, err
+	}
+
+	verrs, err = SetTags(tx, c)
+
+	if err != nil || verrs.HasAny() {
+		return verrs, err
+	}
+
+	if err = queueConversationEvent(tx, c, EventConversationUpdated); err != nil {
+		return nil, err
+	}
+
+	return verrs, recordConversation(tx, c, RevisionRevert, editor)
+}
+
+// revertQuote restores a quote, recreating it if it was deleted
+func (r *Revision) revertQuote(tx *pop.Connection, snap Snapshot, editor uuid.UUID) (*validate.Errors, error) {
+	q := &Quote{}
+	err := tx.Find(q, r.ItemID)
+	missing := errors.Cause(err) == sql.ErrNoRows
+
+	if err != nil && !missing {
+		return nil, err
+	}
+
+	// narrator lines were saved without an author
+	q.AuthorID = nil
+
+	if len(snap["author_id"]) > 0 {
+		id, err := uuid.FromString(snap["author_id"])
+
+		if err != nil {
+			return nil, err
+		}
+
+		q.AuthorID = &id
+	}
+
+	q.CoAuthors = Authors{}
+
+	for _, s := range strings.Split(snap["co_author_ids"], ",") {
+		if len(s) == 0 {
+			continue
+		}
+
+		id, err := uuid.FromString(s)
+
+		if err != nil {
+			return nil, err
+		}
+
+		q.CoAuthors = append(q.CoAuthors, Author{ID: id})
+	}
+
+	// a speaker merged away or removed since can't be put back
+	verrs, err := speakersExist(tx, q)
+
+	if err != nil || verrs.HasAny() {
+		return verrs, err
+	}
+
+	if q.SaidOn, err = time.Parse("2006-01-02", snap["said_on"]); err != nil {
+		return nil, err
+	}
+
+	q.SaidPrecision = snap["said_precision"]
+	q.Phrase = snap["phrase"]
+	q.Publish = snap["publish"] == "true"
+	q.Annotation = nil
+
+	if len(snap["annotation"]) > 0 {
+		q.Annotation = &Annotation{Note: snap["annotation"]}
+	}
+
+	if missing {
+		if r.ConversationID == nil {
+			return nil, errors.New("quote revision has no conversation")
+		}
+
+		q.ID = r.ItemID
+		q.ConversationID = *r.ConversationID
+
+		found, ferr := tx.Where("id = ?", q.ConversationID).Exists(&Conversation{})
+
+		if ferr != nil {
+			return nil, ferr
+		}
+
+		if !found {
+			verrs.Add("conversation", "the conversation this quote belonged to is gone")
+			return verrs, nil
+		}
+	}
+
+	// a quote an edit removed is still there, marked deleted, and goes
+	// back in the same as one that was gone altogether
+	if missing || q.DeletedAt != nil {
+		if q.Sequence, err = strconv.Atoi(snap["sequence"]); err != nil {
+			return nil, err
+		}
+
+		// make room for it back where it used to be
+		err = tx.RawQuery("UPDATE quotes SET sequence = sequence + 1 WHERE conversation_id = ? AND sequence >= ? AND deleted_at IS NULL", q.ConversationID, q.Sequence).Exec()
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if missing {
+		verrs, err = q.Create(tx, q.ConversationID)
+	} else {
+		q.DeletedAt = nil
+		verrs, err = q.Update(tx, q.ConversationID)
+	}
+
+	if err != nil || verrs.HasAny() {
+		return verrs, err
+	}
+
+	// the quote's conversation changed along with it
+	c := &Conversation{}
+
+	if err = tx.Find(c, q.ConversationID); err != nil {
+		return nil, err
+	}
+
+	if err = queueConversationEvent(tx, c, EventConversationUpdated); err != nil {
+		return nil, err
+	}
+
+	return verrs, recordQuote(tx, q, RevisionRevert, editor)
+}
+
+// speakersExist checks that everyone credited with the quote is still
+// around
+func speakersExist(tx *pop.Connection, q *Quote) (*validate.Errors, error) {
+	verrs := validate.NewErrors()
+	ids := []uuid.UUID{}
+
+	if q.AuthorID != nil {
+		ids = append(ids, *q.AuthorID)
+	}
+
+	for _, a := range q.CoAuthors {
+		ids = append(ids, a.ID)
+	}
+
+	for _, id := range ids {
+		found, err := tx.Where("id = ?", id).Exists(&Author{})
+
+		if err != nil {
+			return nil, err
+		}
+
+		if !found {
+			verrs.Add("author", "a speaker in this revision no longer exists")
+			return verrs, nil
+		}
+	}
+
+	return verrs, nil
+}
+
+// revertAnnotation restores an annotation's note
+func (r *Revision) revertAnnotation(tx *pop.Connection, snap Snapshot, editor uuid.UUID) (*validate.Errors, error) {
+	a := &Annotation{}
+
+	if err := tx.Find(a, r.ItemID); err != nil {
+		return nil, err
+	}
+
+	a.Note = snap["note"]
+
+	verrs, err := tx.ValidateAndUpdate(a)
+
+	if err != nil || verrs.HasAny() {
+		return verrs, err
+	}
+
+	return verrs, recordAnnotation(tx, a, RevisionRevert, editor)
+}
@@ -0,0 +1,70 @@
+package models_test
+
+import (
+	"testing"
+
+	"github.com/gofrs/uuid"
+	"github.com/navionguy/cloudquotes/models"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Revision(t *testing.T) {
+
+	var fields = []struct {
+		fn  string
+		msg string
+	}{
+		{"id", "revision id field not found"},
+		{"conversation_id", "conversation_id field not found"},
+		{"item_type", "item_type field not found"},
+		{"item_id", "item_id field not found"},
+		{"action", "action field not found"},
+		{"user_id", "user_id field not found"},
+		{"snapshot", "snapshot field not found"},
+		{"created_at", "created_at field not found"},
+		{"updated_at", "updated_at field not found"},
+	}
+
+	r := models.Revision{
+		ItemType: models.RevisionQuote,
+		Action:   models.RevisionUpdate,
+		Snapshot: `{"phrase":"It works on my machine."}`,
+	}
+
+	js := r.String()
+
+	rq := require.New(t)
+
+	for _, fld := range fields {
+		rq.Containsf(js, fld.fn, fld.msg)
+	}
+
+	rq.Equal("It works on my machine.", r.Fields()["phrase"])
+}
+
+func Test_DiffWords(t *testing.T) {
+	rq := require.New(t)
+
+	rq.Equal("It <del>works</del> <ins>worked</ins> on my machine.", string(models.DiffWords("It works on my machine.", "It worked on my machine.")))
+	rq.Equal("<ins>Ship</ins> <ins>it.</ins>", string(models.DiffWords("", "Ship it.")))
+	rq.Equal("same words", string(models.DiffWords("same  words", "same words")))
+	rq.Equal("<del>&lt;b&gt;</del>", string(models.DiffWords("<b>", "")))
+}
+
+// a quote can't be put back once its speaker is gone
+func (ms *ModelSuite) Test_Revision_RevertQuote_AuthorGone() {
+	conversation := uuid.Must(uuid.NewV4())
+
+	r := models.Revision{
+		ConversationID: &conversation,
+		ItemType:       models.RevisionQuote,
+		ItemID:         uuid.Must(uuid.NewV4()),
+		Action:         models.RevisionDelete,
+		Snapshot:       `{"author_id":"` + uuid.Must(uuid.NewV4()).String() + `","said_on":"2021-01-01","phrase":"Who said that?","sequence":"1"}`,
+	}
+
+	verrs, err := r.Revert(ms.DB, uuid.Nil)
+
+	ms.NoError(err)
+	ms.NotEmpty(verrs.Get("author"))
+}
@@ -0,0 +1,91 @@
+package models
+
+import (
+	"html"
+	"html/template"
+	"strings"
+
+	"github.com/gobuffalo/pop/v5"
+	"github.com/gofrs/uuid"
+)
+
+// searchHits finds every conversation with a quote that matches the search
+// terms in the phrase, the speaker's name, or the annotation.  Narrator
+// lines have no speaker, so only their phrase and annotation count.
+// Each conversation gets the rank of its best matching quote.  The name
+// of an author that isn't shown in full only counts for the editors.
+//
+// Each of the three columns is matched on its own so the GIN indexes from
+// the conversation_search migration get used.
+const searchHits = `(SELECT q.conversation_id,
+		MAX(ts_rank(to_tsvector('english', q.phrase) || to_tsvector('english', COALESCE(CASE WHEN a.visibility = 'full' OR ? THEN a.name END, '')) || to_tsvector('english', COALESCE(n.note, '')), plainto_tsquery('english', ?))) AS rank
+	FROM quotes q
+	LEFT JOIN authors a ON a.id = q.author_id
+	LEFT JOIN annotations n ON n.id = q.annotation_id
+	WHERE q.deleted_at IS NULL
+	AND (to_tsvector('english', q.phrase) @@ plainto_tsquery('english', ?)
+	OR (to_tsvector('english', a.name) @@ plainto_tsquery('english', ?) AND (a.visibility = 'full' OR ?))
+	OR to_tsvector('english', n.note) @@ plainto_tsquery('english', ?))
+	GROUP BY q.conversation_id) hits`
+
+// highlight markers are picked so they survive html escaping, then get
+// swapped for <mark> tags once the phrase is safe to drop into a page.
+const (
+	hlStart = "[[["
+	hlStop  = "]]]"
+)
+
+// SearchConversations narrows a conversation query down to the ones that
+// match the search terms, best matches first.  realNames lets the search
+// match every author by name, not just the ones shown in full.
+func SearchConversations(q *pop.Query, terms string, realNames bool) *pop.Query {
+	return q.Join("INNER JOIN "+searchHits, "hits.conversation_id = conversations.id", realNames, terms, terms, terms, realNames, terms).
+		Order("hits.rank DESC")
+}
+
+// searchHighlight is the best matching phrase for a conversation, with the
+// matching words marked.
+type searchHighlight struct {
+	ConversationID uuid.UUID `db:"conversation_id"`
+	Phrase         string    `db:"phrase"`
+}
+
+// SearchHighlights marks up the search terms in the quotes of the passed
+// conversations.  For each conversation I pick the quote that best matches,
+// so a hit in the third line of an exchange still shows up on the index.
+// The results are html escaped and ready to render.
+func SearchHighlights(tx *pop.Connection, terms string, conversations Conversations) (map[uuid.UUID]template.HTML, error) {
+	highlights := map[uuid.UUID]template.HTML{}
+
+	if len(conversations) == 0 {
+		return highlights, nil
+	}
+
+	ids := make([]uuid.UUID, len(conversations))
+	for i, cv := range conversations {
+		ids[i] = cv.ID
+	}
+
+	hits := []searchHighlight{}
+	opts := "StartSel=" + hlStart + ", StopSel=" + hlStop + ", HighlightAll=TRUE"
+
+	err := tx.RawQuery(`SELECT DISTINCT ON (q.conversation_id) q.conversation_id,
+			ts_headline('english', q.phrase, plainto_tsquery('english', ?), ?) AS phrase
+		FROM quotes q
+		WHERE q.conversation_id IN (?) AND q.deleted_at IS NULL
+		ORDER BY q.conversation_id, ts_rank(to_tsvector('english', q.phrase), plainto_tsquery('english', ?)) DESC, q.sequence`,
+		terms, opts, ids, terms).All(&hits)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, hit := range hits {
+		phrase := html.EscapeString(hit.Phrase)
+		phrase = strings.Replace(phrase, hlStart, "<mark>", -1)
+		phrase = strings.Replace(phrase, hlStop, "</mark>", -1)
+		highlights[hit.ConversationID] = template.HTML(phrase)
+	}
+
+	return highlights, nil
+}
@@ -0,0 +1,247 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/gobuffalo/pop/v5"
+	"github.com/gobuffalo/validate/v3"
+	"github.com/gobuffalo/validate/v3/validators"
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+)
+
+// Tag groups conversations by topic, "release week" or "customer calls"
+type Tag struct {
+	ID        uuid.UUID `json:"id" db:"id"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+	Name      string    `json:"name" db:"name" form:"name"`
+	ArchiveID uuid.UUID `json:"archive_id" db:"archive_id"`
+}
+
+// TagCredit lets me show how many conversations carry each tag
+type TagCredit struct {
+	ID    uuid.UUID `json:"id" db:"id"`
+	Name  string    `json:"name" db:"name"`
+	Count int       `json:"count" db:"count"`
+}
+
+// TagCredits holds all the tags
+type TagCredits []TagCredit
+
+// ConversationTag is one row in the join between conversations and tags
+type ConversationTag struct {
+	ID             uuid.UUID `json:"id" db:"id"`
+	CreatedAt      time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at" db:"updated_at"`
+	ConversationID uuid.UUID `json:"conversation_id" db:"conversation_id"`
+	TagID          uuid.UUID `json:"tag_id" db:"tag_id"`
+}
+
+// TableName overrides the default pop would pick for the join table
+func (ct ConversationTag) TableName() string {
+	return "conversations_tags"
+}
+
+// String is not required by pop and may be deleted
+func (t Tag) String() string {
+	jt, _ := json.Marshal(t)
+	return string(jt)
+}
+
+// Tags is not required by pop and may be deleted
+type Tags []Tag
+
+// String is not required by pop and may be deleted
+func (t Tags) String() string {
+	jt, _ := json.Marshal(t)
+	return string(jt)
+}
+
+// Validate gets run every time you call a "pop.Validate*" (pop.ValidateAndSave, pop.ValidateAndCreate, pop.ValidateAndUpdate) method.
+// This method is not required and may be deleted.
+func (t *Tag) Validate(tx *pop.Connection) (*validate.Errors, error) {
+	var err error
+	return validate.Validate(
+		&validators.StringIsPresent{Field: t.Name, Name: "Name"},
+		&validators.StringLengthInRange{Field: t.Name, Name: "Name", Min: 1, Max: 64, Message: "length must be <64"},
+		// tag names have to be unique in an archive, or merging makes no sense
+		&validators.FuncValidator{
+			Field:   t.Name,
+			Name:    "Name",
+			Message: "%s is already a tag",
+			Fn: func() bool {
+				var b bool
+				q := tx.Where("name = ? AND archive_id = ?", t.Name, t.ArchiveID)
+				if t.ID != uuid.Nil {
+					q = q.Where("id != ?", t.ID)
+				}
+				b, err = q.Exists(&Tag{})
+				if err != nil {
+					return false
+				}
+				return !b
+			},
+		},
+	), err
+}
+
+// ValidateCreate gets run every time you call "pop.ValidateAndCreate" method.
+// This method is not required and may be deleted.
+func (t *Tag) ValidateCreate(tx *pop.Connection) (*validate.Errors, error) {
+	return validate.NewErrors(), nil
+}
+
+// ValidateUpdate gets run every time you call "pop.ValidateAndUpdate" method.
+// This method is not required and may be deleted.
+func (t *Tag) ValidateUpdate(tx *pop.Connection) (*validate.Errors, error) {
+	return validate.NewErrors(), nil
+}
+
+// SelectValue returns the tag ID value to a form SelectTag
+func (t Tag) SelectValue() interface{} {
+	return t.ID.String()
+}
+
+// SelectLabel allows tags to be in a form SelectTag
+func (t Tag) SelectLabel() string {
+	return t.Name
+}
+
+// NormalizeTagName folds a tag name down to lower case with single
+// spaces so "Release  Week" and "release week" are the same tag.
+func NormalizeTagName(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
+
+// ParseTags splits a comma separated list of tag names, like the one
+// typed into the conversation form.
+func ParseTags(list string) Tags {
+	tags := Tags{}
+
+	for _, name := range strings.Split(list, ",") {
+		name = NormalizeTagName(name)
+
+		if len(name) > 0 {
+			tags = append(tags, Tag{Name: name})
+		}
+	}
+
+	return tags
+}
+
+// FindOrCreate looks for the tag by name in the tag's archive, creating
+// it if this is the first time anyone there has used it.
+func (t *Tag) FindOrCreate(tx *pop.Connection) (*validate.Errors, error) {
+	t.Name = NormalizeTagName(t.Name)
+
+	tagRecs := []Tag{}
+	err := tx.Where("name = ? AND archive_id = ?", t.Name, t.ArchiveID).All(&tagRecs)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(tagRecs) > 0 {
+		*t = tagRecs[0]
+		return validate.NewErrors(), nil
+	}
+
+	t.ID = uuid.Nil
+
+	return tx.ValidateAndCreate(t)
+}
+
+// SetTags replaces whatever tags the conversation had with the ones in
+// c.Tags, finding or creating each in the conversation's archive.
+func SetTags(tx *pop.Connection, c *Conversation) (*validate.Errors, error) {
+	err := tx.RawQuery("DELETE FROM conversations_tags WHERE conversation_id = ?", c.ID).Exec()
+
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{}
+	tags := c.Tags
+
+	for i := range tags {
+		tags[i].ArchiveID = c.ArchiveID
+
+		verrs, err := tags[i].FindOrCreate(tx)
+
+		if err != nil || verrs.HasAny() {
+			return verrs, err
+		}
+
+		// the same tag twice in the list only gets joined once
+		if seen[tags[i].Name] {
+			continue
+		}
+		seen[tags[i].Name] = true
+
+		err = tx.Create(&ConversationTag{ConversationID: c.ID, TagID: tags[i].ID})
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return validate.NewErrors(), nil
+}
+
+// FilterByTag narrows a conversation query down to the ones carrying the
+// named tag.
+func FilterByTag(q *pop.Query, name string) *pop.Query {
+	return q.Where("conversations.id IN (SELECT ct.conversation_id FROM conversations_tags ct JOIN tags ON tags.id = ct.tag_id WHERE tags.name = ?)", NormalizeTagName(name))
+}
+
+// Rename changes the tag's name.  If another tag already has the new
+// name, the two get merged instead and t ends up as the surviving tag.
+func (t *Tag) Rename(tx *pop.Connection, name string) (*validate.Errors, error) {
+	into := &Tag{}
+	name = NormalizeTagName(name)
+
+	err := tx.Where("name = ? AND archive_id = ? AND id != ?", name, t.ArchiveID, t.ID).First(into)
+
+	if err != nil && errors.Cause(err) != sql.ErrNoRows {
+		return nil, err
+	}
+
+	if err == nil {
+		if err = t.MergeInto(tx, into); err != nil {
+			return nil, err
+		}
+
+		*t = *into
+		return validate.NewErrors(), nil
+	}
+
+	t.Name = name
+
+	return tx.ValidateAndUpdate(t)
+}
+
+// MergeInto moves every conversation carrying this tag over to the into
+// tag, then removes this tag.
+func (t *Tag) MergeInto(tx *pop.Connection, into *Tag) error {
+	// conversations that already carry both tags just lose this one
+	err := tx.RawQuery(`UPDATE conversations_tags SET tag_id = ?
+		WHERE tag_id = ?
+		AND conversation_id NOT IN (SELECT conversation_id FROM conversations_tags WHERE tag_id = ?)`,
+		into.ID, t.ID, into.ID).Exec()
+
+	if err != nil {
+		return err
+	}
+
+	err = tx.RawQuery("DELETE FROM conversations_tags WHERE tag_id = ?", t.ID).Exec()
+
+	if err != nil {
+		return err
+	}
+
+	return tx.Destroy(t)
+}
@@ -0,0 +1,61 @@
+package models_test
+
+import (
+	"testing"
+
+	"github.com/navionguy/cloudquotes/models"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Tag(t *testing.T) {
+
+	var fields = []struct {
+		fn  string
+		msg string
+	}{
+		{"id", "tag id field not found"},
+		{"name", "tag name field not found"},
+		{"created_at", "created_at field not found"},
+		{"updated_at", "updated_at field not found"},
+	}
+
+	a := models.Tag{
+		Name: "release week",
+	}
+
+	js := a.String()
+
+	if len(js) == 0 {
+		t.Error("unable to marshal a tag")
+		t.FailNow()
+	}
+
+	rq := require.New(t)
+
+	for _, fld := range fields {
+		rq.Containsf(js, fld.fn, fld.msg)
+	}
+
+	var ar models.Tags
+	ar = append(ar, a)
+
+	js = ar.String()
+
+	if len(js) == 0 {
+		t.Error("unable to marshal array of tags")
+		t.Fail()
+	}
+}
+
+func Test_ParseTags(t *testing.T) {
+	rq := require.New(t)
+
+	tags := models.ParseTags(" Release  Week, customer calls,, ")
+
+	rq.Len(tags, 2)
+	rq.Equal("release week", tags[0].Name)
+	rq.Equal("customer calls", tags[1].Name)
+
+	cv := models.Conversation{Tags: tags}
+	rq.Equal("release week, customer calls", cv.TagList())
+}
@@ -0,0 +1,131 @@
+package models
+
+import (
+	"time"
+
+	"github.com/gobuffalo/pop/v5"
+	"github.com/gofrs/uuid"
+)
+
+// RevisionRestore is the revision action for bringing an item back out
+// of the trash.
+const RevisionRestore = "restore"
+
+// Trash moves the conversation and its quotes into the trash.  Nothing
+// is removed from the database, it just stops showing up anywhere but
+// the trash page until it is restored or purged.
+func (c *Conversation) Trash(tx *pop.Connection, editor uuid.UUID) error {
+	if err := c.RecordDestroy(tx, editor); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	c.DeletedAt = &now
+
+	err := tx.RawQuery("UPDATE quotes SET deleted_at = ? WHERE conversation_id = ? AND deleted_at IS NULL", now, c.ID).Exec()
+
+	if err != nil {
+		return err
+	}
+
+	if err = queueConversationEvent(tx, c, EventConversationDeleted); err != nil {
+		return err
+	}
+
+	return tx.RawQuery("UPDATE conversations SET deleted_at = ? WHERE id = ?", now, c.ID).Exec()
+}
+
+// LiveQuotes leaves out the quotes an edit removed.  They stay behind in
+// the table, marked deleted, so the history can still bring them back.
+// A conversation in the trash keeps the quotes that went in with it.
+func (c Conversation) LiveQuotes() Quotes {
+	quotes := Quotes{}
+
+	for _, q := range c.Quotes {
+		if q.DeletedAt == nil || (c.DeletedAt != nil && q.DeletedAt.Equal(*c.DeletedAt)) {
+			quotes = append(quotes, q)
+		}
+	}
+
+	return quotes
+}
+
+// DropRemovedQuotes swaps the quotes loaded with each conversation for
+// its LiveQuotes
+func (cs Conversations) DropRemovedQuotes() {
+	for i := range cs {
+		cs[i].Quotes = cs[i].LiveQuotes()
+	}
+}
+
+// Restore takes the conversation and its quotes back out of the trash.
+// Quotes an edit removed before it went in stay removed.
+func (c *Conversation) Restore(tx *pop.Connection, editor uuid.UUID) error {
+	err := tx.RawQuery("UPDATE quotes SET deleted_at = NULL WHERE conversation_id = ? AND deleted_at = (SELECT deleted_at FROM conversations WHERE id = ?)", c.ID, c.ID).Exec()
+
+	if err != nil {
+		return err
+	}
+
+	err = tx.RawQuery("UPDATE conversations SET deleted_at = NULL WHERE id = ?", c.ID).Exec()
+
+	if err != nil {
+		return err
+	}
+
+	c.DeletedAt = nil
+
+	// to anyone listening it is back, as it was before it went in the trash
+	if err = queueConversationEvent(tx, c, EventConversationUpdated); err != nil {
+		return err
+	}
+
+	for i := range c.Quotes {
+		c.Quotes[i].DeletedAt = nil
+
+		if err = recordQuote(tx, &c.Quotes[i], RevisionRestore, editor); err != nil {
+			return err
+		}
+	}
+
+	return recordConversation(tx, c, RevisionRestore, editor)
+}
+
+// Purge removes a trashed conversation and its quotes for good.  The
+// revision history is left behind so there is still a record of what
+// was said.
+func (c *Conversation) Purge(tx *pop.Connection) error {
+	// the quote of the day deck points at the conversation, pull it out first
+	if err := RemoveFromDeck(tx, c.ID); err != nil {
+		return err
+	}
+
+	// the files live outside the database, they are removed once tx commits
+	if err := removeAttachments(tx, c.ID); err != nil {
+		return err
+	}
+
+	if err := tx.RawQuery("DELETE FROM quotes WHERE conversation_id = ?", c.ID).Exec(); err != nil {
+		return err
+	}
+
+	return tx.Destroy(c)
+}
+
+// PurgeTrash purges every conversation that went into the trash before
+// the cutoff, returning how many were purged.
+func PurgeTrash(tx *pop.Connection, cutoff time.Time) (int, error) {
+	trashed := Conversations{}
+
+	if err := tx.Where("deleted_at < ?", cutoff).All(&trashed); err != nil {
+		return 0, err
+	}
+
+	for i := range trashed {
+		if err := trashed[i].Purge(tx); err != nil {
+			return i, err
+		}
+	}
+
+	return len(trashed), nil
+}
@@ -43,6 +43,17 @@ func (u User) String() string {
 	return string(ju)
 }
 
+// DisplayName is what the user goes by where other people can see it,
+// the part of their email before the @, so the address itself stays
+// private
+func (u User) DisplayName() string {
+	if i := strings.Index(u.Email, "@"); i >= 0 {
+		return u.Email[:i]
+	}
+
+	return u.Email
+}
+
 // Users is not required by pop and may be deleted
 type Users []User
 
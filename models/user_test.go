@@ -1,7 +1,10 @@
 package models_test
 
 import (
+	"testing"
+
 	"github.com/navionguy/cloudquotes/models"
+	"github.com/stretchr/testify/require"
 )
 
 func (ms *ModelSuite) Test_User_Create() {
@@ -78,3 +81,10 @@ func (ms *ModelSuite) Test_User_Create_UserExists() {
 	ms.NoError(err)
 	ms.Equal(1, count)
 }
+
+func Test_User_DisplayName(t *testing.T) {
+	rq := require.New(t)
+
+	rq.Equal("mark", models.User{Email: "mark@example.com"}.DisplayName())
+	rq.Equal("mark", models.User{Email: "mark"}.DisplayName())
+}
@@ -0,0 +1,205 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/gobuffalo/pop/v5"
+	"github.com/gofrs/uuid"
+)
+
+// Vote is one user's upvote for a conversation.  Each user only gets
+// one vote per conversation.
+type Vote struct {
+	ID             uuid.UUID `json:"id" db:"id"`
+	CreatedAt      time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at" db:"updated_at"`
+	ConversationID uuid.UUID `json:"conversation_id" db:"conversation_id"`
+	UserID         uuid.UUID `json:"user_id" db:"user_id"`
+}
+
+// String is not required by pop and may be deleted
+func (v Vote) String() string {
+	jv, _ := json.Marshal(v)
+	return string(jv)
+}
+
+// Votes is not required by pop and may be deleted
+type Votes []Vote
+
+// String is not required by pop and may be deleted
+func (v Votes) String() string {
+	jv, _ := json.Marshal(v)
+	return string(jv)
+}
+
+// Standing is one conversation's place on the leaderboard
+type Standing struct {
+	Rank         int          `json:"rank" db:"-"`
+	Votes        int          `json:"votes" db:"votes"`
+	Conversation Conversation `json:"conversation" db:"-"`
+
+	ConversationID uuid.UUID `json:"-" db:"conversation_id"`
+}
+
+// Standings is the whole leaderboard, most loved first
+type Standings []Standing
+
+// Upvote gives the user's vote to the conversation.  Voting twice
+// doesn't count twice.
+func Upvote(tx *pop.Connection, conversationID uuid.UUID, user uuid.UUID) error {
+	voted, err := tx.Where("conversation_id = ? AND user_id = ?", conversationID, user).Exists(&Vote{})
+
+	if err != nil || voted {
+		return err
+	}
+
+	return tx.Create(&Vote{ConversationID: conversationID, UserID: user})
+}
+
+// Unvote takes back the user's vote for the conversation
+func Unvote(tx *pop.Connection, conversationID uuid.UUID, user uuid.UUID) error {
+	return tx.RawQuery("DELETE FROM votes WHERE conversation_id = ? AND user_id = ?", conversationID, user).Exec()
+}
+
+// VoteCounts returns how many votes each of the conversations has.
+// Conversations nobody has voted for are left out of the map.
+func VoteCounts(tx *pop.Connection, cs Conversations) (map[uuid.UUID]int, error) {
+	counts := map[uuid.UUID]int{}
+
+	if len(cs) == 0 {
+		return counts, nil
+	}
+
+	standings := Standings{}
+
+	err := tx.RawQuery("SELECT conversation_id, COUNT(*) AS votes FROM votes WHERE conversation_id IN (?) GROUP BY conversation_id", conversationIDs(cs)).All(&standings)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, st := range standings {
+		counts[st.ConversationID] = st.Votes
+	}
+
+	return counts, nil
+}
+
+// VotedFor returns which of the conversations the user has voted for
+func VotedFor(tx *pop.Connection, cs Conversations, user uuid.UUID) (map[uuid.UUID]bool, error) {
+	voted := map[uuid.UUID]bool{}
+
+	if len(cs) == 0 || user == uuid.Nil {
+		return voted, nil
+	}
+
+	votes := Votes{}
+
+	err := tx.Where("conversation_id IN (?)", conversationIDs(cs)...).Where("user_id = ?", user).All(&votes)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, v := range votes {
+		voted[v.ConversationID] = true
+	}
+
+	return voted, nil
+}
+
+// Leaderboard ranks the archive's published conversations by how many
+// votes they got, keeping the top limit of them.  A year other than zero only
+// counts conversations that happened that year, which is how the
+// quote of the year gets picked.
+func Leaderboard(tx *pop.Connection, archive uuid.UUID, year int, limit int) (Standings, error) {
+	standings := Standings{}
+
+	query := `SELECT votes.conversation_id, COUNT(*) AS votes FROM votes
+		JOIN conversations ON conversations.id = votes.conversation_id
+		WHERE conversations.archive_id = ? AND conversations.status = ? AND conversations.deleted_at IS NULL`
+	args := []interface{}{archive, StatusPublished}
+
+	if year != 0 {
+		query += " AND EXTRACT(YEAR FROM conversations.occurredon) = ?"
+		args = append(args, year)
+	}
+
+	query += " GROUP BY votes.conversation_id, conversations.occurredon ORDER BY votes DESC, conversations.occurredon DESC LIMIT ?"
+	args = append(args, limit)
+
+	if err := tx.RawQuery(query, args...).All(&standings); err != nil {
+		return nil, err
+	}
+
+	if len(standings) == 0 {
+		return standings, nil
+	}
+
+	ids := []interface{}{}
+	for _, st := range standings {
+		ids = append(ids, st.ConversationID)
+	}
+
+	cs := Conversations{}
+
+	if err := tx.Eager("Quotes", "Quotes.Author", "Quotes.CoAuthors").Where("id IN (?)", ids...).All(&cs); err != nil {
+		return nil, err
+	}
+
+	byID := map[uuid.UUID]Conversation{}
+	for _, c := range cs {
+		c.Quotes = c.PublishedQuotes()
+		byID[c.ID] = c
+	}
+
+	// conversations with the same number of votes share a place
+	for i := range standings {
+		standings[i].Rank = i + 1
+
+		if i > 0 && standings[i].Votes == standings[i-1].Votes {
+			standings[i].Rank = standings[i-1].Rank
+		}
+
+		standings[i].Conversation = byID[standings[i].ConversationID]
+	}
+
+	return standings, nil
+}
+
+// LeaderboardYears lists the years that have votes on the archive's
+// leaderboard, newest first.
+func LeaderboardYears(tx *pop.Connection, archive uuid.UUID) ([]int, error) {
+	years := []struct {
+		Year int `db:"year"`
+	}{}
+
+	err := tx.RawQuery(`SELECT DISTINCT EXTRACT(YEAR FROM conversations.occurredon)::int AS year FROM votes
+		JOIN conversations ON conversations.id = votes.conversation_id
+		WHERE conversations.archive_id = ? AND conversations.status = ? AND conversations.deleted_at IS NULL
+		ORDER BY year DESC`, archive, StatusPublished).All(&years)
+
+	if err != nil {
+		return nil, err
+	}
+
+	list := []int{}
+	for _, y := range years {
+		list = append(list, y.Year)
+	}
+
+	return list, nil
+}
+
+// conversationIDs pulls the IDs out of the conversations, ready to be
+// used in an IN (?) query
+func conversationIDs(cs Conversations) []interface{} {
+	ids := []interface{}{}
+
+	for _, c := range cs {
+		ids = append(ids, c.ID)
+	}
+
+	return ids
+}
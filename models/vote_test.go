@@ -0,0 +1,32 @@
+package models_test
+
+import (
+	"testing"
+
+	"github.com/navionguy/cloudquotes/models"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Vote(t *testing.T) {
+
+	var fields = []struct {
+		fn  string
+		msg string
+	}{
+		{"id", "vote id field not found"},
+		{"conversation_id", "conversation_id field not found"},
+		{"user_id", "user_id field not found"},
+		{"created_at", "created_at field not found"},
+		{"updated_at", "updated_at field not found"},
+	}
+
+	v := models.Vote{}
+
+	js := v.String()
+
+	rq := require.New(t)
+
+	for _, fld := range fields {
+		rq.Containsf(js, fld.fn, fld.msg)
+	}
+}
@@ -0,0 +1,523 @@
+package models
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/gobuffalo/pop/v5"
+	"github.com/gobuffalo/validate/v3"
+	"github.com/gobuffalo/validate/v3/validators"
+	"github.com/gofrs/uuid"
+)
+
+// the things that happen in an archive a webhook can be told about
+const (
+	EventConversationCreated   = "conversation.created"
+	EventConversationUpdated   = "conversation.updated"
+	EventConversationPublished = "conversation.published"
+	EventConversationDeleted   = "conversation.deleted"
+	EventAuthorMerged          = "author.merged"
+)
+
+// Events are all the events a webhook can subscribe to
+var Events = []string{EventConversationCreated, EventConversationUpdated, EventConversationPublished, EventConversationDeleted, EventAuthorMerged}
+
+// the states a delivery goes through, it stays pending while there are
+// attempts left
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+// WebhookMaxAttempts is how many times a delivery is tried before it is
+// given up on.  With the backoff doubling from a minute that is a little
+// over four hours of trying.
+const WebhookMaxAttempts = 8
+
+// webhookBackoff is how long the first retry waits
+const webhookBackoff = time.Minute
+
+// webhookBatch is how many deliveries get sent each time DeliverWebhooks
+// is run
+const webhookBatch = 50
+
+// WebhookSignatureHeader carries the HMAC of the payload, so the
+// receiver can check it came from us
+const WebhookSignatureHeader = "X-Cloudquotes-Signature"
+
+// Webhook is a URL an archive's admins want told whenever one of the
+// Events they picked happens there.  Every payload is signed with the
+// Secret.  Events is a comma separated list.
+type Webhook struct {
+	ID        uuid.UUID `json:"id" db:"id"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+	ArchiveID uuid.UUID `json:"archive_id" db:"archive_id"`
+	URL       string    `json:"url" db:"url"`
+	Secret    string    `json:"-" db:"secret"`
+	Events    string    `json:"events" db:"events"`
+	Active    bool      `json:"active" db:"active"`
+}
+
+// String is not required by pop and may be deleted
+func (w Webhook) String() string {
+	jw, _ := json.Marshal(w)
+	return string(jw)
+}
+
+// Webhooks is not required by pop and may be deleted
+type Webhooks []Webhook
+
+// String is not required by pop and may be deleted
+func (w Webhooks) String() string {
+	jw, _ := json.Marshal(w)
+	return string(jw)
+}
+
+// WebhookDelivery is one event on its way to a webhook.  It is kept
+// after it goes out, or is given up on, as the log of what was sent and
+// how the receiver answered.
+type WebhookDelivery struct {
+	ID            uuid.UUID  `json:"id" db:"id"`
+	CreatedAt     time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at" db:"updated_at"`
+	WebhookID     uuid.UUID  `json:"webhook_id" db:"webhook_id"`
+	Event         string     `json:"event" db:"event"`
+	Payload       string     `json:"payload" db:"payload"`
+	Status        string     `json:"status" db:"status"`
+	Attempts      int        `json:"attempts" db:"attempts"`
+	NextAttemptAt *time.Time `json:"next_attempt_at" db:"next_attempt_at"`
+	ResponseCode  int        `json:"response_code" db:"response_code"`
+	LastError     string     `json:"last_error" db:"last_error"`
+	DeliveredAt   *time.Time `json:"delivered_at" db:"delivered_at"`
+}
+
+// String is not required by pop and may be deleted
+func (d WebhookDelivery) String() string {
+	jd, _ := json.Marshal(d)
+	return string(jd)
+}
+
+// WebhookDeliveries is not required by pop and may be deleted
+type WebhookDeliveries []WebhookDelivery
+
+// String is not required by pop and may be deleted
+func (d WebhookDeliveries) String() string {
+	jd, _ := json.Marshal(d)
+	return string(jd)
+}
+
+// webhookPayload is what gets posted to the webhook.  The data only
+// identifies what changed, the receiver looks it up through the API so
+// it only ever sees what its token is allowed to.
+type webhookPayload struct {
+	ID         uuid.UUID   `json:"id"`
+	Event      string      `json:"event"`
+	ArchiveID  uuid.UUID   `json:"archive_id"`
+	OccurredAt time.Time   `json:"occurred_at"`
+	Data       interface{} `json:"data"`
+}
+
+// conversationEvent is the data for the conversation events
+type conversationEvent struct {
+	ConversationID uuid.UUID `json:"conversation_id"`
+	Status         string    `json:"status"`
+	Publish        bool      `json:"publish"`
+}
+
+// authorMergedEvent is the data for EventAuthorMerged
+type authorMergedEvent struct {
+	AuthorID uuid.UUID   `json:"author_id"`
+	Merged   []uuid.UUID `json:"merged_author_ids"`
+}
+
+// Validate gets run every time you call a "pop.Validate*" (pop.ValidateAndSave, pop.ValidateAndCreate, pop.ValidateAndUpdate) method.
+// This method is not required and may be deleted.
+func (w *Webhook) Validate(tx *pop.Connection) (*validate.Errors, error) {
+	return validate.Validate(
+		&validators.UUIDIsPresent{Field: w.ArchiveID, Name: "ArchiveID"},
+		&validators.StringIsPresent{Field: w.Secret, Name: "Secret"},
+		&validators.StringLengthInRange{Field: w.URL, Name: "URL", Max: 2048, Message: "length must be <2048"},
+		&validators.FuncValidator{
+			Field:   w.URL,
+			Name:    "URL",
+			Message: "%s has to be an http or https address",
+			Fn: func() bool {
+				u, err := url.Parse(w.URL)
+				return err == nil && (u.Scheme == "http" || u.Scheme == "https") && len(u.Hostname()) > 0
+			},
+		},
+		&validators.FuncValidator{
+			Field:   w.URL,
+			Name:    "URL",
+			Message: "%s can't be a private, loopback or link-local address",
+			Fn: func() bool {
+				u, err := url.Parse(w.URL)
+				return err != nil || publicHost(u.Hostname())
+			},
+		},
+		&validators.FuncValidator{
+			Field:   w.Events,
+			Name:    "Events",
+			Message: "%s needs at least one event, and only ones there are",
+			Fn: func() bool {
+				events := w.EventList()
+				if len(events) == 0 {
+					return false
+				}
+				for _, e := range events {
+					if !knownEvent(e) {
+						return false
+					}
+				}
+				return true
+			},
+		},
+	), nil
+}
+
+// privateNetworks are the addresses webhooks are never sent to, so a
+// webhook can't be used to poke at whatever sits next to the server:
+// loopback, link-local (cloud metadata lives there), private and shared
+// address space, plus their IPv6 equivalents.
+var privateNetworks = parseNetworks(
+	"0.0.0.0/8", "10.0.0.0/8", "100.64.0.0/10", "127.0.0.0/8",
+	"169.254.0.0/16", "172.16.0.0/12", "192.168.0.0/16",
+	"::/128", "::1/128", "fc00::/7", "fe80::/10",
+)
+
+// parseNetworks turns CIDR strings into networks
+func parseNetworks(cidrs ...string) []*net.IPNet {
+	nets := []*net.IPNet{}
+
+	for _, c := range cidrs {
+		_, n, err := net.ParseCIDR(c)
+		if err != nil {
+			panic(err)
+		}
+		nets = append(nets, n)
+	}
+
+	return nets
+}
+
+// publicIP reports if ip is somewhere a webhook may be sent
+func publicIP(ip net.IP) bool {
+	if v4 := ip.To4(); v4 != nil {
+		ip = v4
+	}
+
+	if ip.IsMulticast() {
+		return false
+	}
+
+	for _, n := range privateNetworks {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// publicHost reports if host, a name or an address, looks like
+// somewhere a webhook may be sent.  A name that can't be looked up
+// right now gets the benefit of the doubt, WebhookDialer checks the
+// address again each time a delivery is sent.
+func publicHost(host string) bool {
+	if strings.EqualFold(host, "localhost") || strings.HasSuffix(strings.ToLower(host), ".localhost") {
+		return false
+	}
+
+	if ip := net.ParseIP(host); ip != nil {
+		return publicIP(ip)
+	}
+
+	ips, err := net.LookupIP(host)
+	if err != nil {
+		return true
+	}
+
+	for _, ip := range ips {
+		if !publicIP(ip) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ErrPrivateAddress is returned when a delivery would have gone to an
+// address webhooks aren't allowed to reach
+var ErrPrivateAddress = errors.New("webhooks can't be sent to private, loopback or link-local addresses")
+
+// WebhookDialer makes the connections deliveries go out over.  It
+// refuses any address that isn't public, checked on the address
+// actually being dialed, so a name that starts resolving somewhere
+// inside after the webhook was saved, or a redirect, doesn't get around
+// the check Validate made.
+func WebhookDialer(timeout time.Duration) *net.Dialer {
+	return &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+
+			if ip := net.ParseIP(host); ip == nil || !publicIP(ip) {
+				return ErrPrivateAddress
+			}
+
+			return nil
+		},
+	}
+}
+
+// ValidateCreate gets run every time you call "pop.ValidateAndCreate" method.
+// This method is not required and may be deleted.
+func (w *Webhook) ValidateCreate(tx *pop.Connection) (*validate.Errors, error) {
+	return validate.NewErrors(), nil
+}
+
+// ValidateUpdate gets run every time you call "pop.ValidateAndUpdate" method.
+// This method is not required and may be deleted.
+func (w *Webhook) ValidateUpdate(tx *pop.Connection) (*validate.Errors, error) {
+	return validate.NewErrors(), nil
+}
+
+// Create saves a new webhook with a freshly made up secret
+func (w *Webhook) Create(tx *pop.Connection) (*validate.Errors, error) {
+	b := make([]byte, 32)
+
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+
+	w.Secret = hex.EncodeToString(b)
+	w.Active = true
+
+	return tx.ValidateAndCreate(w)
+}
+
+// EventList splits the webhook's events apart
+func (w Webhook) EventList() []string {
+	events := []string{}
+
+	for _, e := range strings.Split(w.Events, ",") {
+		if e = strings.TrimSpace(e); len(e) > 0 {
+			events = append(events, e)
+		}
+	}
+
+	return events
+}
+
+// Subscribed reports if the webhook wants to hear about event
+func (w Webhook) Subscribed(event string) bool {
+	for _, e := range w.EventList() {
+		if e == event {
+			return true
+		}
+	}
+
+	return false
+}
+
+// knownEvent checks the event is one of Events
+func knownEvent(event string) bool {
+	for _, e := range Events {
+		if e == event {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SignWebhook works out the signature for a payload, the hex of its
+// HMAC-SHA256 keyed with the webhook's secret, with "sha256=" in front
+func SignWebhook(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// NextWebhookAttempt is when a delivery that has failed attempts times
+// gets tried again.  The wait doubles every time.
+func NextWebhookAttempt(attempts int, now time.Time) time.Time {
+	wait := webhookBackoff
+
+	for i := 1; i < attempts; i++ {
+		wait *= 2
+	}
+
+	return now.Add(wait)
+}
+
+// queueWebhooks lines up a delivery of event to every active webhook in
+// the archive that subscribed to it.  It runs in the same transaction as
+// the change it is about, so nothing is sent for a change that gets
+// rolled back.
+func queueWebhooks(tx *pop.Connection, archiveID uuid.UUID, event string, data interface{}) error {
+	hooks := Webhooks{}
+
+	if err := tx.Where("archive_id = ? AND active = ?", archiveID, true).All(&hooks); err != nil {
+		return err
+	}
+
+	now := time.Now()
+
+	for _, hook := range hooks {
+		if !hook.Subscribed(event) {
+			continue
+		}
+
+		d := &WebhookDelivery{
+			ID:            uuid.Must(uuid.NewV4()),
+			WebhookID:     hook.ID,
+			Event:         event,
+			Status:        DeliveryPending,
+			NextAttemptAt: &now,
+		}
+
+		payload, err := json.Marshal(webhookPayload{ID: d.ID, Event: event, ArchiveID: archiveID, OccurredAt: now, Data: data})
+
+		if err != nil {
+			return err
+		}
+
+		d.Payload = string(payload)
+
+		if err = tx.Create(d); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// queueConversationEvent lines up event for the conversation
+func queueConversationEvent(tx *pop.Connection, c *Conversation, event string) error {
+	return queueWebhooks(tx, c.ArchiveID, event, conversationEvent{ConversationID: c.ID, Status: c.Status, Publish: c.Publish})
+}
+
+// Redeliver puts a delivery back in line to go out again, with a fresh
+// set of attempts
+func (d *WebhookDelivery) Redeliver(tx *pop.Connection) error {
+	now := time.Now()
+
+	d.Status = DeliveryPending
+	d.Attempts = 0
+	d.NextAttemptAt = &now
+
+	return tx.Update(d)
+}
+
+// attempt posts the delivery to the webhook once, and notes how it went.
+// A 2xx answer means it got there, anything else is tried again later
+// until it runs out of attempts.
+func (d *WebhookDelivery) attempt(client *http.Client, hook Webhook, now time.Time) {
+	d.Attempts++
+	d.ResponseCode = 0
+	d.LastError = ""
+
+	req, err := http.NewRequest("POST", hook.URL, bytes.NewBufferString(d.Payload))
+
+	if err == nil {
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Cloudquotes-Event", d.Event)
+		req.Header.Set("X-Cloudquotes-Delivery", d.ID.String())
+		req.Header.Set(WebhookSignatureHeader, SignWebhook(hook.Secret, []byte(d.Payload)))
+
+		var res *http.Response
+
+		if res, err = client.Do(req); err == nil {
+			d.ResponseCode = res.StatusCode
+
+			// the body isn't needed, reading it lets the connection be reused
+			io.Copy(ioutil.Discard, io.LimitReader(res.Body, 64*1024))
+			res.Body.Close()
+		}
+	}
+
+	switch {
+	case err == nil && d.ResponseCode >= 200 && d.ResponseCode < 300:
+		d.Status = DeliveryDelivered
+		d.DeliveredAt = &now
+		d.NextAttemptAt = nil
+	case d.Attempts >= WebhookMaxAttempts:
+		d.Status = DeliveryFailed
+		d.NextAttemptAt = nil
+	default:
+		next := NextWebhookAttempt(d.Attempts, now)
+		d.NextAttemptAt = &next
+	}
+
+	if err != nil {
+		d.LastError = err.Error()
+	} else if d.Status != DeliveryDelivered {
+		d.LastError = http.StatusText(d.ResponseCode)
+	}
+}
+
+// DeliverWebhooks sends the deliveries that are due, returning how many
+// got through.  Every delivery is saved on its own as soon as it has been
+// tried, so a slow receiver doesn't hold a transaction open.  If two
+// copies of the app run it at once a delivery can go out twice, the
+// X-Cloudquotes-Delivery header lets the receiver spot that.
+func DeliverWebhooks(db *pop.Connection, client *http.Client, now time.Time) (int, error) {
+	due := WebhookDeliveries{}
+
+	// a webhook that was switched off keeps its deliveries until it's back on
+	err := db.Where("status = ? AND next_attempt_at <= ?", DeliveryPending, now).Where("webhook_id IN (SELECT id FROM webhooks WHERE active = ?)", true).Order("next_attempt_at ASC").Limit(webhookBatch).All(&due)
+
+	if err != nil {
+		return 0, err
+	}
+
+	hooks := map[uuid.UUID]*Webhook{}
+	delivered := 0
+
+	for i := range due {
+		d := &due[i]
+		hook, ok := hooks[d.WebhookID]
+
+		if !ok {
+			hook = &Webhook{}
+
+			if err = db.Find(hook, d.WebhookID); err != nil {
+				return delivered, err
+			}
+
+			hooks[d.WebhookID] = hook
+		}
+
+		d.attempt(client, *hook, now)
+
+		if err = db.Update(d); err != nil {
+			return delivered, err
+		}
+
+		if d.Status == DeliveryDelivered {
+			delivered++
+		}
+	}
+
+	return delivered, nil
+}
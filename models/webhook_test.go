@@ -0,0 +1,215 @@
+package models_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/navionguy/cloudquotes/models"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_SignWebhook(t *testing.T) {
+	rq := require.New(t)
+
+	// the example from RFC 4231, test case 2
+	rq.Equal("sha256=5bdcc146bf60754e6a042426089575c75a003f089d2739839dec58b964ec3843", models.SignWebhook("Jefe", []byte("what do ya want for nothing?")))
+	rq.NotEqual(models.SignWebhook("Jefe", []byte("{}")), models.SignWebhook("jefe", []byte("{}")))
+}
+
+func Test_NextWebhookAttempt(t *testing.T) {
+	rq := require.New(t)
+
+	now := time.Date(2021, time.January, 24, 12, 0, 0, 0, time.UTC)
+
+	rq.Equal(now.Add(time.Minute), models.NextWebhookAttempt(1, now))
+	rq.Equal(now.Add(2*time.Minute), models.NextWebhookAttempt(2, now))
+	rq.Equal(now.Add(64*time.Minute), models.NextWebhookAttempt(7, now))
+}
+
+func Test_Webhook_Subscribed(t *testing.T) {
+	rq := require.New(t)
+
+	w := models.Webhook{Events: "conversation.created, author.merged"}
+
+	rq.True(w.Subscribed(models.EventConversationCreated))
+	rq.True(w.Subscribed(models.EventAuthorMerged))
+	rq.False(w.Subscribed(models.EventConversationDeleted))
+	rq.Len(w.EventList(), 2)
+}
+
+func Test_Webhook_PrivateURL(t *testing.T) {
+	rq := require.New(t)
+
+	for _, u := range []string{
+		"http://localhost/hook",
+		"http://127.0.0.1:3000/hook",
+		"http://10.1.2.3/hook",
+		"http://172.16.0.9/hook",
+		"http://192.168.1.1/hook",
+		"http://169.254.169.254/latest/meta-data",
+		"http://[::1]/hook",
+		"http://[fe80::1]/hook",
+	} {
+		w := models.Webhook{ArchiveID: uuid.Must(uuid.NewV4()), Secret: "s", URL: u, Events: models.EventConversationCreated}
+		verrs, err := w.Validate(nil)
+		rq.NoError(err)
+		rq.NotEmptyf(verrs.Get("url"), "%s", u)
+	}
+
+	w := models.Webhook{ArchiveID: uuid.Must(uuid.NewV4()), Secret: "s", URL: "https://93.184.216.34/hook", Events: models.EventConversationCreated}
+	verrs, err := w.Validate(nil)
+	rq.NoError(err)
+	rq.False(verrs.HasAny())
+}
+
+// a delivery can't get to a private address even if the webhook was
+// saved pointing somewhere else
+func Test_WebhookDialer_Private(t *testing.T) {
+	rq := require.New(t)
+
+	srv := httptest.NewServer(&webhookReceiver{status: http.StatusOK})
+	defer srv.Close()
+
+	client := &http.Client{Transport: &http.Transport{DialContext: models.WebhookDialer(time.Second).DialContext}}
+
+	_, err := client.Get(srv.URL)
+	rq.Error(err)
+	rq.Contains(err.Error(), models.ErrPrivateAddress.Error())
+}
+
+// webhookReceiver stands in for the far end of a webhook, answering
+// with whatever status it is set to
+type webhookReceiver struct {
+	status    int
+	signature string
+}
+
+func (wr *webhookReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	wr.signature = r.Header.Get(models.WebhookSignatureHeader)
+	w.WriteHeader(wr.status)
+}
+
+// pendingDelivery sets up a webhook pointed at url with one delivery
+// waiting to go out to it
+func pendingDelivery(ms *ModelSuite, url string, attempts int, now time.Time) (*models.Webhook, *models.WebhookDelivery) {
+	archive := &models.Archive{Name: "Hooks", Slug: "hooks-" + uuid.Must(uuid.NewV4()).String()[:8]}
+	ms.NoError(ms.DB.Create(archive))
+
+	// the test receivers listen on loopback, which Validate won't allow
+	hook := &models.Webhook{ArchiveID: archive.ID, URL: url, Events: models.EventConversationCreated, Secret: "test secret", Active: true}
+	ms.NoError(ms.DB.Create(hook))
+
+	d := &models.WebhookDelivery{
+		WebhookID:     hook.ID,
+		Event:         models.EventConversationCreated,
+		Payload:       `{"event":"conversation.created"}`,
+		Status:        models.DeliveryPending,
+		Attempts:      attempts,
+		NextAttemptAt: &now,
+	}
+	ms.NoError(ms.DB.Create(d))
+
+	return hook, d
+}
+
+// a delivery the receiver turns down is tried again later, waiting
+// twice as long each time, until it gets through
+func (ms *ModelSuite) Test_DeliverWebhooks_Retry() {
+	wr := &webhookReceiver{status: http.StatusInternalServerError}
+	srv := httptest.NewServer(wr)
+	defer srv.Close()
+
+	now := time.Now().UTC().Truncate(time.Second)
+	hook, d := pendingDelivery(ms, srv.URL, 0, now)
+
+	n, err := models.DeliverWebhooks(ms.DB, srv.Client(), now)
+	ms.NoError(err)
+	ms.Equal(0, n)
+
+	ms.NoError(ms.DB.Find(d, d.ID))
+	ms.Equal(models.DeliveryPending, d.Status)
+	ms.Equal(1, d.Attempts)
+	ms.Equal(http.StatusInternalServerError, d.ResponseCode)
+	ms.Equal("Internal Server Error", d.LastError)
+	ms.WithinDuration(now.Add(time.Minute), *d.NextAttemptAt, time.Second)
+
+	// it isn't due again yet
+	n, err = models.DeliverWebhooks(ms.DB, srv.Client(), now.Add(30*time.Second))
+	ms.NoError(err)
+	ms.Equal(0, n)
+	ms.NoError(ms.DB.Find(d, d.ID))
+	ms.Equal(1, d.Attempts)
+
+	// the second try fails too, and waits twice as long
+	now = now.Add(time.Minute)
+
+	_, err = models.DeliverWebhooks(ms.DB, srv.Client(), now)
+	ms.NoError(err)
+	ms.NoError(ms.DB.Find(d, d.ID))
+	ms.Equal(2, d.Attempts)
+	ms.WithinDuration(now.Add(2*time.Minute), *d.NextAttemptAt, time.Second)
+
+	// then the receiver comes back
+	wr.status = http.StatusNoContent
+	now = now.Add(2 * time.Minute)
+
+	n, err = models.DeliverWebhooks(ms.DB, srv.Client(), now)
+	ms.NoError(err)
+	ms.Equal(1, n)
+
+	ms.NoError(ms.DB.Find(d, d.ID))
+	ms.Equal(models.DeliveryDelivered, d.Status)
+	ms.Equal(3, d.Attempts)
+	ms.Nil(d.NextAttemptAt)
+	ms.NotNil(d.DeliveredAt)
+	ms.Equal(models.SignWebhook(hook.Secret, []byte(d.Payload)), wr.signature)
+}
+
+// a delivery that runs out of attempts is given up on
+func (ms *ModelSuite) Test_DeliverWebhooks_Failed() {
+	srv := httptest.NewServer(&webhookReceiver{status: http.StatusGone})
+	defer srv.Close()
+
+	now := time.Now().UTC().Truncate(time.Second)
+	_, d := pendingDelivery(ms, srv.URL, models.WebhookMaxAttempts-1, now)
+
+	n, err := models.DeliverWebhooks(ms.DB, srv.Client(), now)
+	ms.NoError(err)
+	ms.Equal(0, n)
+
+	ms.NoError(ms.DB.Find(d, d.ID))
+	ms.Equal(models.DeliveryFailed, d.Status)
+	ms.Equal(models.WebhookMaxAttempts, d.Attempts)
+	ms.Nil(d.NextAttemptAt)
+
+	// and isn't tried again
+	_, err = models.DeliverWebhooks(ms.DB, srv.Client(), now.Add(24*time.Hour))
+	ms.NoError(err)
+	ms.NoError(ms.DB.Find(d, d.ID))
+	ms.Equal(models.WebhookMaxAttempts, d.Attempts)
+}
+
+// a webhook that is switched off keeps its deliveries waiting
+func (ms *ModelSuite) Test_DeliverWebhooks_Inactive() {
+	wr := &webhookReceiver{status: http.StatusOK}
+	srv := httptest.NewServer(wr)
+	defer srv.Close()
+
+	now := time.Now().UTC().Truncate(time.Second)
+	hook, d := pendingDelivery(ms, srv.URL, 0, now)
+
+	hook.Active = false
+	ms.NoError(ms.DB.Update(hook))
+
+	n, err := models.DeliverWebhooks(ms.DB, srv.Client(), now)
+	ms.NoError(err)
+	ms.Equal(0, n)
+
+	ms.NoError(ms.DB.Find(d, d.ID))
+	ms.Equal(models.DeliveryPending, d.Status)
+	ms.Equal(0, d.Attempts)
+	ms.Empty(wr.signature)
+}
@@ -0,0 +1,73 @@
+package storage
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// DiskStore keeps files in a directory on local disk
+type DiskStore struct {
+	Root string
+}
+
+// NewDiskStore returns a store that keeps its files under root.  The
+// directory gets created the first time something is put in it.
+func NewDiskStore(root string) *DiskStore {
+	return &DiskStore{Root: root}
+}
+
+// Put writes the file to a temporary name first and renames it into
+// place, so a failed upload never leaves half a file behind.
+func (d *DiskStore) Put(key string, r io.Reader) error {
+	if err := os.MkdirAll(d.Root, 0750); err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(d.Root, ".upload-")
+
+	if err != nil {
+		return err
+	}
+
+	if _, err = io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	if err = tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return os.Rename(tmp.Name(), d.path(key))
+}
+
+// Get opens the file stored under key
+func (d *DiskStore) Get(key string) (io.ReadCloser, error) {
+	f, err := os.Open(d.path(key))
+
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+
+	return f, err
+}
+
+// Delete removes the file stored under key
+func (d *DiskStore) Delete(key string) error {
+	err := os.Remove(d.path(key))
+
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	return err
+}
+
+// path keeps the key from climbing out of the root directory
+func (d *DiskStore) path(key string) string {
+	return filepath.Join(d.Root, filepath.Base(key))
+}
@@ -0,0 +1,42 @@
+package storage
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_DiskStore(t *testing.T) {
+	rq := require.New(t)
+
+	root, err := ioutil.TempDir("", "cloudquotes-storage")
+	rq.NoError(err)
+	defer os.RemoveAll(root)
+
+	d := NewDiskStore(root)
+
+	rq.NoError(d.Put("photo", strings.NewReader("whiteboard")))
+
+	f, err := d.Get("photo")
+	rq.NoError(err)
+
+	b, err := ioutil.ReadAll(f)
+	f.Close()
+	rq.NoError(err)
+	rq.Equal("whiteboard", string(b))
+
+	rq.NoError(d.Delete("photo"))
+	rq.NoError(d.Delete("photo"))
+
+	_, err = d.Get("photo")
+	rq.Equal(ErrNotFound, err)
+}
+
+func Test_DiskStore_StaysInRoot(t *testing.T) {
+	d := NewDiskStore("/srv/uploads")
+
+	require.Equal(t, "/srv/uploads/passwd", d.path("../../etc/passwd"))
+}
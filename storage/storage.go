@@ -0,0 +1,45 @@
+// Package storage keeps the files attached to conversations.  Everything
+// goes through the Store interface so the files can live somewhere other
+// than local disk without the rest of the app caring.
+package storage
+
+import (
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/gobuffalo/envy"
+)
+
+// ErrNotFound is returned when there is nothing stored under a key
+var ErrNotFound = errors.New("no file stored under that key")
+
+// Store is somewhere attachment files can be kept.  Keys are picked by
+// the caller and are safe to use as a file name.
+type Store interface {
+	// Put saves everything read from r under key, replacing whatever
+	// was there before.
+	Put(key string, r io.Reader) error
+
+	// Get opens the file stored under key.  The caller closes it.
+	Get(key string) (io.ReadCloser, error)
+
+	// Delete removes the file stored under key.  Deleting a key that
+	// isn't there is not an error.
+	Delete(key string) error
+}
+
+var (
+	defaultStore Store
+	defaultOnce  sync.Once
+)
+
+// Default returns the store the app is configured to use.  For now that
+// is always local disk, in the directory named by ATTACHMENTS_DIR.
+func Default() Store {
+	defaultOnce.Do(func() {
+		defaultStore = NewDiskStore(envy.Get("ATTACHMENTS_DIR", "uploads"))
+	})
+
+	return defaultStore
+}